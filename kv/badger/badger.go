@@ -27,8 +27,16 @@ import (
 
 var (
 	entitySequenceKey = []byte{1}
+
+	// watchKey is written by Watch to learn when a new subscription has
+	// begun to receive updates.
+	watchKey = []byte{2}
 )
 
+// setMeta is the user metadata of every key written by Set, which
+// distinguishes sets from deletes in the updates received by subscriptions.
+const setMeta byte = 1
+
 // DB holds some kv-specific state in addition to mixing in a badger.DB.
 type DB struct {
 	*badger.DB
@@ -95,6 +103,36 @@ func (db *DB) NewTxn(update bool) kv.TxnCommitDiscarder {
 	return txn{db: db, tx: btxn}
 }
 
+// NewBatch creates a new kv.Batch backed by a badger.WriteBatch.
+func (db *DB) NewBatch() kv.Batch {
+	return batch{db: db, wb: db.DB.NewWriteBatch()}
+}
+
+type batch struct {
+	db *DB
+	wb *badger.WriteBatch
+}
+
+func (b batch) Set(key, value []byte) error {
+	return b.wb.SetEntry(badger.NewEntry(key, value).WithMeta(setMeta))
+}
+
+func (b batch) Delete(key []byte) error { return b.wb.Delete(key) }
+
+func (b batch) Flush() error {
+	if err := b.wb.Flush(); err != nil {
+		return err
+	}
+	if err := b.db.Sync(); err != nil {
+		// As in txn.Commit, an error from Sync does not indicate that the
+		// writes failed.
+		log.Println("batch.Flush: db.Sync:", err)
+	}
+	return nil
+}
+
+func (b batch) Cancel() { b.wb.Cancel() }
+
 type txn struct {
 	db *DB
 	tx *badger.Txn
@@ -111,7 +149,9 @@ func (s txn) Alloc() (kv.Entity, error) {
 	return kv.Entity(u64), err
 }
 
-func (s txn) Set(key, value []byte) error { return s.tx.Set(key, value) }
+func (s txn) Set(key, value []byte) error {
+	return s.tx.SetEntry(badger.NewEntry(key, value).WithMeta(setMeta))
+}
 
 func (s txn) Delete(key []byte) error { return s.tx.Delete(key) }
 
@@ -126,6 +166,15 @@ func (s txn) Get(key []byte, f func([]byte) error) error {
 	}
 }
 
+func (s txn) Has(key []byte) (bool, error) {
+	if _, err := s.tx.Get(key); err == badger.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (s txn) PrefixIterator(prefix []byte) kv.Iterator {
 	opts := badger.DefaultIteratorOptions
 	// Work around https://github.com/dgraph-io/badger/issues/992 by *not*
@@ -137,8 +186,30 @@ func (s txn) PrefixIterator(prefix []byte) kv.Iterator {
 	}
 }
 
+func (s txn) ReversePrefixIterator(prefix []byte) kv.Iterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	return reverseIterator{iterator{
+		s.tx.NewIterator(opts),
+		prefix,
+	}}
+}
+
+func (s txn) RangeIterator(prefix, lower, upper []byte) kv.Iterator {
+	return rangeIterator{
+		iterator: iterator{
+			s.tx.NewIterator(badger.DefaultIteratorOptions),
+			prefix,
+		},
+		lower: lower,
+		upper: upper,
+	}
+}
+
 func (s txn) Commit() error {
-	if err := s.tx.Commit(); err != nil {
+	if err := s.tx.Commit(); err == badger.ErrConflict {
+		return kv.ErrConflict
+	} else if err != nil {
 		return err
 	}
 	if err := s.db.Sync(); err != nil {
@@ -162,8 +233,48 @@ func (i iterator) Seek(key []byte) { i.Iterator.Seek(append(i.prefix, key...)) }
 
 func (i iterator) Key() []byte { return i.Item().Key()[len(i.prefix):] }
 
-func (i iterator) Valid() bool { return i.Iterator.Valid() && bytes.HasPrefix(i.Item().Key(), i.prefix) }
+// Valid reports whether the iterator is at a key with the prefix. Unlike
+// Item, ValidForPrefix does not add the key to the reads of the transaction,
+// so a key beyond the prefix cannot cause a conflict.
+func (i iterator) Valid() bool { return i.Iterator.ValidForPrefix(i.prefix) }
 
 func (i iterator) Value(f func([]byte) error) error { return i.Item().Value(f) }
 
 func (i iterator) Discard() { i.Close() }
+
+type rangeIterator struct {
+	iterator
+	lower, upper []byte
+}
+
+func (i rangeIterator) Seek(key []byte) {
+	if bytes.Compare(key, i.lower) < 0 {
+		key = i.lower
+	}
+	i.iterator.Seek(key)
+}
+
+func (i rangeIterator) Valid() bool {
+	return i.iterator.Valid() &&
+		(i.upper == nil || bytes.Compare(i.Key(), i.upper) < 0)
+}
+
+type reverseIterator struct{ iterator }
+
+func (i reverseIterator) Seek(key []byte) {
+	if key != nil {
+		i.Iterator.Seek(kv.ConcatByteSlices(i.prefix, key))
+		return
+	}
+	// Seek to the first key after all keys that have the prefix, and then
+	// step back from it if it happens to exist.
+	end := kv.PrefixEnd(i.prefix)
+	if end == nil {
+		i.Iterator.Rewind()
+		return
+	}
+	i.Iterator.Seek(end)
+	if i.Iterator.ValidForPrefix(end) {
+		i.Iterator.Next()
+	}
+}
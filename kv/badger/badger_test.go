@@ -3,7 +3,10 @@ package badger
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
+
+	"github.com/google/note-maps/kv"
 )
 
 func TestNew(t *testing.T) {
@@ -34,3 +37,156 @@ func TestNew(t *testing.T) {
 		t.Errorf("want %#v, got %#v", want, got)
 	}
 }
+
+func TestReverseIterator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestReverseIterator-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := Open(DefaultOptions(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	for _, k := range []string{"a\xff", "a0", "a1", "a2", "b", "b0"} {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		Prefix string
+		Seek   []byte
+		Want   []string
+	}{
+		{"a", nil, []string{"\xff", "2", "1", "0"}},
+		{"a", []byte("1"), []string{"1", "0"}},
+		{"a", []byte("10"), []string{"1", "0"}},
+		{"a\xff", nil, []string{""}},
+		{"b", nil, []string{"0", ""}},
+	} {
+		var got []string
+		iter := txn.ReversePrefixIterator([]byte(test.Prefix))
+		for iter.Seek(test.Seek); iter.Valid(); iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		iter.Discard()
+		if !reflect.DeepEqual(test.Want, got) {
+			t.Errorf("%q: Seek(%q): want %q, got %q",
+				test.Prefix, test.Seek, test.Want, got)
+		}
+	}
+}
+
+func TestRangeIterator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestRangeIterator-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := Open(DefaultOptions(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	for _, k := range []string{"a0", "a1", "a2", "a3", "b0"} {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		Lower, Upper []byte
+		Want         []string
+	}{
+		{nil, nil, []string{"0", "1", "2", "3"}},
+		{[]byte("1"), []byte("3"), []string{"1", "2"}},
+		{[]byte("10"), nil, []string{"2", "3"}},
+		{nil, []byte("1"), []string{"0"}},
+		{[]byte("2"), []byte("2"), nil},
+	} {
+		var got []string
+		iter := txn.RangeIterator([]byte("a"), test.Lower, test.Upper)
+		for iter.Seek(nil); iter.Valid(); iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		iter.Discard()
+		if !reflect.DeepEqual(test.Want, got) {
+			t.Errorf("[%q,%q): want %q, got %q",
+				test.Lower, test.Upper, test.Want, got)
+		}
+	}
+}
+
+func TestCommitConflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestCommitConflict-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := Open(DefaultOptions(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	a, b := db.NewTxn(true), db.NewTxn(true)
+	defer a.Discard()
+	defer b.Discard()
+	if err := a.Get([]byte("k"), func([]byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set([]byte("x"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set([]byte("k"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Commit(); err != kv.ErrConflict {
+		t.Errorf("got %v, want %v", err, kv.ErrConflict)
+	}
+}
+
+func TestBatchTxnDeletePartition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestBatchTxnDeletePartition-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := Open(DefaultOptions(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bt, err := kv.NewBatchTxn(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bt.Discard()
+	// Flush so often that DeletePartition flushes while it iterates.
+	bt.MaxPendingBytes = 64
+	p := kv.Partitioned{Txn: bt, Partition: 1}
+	for e := kv.Entity(1); e < 50; e++ {
+		if err := p.Set(kv.Prefix(p.Partition.Encode()).ConcatEntity(e), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.DeletePartition(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	iter := txn.PrefixIterator(kv.Entity(1).Encode())
+	defer iter.Discard()
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		t.Errorf("key %x was not deleted", iter.Key())
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badger_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/badger"
+	"github.com/google/note-maps/kv/examples/docs"
+	"github.com/google/note-maps/kv/kvtest"
+)
+
+func TestCrashRecovery(t *testing.T) {
+	kvtest.CrashRecovery{
+		Open: func(dir string) (kv.DB, error) {
+			return badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+		},
+		Write:    writeDocuments,
+		Check:    verifyDocumentIndexes,
+		Crashes:  10,
+		MaxDelay: 200 * time.Millisecond,
+	}.Run(t)
+}
+
+// writeDocuments creates, revises and deletes a few documents in a single
+// transaction, so that a crash part way through a commit would leave the
+// title index out of step with the documents.
+func writeDocuments(db kv.DB) error {
+	return kv.Update(db, func(txn kv.Txn) error {
+		s := docs.New(txn)
+		es, err := s.AllDocumentEntities(nil, 50)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 4; i++ {
+			e, err := s.Alloc()
+			if err != nil {
+				return err
+			}
+			es = append(es, e)
+		}
+		for i := 0; i < 8; i++ {
+			e := es[rand.Intn(len(es))]
+			if rand.Intn(4) == 0 {
+				err = s.DeleteDocument(e)
+			} else {
+				err = s.SetDocument(e, &docs.Document{
+					Title: fmt.Sprint("Title ", rand.Intn(10)),
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func verifyDocumentIndexes(db kv.DB) error {
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	return docs.New(txn).VerifyComponentIndex(docs.DocumentPrefix, docs.TitlePrefix,
+		func(bs []byte) ([][]byte, error) {
+			var d docs.Document
+			if err := d.Decode(bs); err != nil {
+				return nil, err
+			}
+			var ivs [][]byte
+			for _, iv := range d.IndexTitle() {
+				ivs = append(ivs, iv.Encode())
+			}
+			return ivs, nil
+		})
+}
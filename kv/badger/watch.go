@@ -0,0 +1,147 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/google/note-maps/kv"
+)
+
+const (
+	// watchInterval is how often Watch writes watchKey while it waits for
+	// a new subscription to begin.
+	watchInterval = 10 * time.Millisecond
+
+	// watchTimeout is how long Watch waits for a new subscription to begin.
+	watchTimeout = 5 * time.Second
+)
+
+var (
+	errWatchTimeout = errors.New("badger: timed out starting a subscription")
+	errWatchEnded   = errors.New("badger: subscription ended before it began")
+
+	// internalPrefix begins the keys that Badger writes for its own use.
+	internalPrefix = []byte("!badger!")
+)
+
+// Watch returns a subscription to the changes made to keys that begin with
+// prefix, using Badger's own subscriptions.
+//
+// Badger does not report when a subscription begins to receive updates, so
+// Watch writes to a reserved key until the subscription receives the write.
+func (db *DB) Watch(prefix []byte) (kv.Subscription, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &subscription{
+		Feed:   kv.NewFeed(cancel),
+		prefix: kv.ConcatByteSlices(prefix),
+		ready:  make(chan struct{}),
+	}
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		err := db.DB.Subscribe(ctx, s.receive, s.prefix, watchKey)
+		if err == context.Canceled {
+			err = nil
+		}
+		s.End(err)
+	}()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	timeout := time.After(watchTimeout)
+	for {
+		if err := db.DB.Update(func(txn *badger.Txn) error {
+			return txn.Set(watchKey, nil)
+		}); err != nil {
+			s.Close()
+			return nil, err
+		}
+		select {
+		case <-s.ready:
+			return s, nil
+		case <-ended:
+			if err := s.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errWatchEnded
+		case <-timeout:
+			s.Close()
+			return nil, errWatchTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// subscription publishes the changes received from one of Badger's own
+// subscriptions.
+type subscription struct {
+	*kv.Feed
+	prefix []byte
+
+	// ready is closed when the subscription receives watchKey.
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// receive publishes the changes in kvs, grouped by the commit that made them.
+func (s *subscription) receive(kvs *badger.KVList) error {
+	var (
+		changes []kv.Change
+		version uint64
+	)
+	flush := func() {
+		sort.Slice(changes, func(i, j int) bool {
+			return bytes.Compare(changes[i].Key, changes[j].Key) < 0
+		})
+		s.Publish(changes)
+		changes = nil
+	}
+	for _, kvp := range kvs.Kv {
+		if bytes.Equal(kvp.Key, watchKey) {
+			s.readyOnce.Do(func() { close(s.ready) })
+			continue
+		}
+		if !bytes.HasPrefix(kvp.Key, s.prefix) || reserved(kvp.Key) {
+			continue
+		}
+		if kvp.Version != version {
+			flush()
+			version = kvp.Version
+		}
+		c := kv.Change{Key: kvp.Key}
+		if len(kvp.Meta) > 0 && kvp.Meta[0]&setMeta != 0 {
+			c.Value = kvp.Value
+		} else {
+			c.Deleted = true
+		}
+		changes = append(changes, c)
+	}
+	flush()
+	return nil
+}
+
+// reserved returns true if key is used by Badger or by this package rather
+// than by clients of kv.DB.
+func reserved(key []byte) bool {
+	return bytes.Equal(key, entitySequenceKey) ||
+		bytes.Equal(key, watchKey) ||
+		bytes.HasPrefix(key, internalPrefix)
+}
@@ -0,0 +1,295 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import "errors"
+
+// ErrBatchUnsupported is returned by NewBatchTxn when a DB does not implement
+// Batcher.
+var ErrBatchUnsupported = errors.New("kv: DB does not support batches")
+
+// Batch accumulates writes to be applied to a DB without the size limits of a
+// single transaction.
+//
+// Writes made through a Batch are not atomic: a batch may be applied as
+// several transactions, and writes that have not yet been flushed are not
+// visible to any transaction.
+type Batch interface {
+	// Set stores key and value in the underlying key-value store. The
+	// caller must not modify key or value after calling Set.
+	Set(key, value []byte) error
+
+	// Delete deletes key and its value from the underlying key-value store.
+	// The caller must not modify key after calling Delete.
+	Delete(key []byte) error
+
+	// Flush applies all writes made through the batch and waits for them to
+	// complete. The batch cannot be used after Flush has been called.
+	Flush() error
+
+	// Cancel ends the batch. Writes that have not been applied may be
+	// dropped.
+	Cancel()
+}
+
+// Batcher is implemented by DBs that support batched writes.
+type Batcher interface {
+	NewBatch() Batch
+}
+
+// DefaultBatchTxnSize is the default for BatchTxn.MaxPendingBytes.
+const DefaultBatchTxnSize = 1 << 20
+
+// BatchTxn implements TxnCommitDiscarder by writing through a Batch, so that
+// code written for a Txn, like the Set methods generated by kvschema, can be
+// used for bulk loads that would not fit into a single transaction.
+//
+// Reads made through a BatchTxn see all writes made through it, including
+// writes that have not yet been flushed. Writes made by other transactions
+// are only guaranteed to become visible each time the BatchTxn flushes its
+// batch, which happens whenever the size of pending writes exceeds
+// MaxPendingBytes and before any iterator is created. An iterator reads from
+// the state of the store when it was created, even if the batch is flushed
+// while it is open.
+//
+// Once a write or flush fails, every subsequent call fails with the same
+// error. Since an Iterator cannot return an error except from Value, an
+// iterator created after a failure is empty and returns the error from
+// Value, so callers must check the error returned by Commit before relying on
+// what they have read.
+//
+// Like a Batch, a BatchTxn is not atomic: if it is discarded or fails part
+// way through, some of its writes may already have been applied.
+//
+// A BatchTxn is not safe for concurrent use.
+type BatchTxn struct {
+	// MaxPendingBytes is the size of pending writes beyond which the batch
+	// will be flushed.
+	MaxPendingBytes int
+
+	db      DB
+	batcher Batcher
+	reader  *batchReader
+	batch   Batch
+	pending map[string][]byte
+	size    int
+	err     error
+}
+
+// NewBatchTxn returns a new BatchTxn writing to db, or ErrBatchUnsupported if
+// db does not implement Batcher.
+func NewBatchTxn(db DB) (*BatchTxn, error) {
+	batcher, ok := db.(Batcher)
+	if !ok {
+		return nil, ErrBatchUnsupported
+	}
+	return &BatchTxn{
+		MaxPendingBytes: DefaultBatchTxnSize,
+		db:              db,
+		batcher:         batcher,
+		reader:          &batchReader{txn: db.NewTxn(false)},
+		batch:           batcher.NewBatch(),
+		pending:         make(map[string][]byte),
+	}, nil
+}
+
+// Alloc allocates a new Entity value from the underlying DB.
+func (b *BatchTxn) Alloc() (Entity, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.txn.Alloc()
+}
+
+// Set stores key and value in the batch.
+func (b *BatchTxn) Set(key, value []byte) error {
+	key, value = ConcatByteSlices(key), ConcatByteSlices(value)
+	if value == nil {
+		value = []byte{}
+	}
+	return b.write(key, value)
+}
+
+// Delete deletes key and its value through the batch.
+func (b *BatchTxn) Delete(key []byte) error {
+	return b.write(ConcatByteSlices(key), nil)
+}
+
+// write records a pending write, where a nil value means deletion.
+func (b *BatchTxn) write(key, value []byte) error {
+	if b.err != nil {
+		return b.err
+	}
+	var err error
+	if value == nil {
+		err = b.batch.Delete(key)
+	} else {
+		err = b.batch.Set(key, value)
+	}
+	if err != nil {
+		b.err = err
+		return err
+	}
+	b.pending[string(key)] = value
+	b.size += len(key) + len(value)
+	if b.size > b.MaxPendingBytes {
+		return b.flush()
+	}
+	return nil
+}
+
+// Get finds the value associated with key, whether or not it has been
+// flushed, and passes it to f.
+func (b *BatchTxn) Get(key []byte, f func([]byte) error) error {
+	if b.err != nil {
+		return b.err
+	}
+	if v, ok := b.pending[string(key)]; ok {
+		if len(v) == 0 {
+			return f(nil)
+		}
+		return f(v)
+	}
+	return b.reader.txn.Get(key, f)
+}
+
+// PrefixIterator flushes the batch and then returns an iterator over all
+// key-value pairs with keys matching the given prefix.
+func (b *BatchTxn) PrefixIterator(prefix []byte) Iterator {
+	if err := b.flush(); err != nil {
+		return errIterator{err}
+	}
+	return b.reader.iterator(b.reader.txn.PrefixIterator(prefix))
+}
+
+// ReversePrefixIterator flushes the batch and then returns a reverse iterator
+// over all key-value pairs with keys matching the given prefix.
+func (b *BatchTxn) ReversePrefixIterator(prefix []byte) Iterator {
+	if err := b.flush(); err != nil {
+		return errIterator{err}
+	}
+	return b.reader.iterator(b.reader.txn.ReversePrefixIterator(prefix))
+}
+
+// RangeIterator flushes the batch and then returns an iterator over a range
+// of key-value pairs with keys matching the given prefix.
+func (b *BatchTxn) RangeIterator(prefix, lower, upper []byte) Iterator {
+	if err := b.flush(); err != nil {
+		return errIterator{err}
+	}
+	return b.reader.iterator(b.reader.txn.RangeIterator(prefix, lower, upper))
+}
+
+// flush applies all pending writes and starts a new batch, along with a new
+// transaction through which the flushed writes can be read.
+//
+// The transaction that has been replaced is discarded once every iterator
+// created from it has been discarded, since callers such as DeletePartition
+// write while they iterate.
+func (b *BatchTxn) flush() error {
+	if b.err != nil {
+		return b.err
+	}
+	if len(b.pending) == 0 {
+		return nil
+	}
+	b.reader.retire()
+	b.reader = nil
+	batch := b.batch
+	b.batch = nil
+	if b.err = batch.Flush(); b.err != nil {
+		return b.err
+	}
+	b.reader = &batchReader{txn: b.db.NewTxn(false)}
+	b.batch = b.batcher.NewBatch()
+	b.pending = make(map[string][]byte)
+	b.size = 0
+	return nil
+}
+
+// Commit flushes all pending writes, and returns the first error encountered
+// by the BatchTxn, if any.
+//
+// The BatchTxn cannot be used after Commit has been called.
+func (b *BatchTxn) Commit() error {
+	err := b.flush()
+	b.Discard()
+	return err
+}
+
+// Discard cancels the batch, dropping any writes that have not been flushed,
+// and releases resources held by the BatchTxn.
+func (b *BatchTxn) Discard() {
+	if b.batch != nil {
+		b.batch.Cancel()
+		b.batch = nil
+	}
+	if b.reader != nil {
+		b.reader.retire()
+		b.reader = nil
+	}
+	if b.err == nil {
+		b.err = errors.New("kv: BatchTxn has been discarded")
+	}
+}
+
+// batchReader is a transaction through which a BatchTxn reads, along with a
+// count of its open iterators.
+type batchReader struct {
+	txn       TxnCommitDiscarder
+	iterators int
+	retired   bool
+}
+
+// iterator counts iter as open until it is discarded.
+func (r *batchReader) iterator(iter Iterator) Iterator {
+	r.iterators++
+	return &batchIterator{Iterator: iter, reader: r}
+}
+
+// retire discards r's transaction as soon as it has no open iterators.
+func (r *batchReader) retire() {
+	r.retired = true
+	if r.iterators == 0 {
+		r.txn.Discard()
+	}
+}
+
+type batchIterator struct {
+	Iterator
+	reader    *batchReader
+	discarded bool
+}
+
+func (i *batchIterator) Discard() {
+	if i.discarded {
+		return
+	}
+	i.discarded = true
+	i.Iterator.Discard()
+	if i.reader.iterators--; i.reader.iterators == 0 && i.reader.retired {
+		i.reader.txn.Discard()
+	}
+}
+
+// errIterator is an empty iterator that returns err from Value.
+type errIterator struct{ err error }
+
+func (errIterator) Seek([]byte)                      {}
+func (errIterator) Next()                            {}
+func (errIterator) Valid() bool                      { return false }
+func (errIterator) Key() []byte                      { return nil }
+func (i errIterator) Value(func([]byte) error) error { return i.err }
+func (errIterator) Discard()                         {}
@@ -0,0 +1,362 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bolt provides an implementation of kv.DB on top of bbolt, a
+// single-file B+tree database.
+//
+// Unlike Badger, bbolt keeps all of its data in one memory-mapped file that
+// grows only as needed, which makes it a better fit for devices with little
+// address space to spare.
+//
+// bbolt allows only one update transaction at a time: NewTxn(true) blocks
+// until any other update transaction has been committed or discarded. As a
+// consequence, Commit never returns kv.ErrConflict.
+//
+// bbolt must also wait for all read-only transactions to end whenever it
+// needs to grow its memory map, so, as with bbolt itself, a goroutine that
+// holds a read-only transaction should not wait for an update transaction to
+// commit unless bbolt.Options.InitialMmapSize is large enough to hold the
+// whole database.
+package bolt
+
+import (
+	"bytes"
+	"os"
+	"sync"
+
+	"github.com/google/note-maps/kv"
+	bbolt "go.etcd.io/bbolt"
+)
+
+var (
+	// bucketName is the name of the bucket holding all key-value pairs. The
+	// bucket's sequence records the last allocated entity.
+	bucketName = []byte("kv")
+)
+
+// DB holds some kv-specific state in addition to mixing in a bbolt.DB.
+type DB struct {
+	*bbolt.DB
+
+	// mutex guards last, the last entity allocated by any transaction.
+	mutex sync.Mutex
+	last  uint64
+}
+
+// Open creates or opens the database at path with the given options, which
+// may be nil.
+func Open(path string, mode os.FileMode, options *bbolt.Options) (*DB, error) {
+	bdb, err := bbolt.Open(path, mode, options)
+	if err != nil {
+		return nil, err
+	}
+	db := &DB{DB: bdb}
+	if bdb.IsReadOnly() {
+		err = bdb.View(func(tx *bbolt.Tx) error {
+			if b := tx.Bucket(bucketName); b != nil {
+				db.last = b.Sequence()
+			}
+			return nil
+		})
+	} else {
+		err = bdb.Update(func(tx *bbolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists(bucketName)
+			if err == nil {
+				db.last = b.Sequence()
+			}
+			return err
+		})
+	}
+	if err != nil {
+		bdb.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close records the last allocated entity and closes the database.
+func (db *DB) Close() error {
+	if db == nil {
+		return nil
+	}
+	if !db.DB.IsReadOnly() {
+		if err := db.DB.Update(db.persist); err != nil {
+			db.DB.Close()
+			return err
+		}
+	}
+	return db.DB.Close()
+}
+
+// NewTxn creates a new kv.TxnCommitDiscarder.
+//
+// If update is true, NewTxn blocks until no other update transaction is open.
+func (db *DB) NewTxn(update bool) kv.TxnCommitDiscarder {
+	tx, err := db.DB.Begin(update)
+	if err != nil {
+		return errTxn{err}
+	}
+	return &txn{db: db, tx: tx, b: tx.Bucket(bucketName)}
+}
+
+// alloc returns a new entity.
+//
+// Entities are allocated in memory so that read-only transactions can
+// allocate them without opening an update transaction, which bbolt does not
+// allow while a read-only transaction is open in the same goroutine. Every
+// update transaction records the last allocated entity through persist, so
+// that any entity that has been written to the database will not be
+// allocated again after it is reopened.
+func (db *DB) alloc() kv.Entity {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.last++
+	return kv.Entity(db.last)
+}
+
+// persist records the last allocated entity in tx.
+func (db *DB) persist(tx *bbolt.Tx) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	b := tx.Bucket(bucketName)
+	if b.Sequence() >= db.last {
+		return nil
+	}
+	return b.SetSequence(db.last)
+}
+
+type txn struct {
+	db *DB
+	tx *bbolt.Tx
+	b  *bbolt.Bucket
+
+	// writes counts the writes made in tx so that iterators know when they
+	// must reposition their cursors.
+	writes int
+}
+
+func (s *txn) Alloc() (kv.Entity, error) {
+	return s.db.alloc(), nil
+}
+
+// Set stores a copy of key and value, since bbolt requires both to remain
+// unmodified until the transaction ends.
+func (s *txn) Set(key, value []byte) error {
+	s.writes++
+	return s.b.Put(kv.ConcatByteSlices(key), kv.ConcatByteSlices(value))
+}
+
+func (s *txn) Delete(key []byte) error {
+	s.writes++
+	return s.b.Delete(key)
+}
+
+func (s *txn) Get(key []byte, f func([]byte) error) error {
+	return f(s.b.Get(key))
+}
+
+func (s *txn) PrefixIterator(prefix []byte) kv.Iterator {
+	return &iterator{txn: s, c: s.b.Cursor(), prefix: prefix}
+}
+
+func (s *txn) ReversePrefixIterator(prefix []byte) kv.Iterator {
+	return &iterator{txn: s, c: s.b.Cursor(), prefix: prefix, reverse: true}
+}
+
+func (s *txn) RangeIterator(prefix, lower, upper []byte) kv.Iterator {
+	return &iterator{
+		txn:    s,
+		c:      s.b.Cursor(),
+		prefix: prefix,
+		lower:  lower,
+		upper:  upper,
+	}
+}
+
+// Commit commits an update transaction, or simply ends a read-only
+// transaction.
+func (s *txn) Commit() error {
+	if !s.tx.Writable() {
+		return s.tx.Rollback()
+	}
+	if err := s.db.persist(s.tx); err != nil {
+		s.tx.Rollback()
+		return err
+	}
+	return s.tx.Commit()
+}
+
+func (s *txn) Discard() {
+	// Rollback returns an error if the transaction has already been
+	// committed, and that is fine.
+	s.tx.Rollback()
+}
+
+// errTxn is returned by NewTxn when bbolt fails to begin a transaction. It
+// returns the same error from every method.
+type errTxn struct{ err error }
+
+func (s errTxn) Alloc() (kv.Entity, error)                             { return 0, s.err }
+func (s errTxn) Set(key, value []byte) error                           { return s.err }
+func (s errTxn) Delete(key []byte) error                               { return s.err }
+func (s errTxn) Get(key []byte, f func([]byte) error) error            { return s.err }
+func (s errTxn) PrefixIterator(prefix []byte) kv.Iterator              { return &iterator{} }
+func (s errTxn) ReversePrefixIterator(prefix []byte) kv.Iterator       { return &iterator{} }
+func (s errTxn) RangeIterator(prefix, lower, upper []byte) kv.Iterator { return &iterator{} }
+func (s errTxn) Commit() error                                         { return s.err }
+func (s errTxn) Discard()                                              {}
+
+// iterator implements kv.Iterator on a bbolt.Cursor.
+//
+// A cursor must be repositioned after its bucket is modified, so iterator
+// keeps track of its current key and seeks back to it when it finds that the
+// transaction has been written to since the cursor last moved.
+type iterator struct {
+	txn          *txn
+	c            *bbolt.Cursor
+	prefix       []byte
+	lower, upper []byte
+	reverse      bool
+
+	key, value []byte
+	writes     int
+}
+
+func (i *iterator) Seek(key []byte) {
+	if i.c == nil {
+		return
+	}
+	if !i.reverse && bytes.Compare(key, i.lower) < 0 {
+		key = i.lower
+	}
+	if i.reverse && key == nil {
+		end := kv.PrefixEnd(i.prefix)
+		if end == nil {
+			i.set(i.c.Last())
+		} else {
+			i.seekReverse(end, false)
+		}
+	} else if i.reverse {
+		i.seekReverse(kv.ConcatByteSlices(i.prefix, key), true)
+	} else {
+		i.set(i.c.Seek(kv.ConcatByteSlices(i.prefix, key)))
+	}
+	i.writes = i.txn.writes
+}
+
+// seekReverse moves to the last key before k, or to k itself if inclusive is
+// true and k exists.
+func (i *iterator) seekReverse(k []byte, inclusive bool) {
+	key, value := i.c.Seek(k)
+	switch {
+	case key == nil:
+		key, value = i.c.Last()
+	case bytes.Equal(key, k) && inclusive:
+	default:
+		key, value = i.c.Prev()
+	}
+	i.set(key, value)
+}
+
+func (i *iterator) set(key, value []byte) { i.key, i.value = key, value }
+
+func (i *iterator) Next() {
+	if i.writes != i.txn.writes {
+		// Reposition the cursor, remembering that the current key may have
+		// been deleted.
+		current := i.key
+		if i.reverse {
+			i.seekReverse(current, false)
+		} else {
+			key, value := i.c.Seek(current)
+			if bytes.Equal(key, current) {
+				key, value = i.c.Next()
+			}
+			i.set(key, value)
+		}
+		i.writes = i.txn.writes
+		return
+	}
+	if i.reverse {
+		i.set(i.c.Prev())
+	} else {
+		i.set(i.c.Next())
+	}
+}
+
+func (i *iterator) Valid() bool {
+	if i.key == nil || !bytes.HasPrefix(i.key, i.prefix) {
+		return false
+	}
+	return i.upper == nil || bytes.Compare(i.Key(), i.upper) < 0
+}
+
+func (i *iterator) Key() []byte { return i.key[len(i.prefix):] }
+
+func (i *iterator) Value(f func([]byte) error) error { return f(i.value) }
+
+func (i *iterator) Discard() {}
+
+// NewBatch returns a kv.Batch that buffers writes in memory and applies them
+// in a single update transaction when flushed. Unlike Badger, bbolt does not
+// limit the size of a transaction.
+func (db *DB) NewBatch() kv.Batch {
+	return &batch{db: db}
+}
+
+type batch struct {
+	db     *DB
+	writes []write
+}
+
+// write is a buffered write, where a nil value means deletion.
+type write struct{ key, value []byte }
+
+func (b *batch) Set(key, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	b.writes = append(b.writes, write{key, value})
+	return nil
+}
+
+func (b *batch) Delete(key []byte) error {
+	b.writes = append(b.writes, write{key, nil})
+	return nil
+}
+
+func (b *batch) Flush() error {
+	writes := b.writes
+	b.writes = nil
+	return b.db.DB.Update(func(tx *bbolt.Tx) error {
+		if err := b.db.persist(tx); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(bucketName)
+		for _, w := range writes {
+			var err error
+			if w.value == nil {
+				err = bucket.Delete(w.key)
+			} else {
+				err = bucket.Put(w.key, w.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *batch) Cancel() { b.writes = nil }
@@ -0,0 +1,337 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache provides an implementation of kv.DB that keeps recently used
+// decoded values in memory.
+//
+// Read-only transactions implement kv.DecodedGetter, so that code generated
+// by kvschema reads and decodes each component value once, rather than every
+// time it is needed, until the value is modified or evicted. Update
+// transactions always read from the underlying DB so that their commits can
+// still detect conflicts, and their commits remove the keys they modify from
+// the cache.
+//
+// Every write to the underlying DB must be made through the same cache.DB, or
+// the cache may return values that have since been modified.
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/google/note-maps/kv"
+)
+
+// DefaultSize is the number of decoded values a DB keeps if Options.Size is
+// not positive.
+const DefaultSize = 1 << 16
+
+// Options configures a DB.
+type Options struct {
+	// Size is the maximum number of decoded values to keep.
+	Size int
+}
+
+// Stats counts how often a DB has found decoded values in its cache.
+type Stats struct {
+	Hits, Misses uint64
+}
+
+// DB implements kv.DB with a cache of decoded values in front of another
+// kv.DB.
+type DB struct {
+	db kv.DB
+
+	mutex sync.Mutex
+
+	// generation is incremented whenever a key may have been modified. A
+	// transaction uses the cache only while the generation is the same as
+	// when the transaction was created.
+	generation uint64
+
+	// writing counts the commits and batches that are in progress.
+	// Transactions created while any are in progress may observe some of
+	// their writes before the generation changes, so they do not use the
+	// cache at all.
+	writing int
+
+	values *lru
+	stats  Stats
+}
+
+// New returns a DB that caches decoded values read from db.
+func New(db kv.DB, opts Options) *DB {
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &DB{db: db, values: newLRU(size)}
+}
+
+// NewTxn returns a transaction of the underlying DB. If update is false, the
+// transaction uses the cache.
+func (db *DB) NewTxn(update bool) kv.TxnCommitDiscarder {
+	if update {
+		return &updateTxn{TxnCommitDiscarder: db.db.NewTxn(true), db: db}
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	// The snapshot is taken while db.mutex is held, so that no commit can
+	// complete between it and the reading of db.generation.
+	return &readTxn{
+		TxnCommitDiscarder: db.db.NewTxn(false),
+		db:                 db,
+		generation:         db.generation,
+		cached:             db.writing == 0,
+	}
+}
+
+// NewBatch returns a batch of the underlying DB, or a batch that writes
+// through a single transaction if the underlying DB does not implement
+// kv.Batcher.
+func (db *DB) NewBatch() kv.Batch {
+	db.begin()
+	b := &batch{db: db}
+	if batcher, ok := db.db.(kv.Batcher); ok {
+		b.Batch = batcher.NewBatch()
+	} else {
+		b.Batch = txnBatch{db.db.NewTxn(true)}
+	}
+	return b
+}
+
+// Watch returns a subscription to the changes made to keys in the underlying
+// DB that begin with prefix.
+func (db *DB) Watch(prefix []byte) (kv.Subscription, error) {
+	return kv.Watch(db.db, prefix)
+}
+
+// Close closes the underlying DB.
+func (db *DB) Close() error { return db.db.Close() }
+
+// Stats returns the number of times that read-only transactions have found
+// and have not found decoded values in the cache.
+func (db *DB) Stats() Stats {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.stats
+}
+
+// begin records the start of a commit or batch.
+func (db *DB) begin() {
+	db.mutex.Lock()
+	db.writing++
+	db.mutex.Unlock()
+}
+
+// end records the end of a commit or batch that may have modified keys.
+func (db *DB) end(keys map[string]bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for k := range keys {
+		db.values.remove(k)
+	}
+	db.generation++
+	db.writing--
+}
+
+// invalidate removes key from the cache before it is modified by a batch.
+func (db *DB) invalidate(key string) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.values.remove(key)
+	db.generation++
+}
+
+// get returns the cached value of key, if it was cached in generation g.
+func (db *DB) get(g uint64, key string) (interface{}, bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if g != db.generation {
+		return nil, false
+	}
+	v, ok := db.values.get(key)
+	if ok {
+		db.stats.Hits++
+	} else {
+		db.stats.Misses++
+	}
+	return v, ok
+}
+
+// add caches v as the value of key, if it was read in generation g.
+func (db *DB) add(g uint64, key string, v interface{}) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if g == db.generation {
+		db.values.add(key, v)
+	}
+}
+
+type readTxn struct {
+	kv.TxnCommitDiscarder
+	db         *DB
+	generation uint64
+	cached     bool
+}
+
+func (s *readTxn) GetDecoded(key []byte, decode kv.DecodeFunc) (interface{}, error) {
+	if !s.cached {
+		return kv.GetDecoded(s.TxnCommitDiscarder, key, decode)
+	}
+	k := string(key)
+	if v, ok := s.db.get(s.generation, k); ok {
+		return v, nil
+	}
+	v, err := kv.GetDecoded(s.TxnCommitDiscarder, key, decode)
+	if err == nil {
+		s.db.add(s.generation, k, v)
+	}
+	return v, err
+}
+
+func (s *readTxn) Has(key []byte) (bool, error) { return kv.Has(s.TxnCommitDiscarder, key) }
+
+type updateTxn struct {
+	kv.TxnCommitDiscarder
+	db *DB
+
+	// keys holds every key written by the transaction.
+	keys map[string]bool
+}
+
+func (s *updateTxn) Has(key []byte) (bool, error) { return kv.Has(s.TxnCommitDiscarder, key) }
+
+func (s *updateTxn) Set(key, value []byte) error {
+	if err := s.TxnCommitDiscarder.Set(key, value); err != nil {
+		return err
+	}
+	s.written(key)
+	return nil
+}
+
+func (s *updateTxn) Delete(key []byte) error {
+	if err := s.TxnCommitDiscarder.Delete(key); err != nil {
+		return err
+	}
+	s.written(key)
+	return nil
+}
+
+func (s *updateTxn) written(key []byte) {
+	if s.keys == nil {
+		s.keys = make(map[string]bool)
+	}
+	s.keys[string(key)] = true
+}
+
+func (s *updateTxn) Commit() error {
+	if len(s.keys) == 0 {
+		return s.TxnCommitDiscarder.Commit()
+	}
+	s.db.begin()
+	defer s.db.end(s.keys)
+	return s.TxnCommitDiscarder.Commit()
+}
+
+// batch removes each key it writes from the cache. Since the underlying
+// batch may apply some writes before Flush, the DB treats the batch as a
+// commit in progress until it is flushed or canceled.
+type batch struct {
+	kv.Batch
+	db   *DB
+	done bool
+}
+
+func (b *batch) Set(key, value []byte) error {
+	b.db.invalidate(string(key))
+	return b.Batch.Set(key, value)
+}
+
+func (b *batch) Delete(key []byte) error {
+	b.db.invalidate(string(key))
+	return b.Batch.Delete(key)
+}
+
+func (b *batch) Flush() error {
+	defer b.finish()
+	return b.Batch.Flush()
+}
+
+func (b *batch) Cancel() {
+	defer b.finish()
+	b.Batch.Cancel()
+}
+
+func (b *batch) finish() {
+	if !b.done {
+		b.done = true
+		b.db.end(nil)
+	}
+}
+
+// txnBatch implements kv.Batch with a single transaction.
+type txnBatch struct{ kv.TxnCommitDiscarder }
+
+func (b txnBatch) Flush() error { return b.Commit() }
+
+func (b txnBatch) Cancel() { b.Discard() }
+
+// lru holds up to size values, evicting the least recently used value to make
+// room for another.
+type lru struct {
+	size int
+	// order holds an *lruEntry for each value, most recently used first.
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lru) get(key string) (interface{}, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+func (c *lru) add(key string, value interface{}) {
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruEntry).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key, value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lru) remove(key string) {
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/cache"
+	"github.com/google/note-maps/kv/kvtest"
+	"github.com/google/note-maps/kv/memory"
+)
+
+func TestConformance(t *testing.T) {
+	kvtest.RunConformance(t, func(t *testing.T) kv.DB {
+		return cache.New(kvtest.NewBackendDB(t, kvtest.Backend()), cache.Options{})
+	})
+}
+
+// decoder counts the values it decodes.
+type decoder struct{ n int }
+
+func (d *decoder) decode(bs []byte) (interface{}, error) {
+	d.n++
+	return string(bs), nil
+}
+
+// get returns the decoded value of key in txn, and checks that it is want.
+func (d *decoder) get(t *testing.T, txn kv.Txn, key, want string) {
+	t.Helper()
+	v, err := kv.GetDecoded(txn, []byte(key), d.decode)
+	if err != nil {
+		t.Fatal(err)
+	} else if v != want {
+		t.Errorf("%q: want %q, got %q", key, want, v)
+	}
+}
+
+func set(t *testing.T, db kv.DB, key, value string) {
+	t.Helper()
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		return txn.Set([]byte(key), []byte(value))
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDecoded(t *testing.T) {
+	db := cache.New(memory.NewDB(), cache.Options{})
+	set(t, db, "a", "1")
+	var d decoder
+	before := db.NewTxn(false)
+	defer before.Discard()
+	d.get(t, before, "a", "1")
+	d.get(t, before, "a", "1")
+	if d.n != 1 {
+		t.Errorf("decoded %d times, want 1", d.n)
+	}
+	other := db.NewTxn(false)
+	d.get(t, other, "a", "1")
+	other.Discard()
+	if d.n != 1 {
+		t.Errorf("decoded %d times in two transactions, want 1", d.n)
+	}
+
+	set(t, db, "a", "2")
+	// A transaction created before the commit still sees its snapshot,
+	// without using the cache.
+	d.get(t, before, "a", "1")
+	after := db.NewTxn(false)
+	defer after.Discard()
+	d.get(t, after, "a", "2")
+	d.get(t, after, "a", "2")
+	if d.n != 3 {
+		t.Errorf("decoded %d times, want 3", d.n)
+	}
+	if got, want := db.Stats(), (cache.Stats{Hits: 3, Misses: 2}); got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestUpdateTxnReadsUnderlyingDB(t *testing.T) {
+	db := cache.New(memory.NewDB(), cache.Options{})
+	set(t, db, "a", "1")
+	var d decoder
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	d.get(t, txn, "a", "1")
+	if err := txn.Set([]byte("a"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	d.get(t, txn, "a", "2")
+	if d.n != 2 {
+		t.Errorf("decoded %d times, want 2", d.n)
+	}
+}
+
+func TestBatchInvalidates(t *testing.T) {
+	db := cache.New(memory.NewDB(), cache.Options{})
+	set(t, db, "a", "1")
+	var d decoder
+	txn := db.NewTxn(false)
+	d.get(t, txn, "a", "1")
+	txn.Discard()
+	b := db.NewBatch()
+	if err := b.Set([]byte("a"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	txn = db.NewTxn(false)
+	defer txn.Discard()
+	d.get(t, txn, "a", "2")
+}
+
+func TestEviction(t *testing.T) {
+	db := cache.New(memory.NewDB(), cache.Options{Size: 2})
+	for _, k := range []string{"a", "b", "c"} {
+		set(t, db, k, k)
+	}
+	var d decoder
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	for _, k := range []string{"a", "b", "a", "c", "a", "b"} {
+		d.get(t, txn, k, k)
+	}
+	// "b" is evicted by "c", while "a" remains the most recently used.
+	if d.n != 4 {
+		t.Errorf("decoded %d times, want 4", d.n)
+	}
+}
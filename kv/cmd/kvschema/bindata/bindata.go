@@ -77,7 +77,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _templatesKvschemaGotmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xec\x58\x6d\x6f\xdb\xba\x15\xfe\x6c\xfe\x8a\x33\x7f\x18\xe4\x56\x95\xd3\x7e\xea\x5a\x64\x80\x9b\x64\x9d\xb1\xde\xe4\x22\x76\x57\x5c\x04\xc1\x40\x4b\xc7\x36\x61\x99\x54\x49\x4a\x8e\x27\xe8\xbf\x0f\x87\xa2\x5e\x6c\xe7\xe5\xf6\x02\x17\x18\xb6\xfb\x29\xb1\xc8\xf3\xfa\x3c\x7c\x74\xa8\xb2\x1c\xbf\x02\x76\xa1\xb2\xbd\x16\xab\xb5\x85\x77\x67\x6f\xff\x02\x9f\x95\x5a\xa5\x08\x5f\xbe\x5c\x30\xf6\x45\xc4\x28\x0d\x26\x90\xcb\x04\x35\xd8\x35\xc2\x24\xe3\xf1\x1a\xc1\xaf\x84\xf0\x4f\xd4\x46\x28\x09\xef\xa2\x33\x08\x68\xc3\xd0\x2f\x0d\x47\x1f\xd9\x5e\xe5\xb0\xe5\x7b\x90\xca\x42\x6e\x10\xec\x5a\x18\x58\x8a\x14\x01\x1f\x62\xcc\x2c\x08\x09\xb1\xda\x66\xa9\xe0\x32\x46\xd8\x09\xbb\x76\x41\xbc\x8b\x88\xfd\xe2\x1d\xa8\x85\xe5\x42\x02\x87\x58\x65\x7b\x50\xcb\xfe\x2e\xe0\x96\x31\x00\x80\xb5\xb5\x99\xf9\x30\x1e\xef\x76\xbb\x88\xbb\x34\x23\xa5\x57\xe3\xb4\xde\x66\xc6\x5f\xa6\x17\x57\xd7\xb3\xab\x37\xef\xa2\x33\xc6\xbe\xca\x14\x8d\x01\x8d\xdf\x73\xa1\x31\x81\xc5\x1e\x78\x96\xa5\x22\xe6\x8b\x14\x21\xe5\x3b\x50\x1a\xf8\x4a\x23\x26\x60\x15\x25\xba\xd3\xc2\x0a\xb9\x0a\xc1\xa8\xa5\xdd\x71\x8d\x2c\x11\xc6\x6a\xb1\xc8\xed\x41\x87\x9a\xb4\x84\x81\xfe\x06\x25\x81\x4b\x18\x4e\x66\x30\x9d\x0d\xe1\xd3\x64\x36\x9d\x85\xec\xdb\x74\xfe\xf7\x9b\xaf\x73\xf8\x36\xb9\xbd\x9d\x5c\xcf\xa7\x57\x33\xb8\xb9\x85\x8b\x9b\xeb\xcb\xe9\x7c\x7a\x73\x3d\x83\x9b\xbf\xc1\xe4\xfa\x17\xf8\xc7\xf4\xfa\x32\x04\x14\x76\x8d\x1a\xf0\x21\xd3\x94\xbb\xd2\x20\xa8\x77\x98\x44\x6c\x86\x78\x10\x7c\xa9\xea\x64\x4c\x86\xb1\x58\x8a\x18\x52\x2e\x57\x39\x5f\x21\xac\x54\x81\x5a\x0a\xb9\x82\x0c\xf5\x56\x18\x42\xcf\x00\x97\x09\x4b\xc5\x56\x58\x6e\xdd\xef\x93\x72\x22\xf6\x6a\x5c\x55\x8c\x95\x65\x82\x4b\x21\x11\x86\x9b\xc2\xc4\x6b\xdc\xf2\x68\xa5\x86\x55\x35\x1e\xc3\x85\x4a\x10\x56\x28\x51\x73\x5b\x77\xb4\xdd\x33\xfc\x08\x97\x37\x70\x7d\x33\x87\xab\xcb\xe9\x3c\x62\x2c\xe3\xf1\x86\xb2\x29\xcb\xe8\xe7\xfa\xdf\xe8\x9a\x6f\x91\x22\x88\x6d\xa6\xb4\x85\x80\x0d\x86\x2b\x61\xd7\xf9\x22\x8a\xd5\x76\xbc\x72\xb4\x1c\x4b\x65\xf1\xcd\x96\x67\x66\xbc\x29\x86\x6c\xc4\xd8\x78\x0c\xf3\x07\x09\x99\x56\x85\x48\xd0\x00\x4a\x2b\xac\x40\x13\x3a\x62\x29\x89\xd2\x9a\x90\xca\x03\x21\x13\x7c\x40\x03\x0b\x1e\x6f\x3c\xe0\xb0\xc1\xfd\x9b\x82\xa7\x39\x82\xb1\x4a\x63\xc4\xec\x3e\x43\xe7\xd0\x58\x9d\xc7\xb6\x84\x4d\x11\xfd\xcc\x35\xf9\x54\x12\x13\xa8\x18\x5b\xe6\x32\x86\x6b\xdc\x05\x96\x16\xe7\x0f\x72\xe4\x0c\x4a\xd0\x68\x73\x2d\xe9\x47\x79\x68\x55\xda\x10\xce\xaa\x0a\x2a\x56\x96\x9a\xcb\x15\x42\x74\xd1\x24\x37\xdf\x67\x68\xaa\xaa\x2c\x2d\x6e\xb3\x94\x5b\x84\x61\x9b\xf8\x10\x22\x5a\x41\x99\xb4\x7f\xfa\x00\x74\xfb\xaa\x8a\xfa\x30\x43\x5b\x96\xbe\x8d\x60\xd0\x1a\x87\x5f\xf7\x88\x1b\xa3\x62\xe1\xb0\x71\x27\x0d\x89\xd8\x45\xc4\xc6\x63\xe6\xd0\xd3\x1a\x4d\xa6\x64\x42\xdc\x68\x9a\xc5\x35\x42\x9e\x25\x64\x14\xd5\x95\x07\x06\x5c\xcd\xfd\x68\x01\x52\x2b\xae\xa8\xf5\xfb\x10\x0a\x28\x4b\xb1\x84\xe8\x52\x68\x8c\xed\x95\x8c\x55\x82\xda\x55\x90\x1a\xac\xaa\x57\x6d\x45\xde\x7a\x04\xa8\xb5\xd2\x50\xb2\xc1\x06\xf7\xf0\xe1\x1c\xb6\x7c\x83\x01\xf5\x50\xe3\x52\x3c\x84\xf0\xfe\xf5\xbb\xd7\xef\x47\x6c\x60\xba\xae\x46\xb5\xdf\x89\x0d\x36\xb8\x1f\xb1\x01\x11\xc9\xed\xae\x7d\x1e\x2c\xdf\xbd\xff\x70\x3f\x62\x03\x3c\x7c\xf8\xf6\xcc\x3d\x2d\x4b\xa0\x64\xa7\xbe\xe0\xaa\x2a\xb8\x06\x95\x26\x5d\xe3\xd8\x40\x2c\x29\x45\xca\xcc\x44\x9f\xd1\x99\x87\xb4\x27\xba\x44\x72\x38\xfa\xe8\x96\xff\x74\x0e\x52\xa4\x54\xc6\xc0\x53\x01\xb5\x66\x83\x23\xfb\x59\x63\x5f\xf8\x74\x82\xd1\x8b\xf6\xe3\x31\x4c\x20\x73\xe5\xc1\x22\x5f\x2e\x51\xbb\x03\xce\xd3\xb4\x06\x8a\x78\x6c\x22\x36\xf0\x5b\x3e\x9c\x13\x1c\x17\x4a\xc6\xdc\x7e\xda\x5b\x9c\x91\x04\x9a\x3a\xaa\x5b\xf0\xbc\x09\xce\x46\x5d\x0e\x6c\xd0\x42\xd8\x3d\x9f\xd8\xa0\xf6\xd9\x74\x8b\x9a\x83\xa6\x43\xdb\xb9\x2e\x4b\xf0\xb4\xee\xba\xc8\x5c\xd6\x5f\x1d\x75\x7a\x1c\x74\xe9\x3e\x83\x56\x13\xad\x46\x8c\x8a\xfc\x57\x08\xa2\xa0\x92\xea\x10\xd4\xf5\xb2\x8c\x7e\x42\xbb\x56\x89\x67\xdf\xc8\xf5\x6c\xf3\x54\xdd\x99\x67\x91\x28\xfa\xd5\x9e\x82\x1a\x02\x9a\x27\x11\x3d\x80\x84\x30\x71\xf6\x26\xba\xc5\xad\x2a\x30\xc0\x3a\x87\x53\xa4\x9d\xd3\xa7\x81\x3e\xf2\xeb\x1c\x57\x0e\xf3\x47\x6a\x2f\xfe\xab\x2a\x9f\x4a\x83\xda\xfe\x0e\x95\xfb\xe7\x52\xa4\x65\x09\x28\x13\x20\xad\x00\x12\x0f\xa8\x2a\xbf\xf8\xf8\x39\x6a\xf7\xb3\xca\xbd\x19\x2e\x31\x45\x8b\x1d\xfb\x12\xf7\xfb\x45\x5d\xfc\xad\x92\x78\x14\xae\xaf\x8a\xff\x5f\x1a\x57\x37\xc2\x65\xfd\x92\xd9\x1f\x92\xf5\xbf\x23\x59\xbf\xee\xe0\xf6\xc8\x71\x7c\x5e\x3f\xf7\x27\x98\xda\xe0\x57\x1f\xd6\xe9\x12\xa4\xea\x6d\x5c\x73\x03\x0b\x44\x49\xe3\x72\x2a\x62\x61\xd3\x3d\x0d\x45\xee\xc5\x89\xf5\x44\x78\x10\x6e\x27\xd2\xd4\xc7\x24\x77\x14\x55\xa3\xc9\x53\x4b\xd7\x8d\x84\x5a\x4c\x22\xc0\x7b\x11\x96\x5a\x6d\x69\xa6\xc7\x6d\x66\xf7\x60\x08\x39\xda\xbb\xd8\x5b\x34\x47\xca\xf0\xf9\x89\x61\x69\x04\x41\xfb\x3c\xac\x25\xc2\xa1\x42\x9c\x2d\xfa\xa7\xb3\x30\xe1\x01\xf4\xed\x92\x23\x4c\x70\x77\xdf\xba\x2c\xb1\x1a\xb9\xd3\x98\xa2\x0c\x0a\x33\x82\xbf\x9e\xc3\x5b\x07\x5c\x01\xe7\x50\x98\xbb\xb3\xfb\x3e\x58\x85\xf3\xfb\x48\xff\x9d\xe3\x16\x84\x83\xba\xa9\x83\x3c\x5e\xd7\xb3\xf6\x9e\xee\x46\x54\xf0\x8f\xc3\x40\xbd\xf3\x33\x23\xc1\xd1\x6b\x39\x81\x41\xde\x16\x78\x10\xd9\xae\xb9\xed\x3c\x3a\x50\x30\xf9\xad\x38\xd4\x9d\x43\x03\xbd\xe6\x8d\x20\xb8\xbb\x7f\x14\x11\x9f\x58\x23\xdc\x07\xbb\xa8\xd3\x68\x9c\x1e\xfd\x9e\xda\x4e\x2d\x13\x21\x60\xa7\x2c\x68\x1c\xb0\x8f\x8b\xfe\xe0\x54\xce\x83\x3f\xd7\x65\xdc\x89\xfb\x51\x23\x1b\x9d\xac\x3c\x22\x1d\x52\xa4\x61\xa7\x1f\x1d\x6b\x6a\x37\x21\xad\x7b\xea\x4c\xd2\xb4\xed\xc8\x95\xbf\x83\x1d\x1c\xe1\xa5\xd0\xc6\x82\x6c\x2f\x68\x0d\x98\xc5\x01\xc4\x21\x2c\x70\x25\x24\xdd\x4f\xc9\x6b\xfb\x45\xa0\xb6\xf6\x84\x5b\x69\xe4\xd6\x5d\x4f\xb9\xa4\x5b\x30\x7e\xcf\x79\x4a\x97\x99\x57\xc6\x72\x6d\x1b\x2a\x4e\x5c\x39\xee\x11\xd4\x97\x3c\x77\xc6\x17\x08\x42\x5a\xd4\x99\x46\x52\x11\x4e\xe4\xce\x94\x7b\x44\x3e\xfe\x8d\x5a\x75\x1e\x6a\x3b\xb5\x04\x09\xee\x7b\xc1\x49\x48\xda\x7e\xea\xd7\x3b\xa6\xcc\x53\xae\x57\x68\x2c\xb9\xcb\x94\x31\x62\x91\x62\xed\xf5\x88\x9a\x8f\x35\x30\xa8\x93\x7f\xd5\xbb\x5c\x49\x0a\x32\x82\x23\xde\xd6\xe2\xd0\x67\xab\x17\xdb\x49\x9a\xb6\xef\xce\xd6\xeb\x11\xd7\xc2\xba\x47\x21\xc8\x11\xab\xda\x1b\xaa\x7f\x2f\x56\x35\xbc\x8d\xed\x4f\xdc\xc6\x6b\x21\x57\x65\xd9\xbd\x93\x6b\x2f\xa7\xc2\xdd\x22\xed\x50\x3c\xb5\xa8\xdb\xe0\x89\xe0\x33\xe6\xb0\xf5\x11\xc8\x80\x2e\xc7\x57\x0f\x99\x6e\xc4\xd6\xae\x51\x68\x38\x7a\x97\xc2\xd6\xfd\x68\x30\x9b\xaf\x1b\xd5\xc2\x04\x7a\x6f\x7c\x10\x06\x78\xaa\x91\x27\x7b\x30\x4a\x9f\x4e\x6f\x3f\x50\x62\x50\x1c\x66\x37\x82\xe0\x70\xbc\xe8\x2b\xc7\x73\x9a\xf0\xfa\xdd\x8b\xaa\xd0\xe6\xf0\x92\x3c\x3c\x3e\x0b\x1d\x4c\x81\xcf\x48\x8c\x1f\x5f\x28\xd9\x73\xe0\x59\x86\x32\x39\x1e\xaf\xa3\x28\x7a\x6a\x9c\x6a\x19\x87\x26\xec\x0b\x4e\x37\x9d\xb0\x43\x1e\x7d\xda\xff\x30\x83\xc8\xfc\x69\x12\x29\x9d\xa0\xff\x8e\xe7\x07\x86\x1e\x79\xfc\x9e\x8e\x43\xde\xd7\x33\x34\xba\x45\xee\x5e\xf8\x4e\x8e\x0c\x70\x0b\x71\xae\x8d\xd2\xf5\xab\x0a\x65\x62\x60\xb7\x46\x59\x1f\x71\x94\x2b\xbb\x6e\x3e\x4b\x1e\x91\x8f\x9c\x99\x86\x80\x9d\x86\xc8\x08\xbe\x91\xbd\xf6\x71\x84\x71\x5f\x49\xdd\xd7\x50\xb4\x18\xfa\x70\xf4\xdc\x5f\x36\xc0\xe4\xf1\xba\x1e\x4d\xb8\x85\xdc\x38\x2b\xf7\x09\x95\x83\xc9\x17\xf8\x3d\x47\x69\x21\xe6\xa9\xd3\x25\xd7\xe0\x66\xb4\x51\x79\x9a\x34\x27\x4c\xe2\x83\x05\x37\xe2\x34\xdd\x7d\xe2\x1c\x3c\x0b\x51\xe0\xd3\x23\x69\x72\x4a\x71\xe1\xbb\xf3\x83\xfa\xd4\x05\x6b\x23\x39\x77\xc1\x53\xcc\x0f\xe1\x44\xbd\x1a\x60\x6a\xf9\xaa\x3f\x93\x35\x7f\xff\x13\x00\x00\xff\xff\x38\xc7\x5a\x59\xe3\x16\x00\x00")
+var _templatesKvschemaGotmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xec\x5b\xff\x6f\xdc\xb6\x92\xff\x79\xf5\x57\x4c\x8d\x43\x4e\x9b\xaa\x72\xda\x9f\x7a\x2e\x7c\x80\xeb\xf8\xb5\x46\xf3\x92\x9e\xed\xb6\x78\x08\x82\x03\x57\x9a\xdd\x25\x56\x2b\x6e\x49\xae\xec\x3d\x41\xff\xfb\x61\x48\x8a\xa2\xb4\x5f\xdd\xe4\x35\xc5\xc3\xfb\x29\xb1\x44\x0d\x39\x33\x9f\xf9\xc2\x99\xd9\xba\x3e\x7f\x09\xd1\xb5\x58\x6d\x24\x9f\xcd\x35\x7c\xf3\xea\xeb\xff\x82\x1f\x84\x98\x15\x08\x6f\xde\x5c\x47\xd1\x1b\x9e\x61\xa9\x30\x87\x75\x99\xa3\x04\x3d\x47\xb8\x5a\xb1\x6c\x8e\xe0\xde\x24\xf0\x2b\x4a\xc5\x45\x09\xdf\xa4\xaf\x20\xa6\x05\x67\xee\xd5\xd9\xf8\xbb\x68\x23\xd6\xb0\x64\x1b\x28\x85\x86\xb5\x42\xd0\x73\xae\x60\xca\x0b\x04\x7c\xca\x70\xa5\x81\x97\x90\x89\xe5\xaa\xe0\xac\xcc\x10\x1e\xb9\x9e\x83\xee\xa8\xa7\xd1\x3f\x1c\x01\x31\xd1\x8c\x97\xc0\x20\x13\xab\x0d\x88\x69\xb8\x0a\x98\x8e\x22\x00\x80\xb9\xd6\x2b\x75\x71\x7e\xfe\xf8\xf8\x98\x32\x73\xcc\x54\xc8\xd9\x79\x61\x97\xa9\xf3\x37\xb7\xd7\x37\x6f\xef\x6f\xbe\xfa\x26\x7d\x15\x45\xbf\x94\x05\x2a\x05\x12\x7f\x5f\x73\x89\x39\x4c\x36\xc0\x56\xab\x82\x67\x6c\x52\x20\x14\xec\x11\x84\x04\x36\x93\x88\x39\x68\x41\x07\x7d\x94\x5c\xf3\x72\x96\x80\x12\x53\xfd\xc8\x24\x46\x39\x57\x5a\xf2\xc9\x5a\xf7\x24\xd4\x1e\x8b\x2b\x08\x17\x88\x12\x58\x09\x67\x57\xf7\x70\x7b\x7f\x06\xdf\x5f\xdd\xdf\xde\x27\xd1\x6f\xb7\x0f\x3f\xbe\xfb\xe5\x01\x7e\xbb\xba\xbb\xbb\x7a\xfb\x70\x7b\x73\x0f\xef\xee\xe0\xfa\xdd\xdb\xd7\xb7\x0f\xb7\xef\xde\xde\xc3\xbb\xbf\xc1\xd5\xdb\x7f\xc0\x4f\xb7\x6f\x5f\x27\x80\x5c\xcf\x51\x02\x3e\xad\x24\x9d\x5d\x48\xe0\x24\x3b\xcc\xd3\xe8\x1e\xb1\xb7\xf9\x54\x58\x75\xa9\x15\x66\x7c\xca\x33\x28\x58\x39\x5b\xb3\x19\xc2\x4c\x54\x28\x4b\x5e\xce\x60\x85\x72\xc9\x15\x69\x4f\x01\x2b\xf3\xa8\xe0\x4b\xae\x99\x36\x7f\x6f\xb1\x93\x46\x2f\xcf\x9b\x26\x8a\xea\x3a\xc7\x29\x2f\x11\xce\x16\x95\xca\xe6\xb8\x64\xe9\x4c\x9c\x35\xcd\xf9\x39\x5c\x8b\x1c\x61\x86\x25\x4a\x46\x0c\x4f\x36\xdd\x9a\xb3\xef\xe0\xf5\x3b\x78\xfb\xee\x01\x6e\x5e\xdf\x3e\xa4\x51\xb4\x62\xd9\x82\x4e\x53\xd7\xe9\xcf\xf6\xbf\xe9\x5b\xb6\x44\xda\x81\x2f\x57\x42\x6a\x88\xa3\xd1\xd9\x8c\xeb\xf9\x7a\x92\x66\x62\x79\x3e\x33\xb0\x3c\x2f\x85\xc6\xaf\x96\x6c\xa5\xce\x17\xd5\x59\x34\x8e\xa2\xf3\x73\x78\x78\x2a\x61\x25\x45\xc5\x73\x54\x80\xa5\xe6\x9a\xa3\x4a\x0c\xb0\x44\x89\xa5\x56\x09\xb1\x07\xbc\xcc\xf1\x09\x15\x4c\x58\xb6\x70\x0a\x87\x05\x6e\xbe\xaa\x58\xb1\x46\x50\x5a\x48\x4c\x23\xbd\x59\xa1\x21\xa8\xb4\x5c\x67\xba\x86\x45\x95\xfe\xcc\x24\xd1\x14\x25\xe6\xd0\x44\xd1\x74\x5d\x66\xf0\x16\x1f\x63\x4d\x2f\x1f\x9e\xca\xb1\xf9\xa0\x06\x89\x7a\x2d\x4b\xfa\xa3\xee\x7f\x55\xeb\x04\x5e\x35\x0d\x34\x51\x5d\x4b\x56\xce\x10\xd2\xeb\xf6\x70\x0f\x9b\x15\xaa\xa6\xa9\x6b\x8d\xcb\x55\xc1\x34\xc2\x99\x3f\xf8\x19\xa4\xf4\x06\xcb\xbc\x69\x88\xd1\xd7\x58\xa0\xc6\x1b\xe2\x70\x03\xb9\xf9\x43\x01\x56\x28\x37\x1d\xb3\xc0\x94\x12\x19\x37\x1a\x30\xf6\x84\x69\x74\x7e\x4e\x5f\xdf\x38\xc9\x80\x9e\x33\x0d\x12\xa7\x84\x57\x01\x84\x1b\x29\xd6\xb3\x39\x30\xfb\x10\xc9\x14\x99\x44\xb7\x43\x0e\x53\x2e\x95\x06\x6e\x8c\x8e\x08\x75\xab\x32\xa6\x32\x96\xa3\x4a\xe1\xd6\xbc\x0d\x5e\x49\x24\xe8\x67\x5a\x59\x32\xe4\x22\x78\xa9\x34\xb2\x3c\xd9\x62\xc5\x0a\x4e\x01\x83\x97\x8b\x2a\xbd\x6b\x69\xdc\x48\x29\xa4\xd5\x1d\x2b\x37\x90\xcd\x49\x74\x0a\x58\x21\x91\xe5\x1b\x58\xb2\x1c\x41\x0b\xa2\x66\xb6\x40\x10\xd6\x3c\x3a\x3e\x2d\x5f\xf6\x94\x84\x78\x7f\x3c\x05\x6a\x2e\xd6\x45\x0e\x13\x24\x23\xcd\x98\xcc\xc9\x8a\x8c\x6a\x63\x45\x2a\x1c\xf7\x8e\x18\x23\xe9\xda\x1e\x77\x0c\x48\x07\x83\x3a\xaa\xeb\xaf\x48\x2c\xe9\xb5\x13\x03\x34\x4d\x34\xaa\x98\x6c\xe5\x02\xef\x3f\xf8\xaf\xea\x1a\xb0\xcc\xa1\x69\x0e\x40\xc0\xbd\xb8\xb5\x40\xa5\xa5\x86\xfc\x1d\x4e\x0d\x65\x3e\x05\xc2\x35\x4a\x09\x17\x97\xa0\xd2\x56\xa1\x7f\x67\x3a\x9b\xf3\x72\x56\xd7\x1d\x4d\x6b\x4c\x75\xed\xac\x2a\xc6\xf1\x77\x74\x6c\xf8\xe2\x12\x4a\x5e\x40\x1d\x8d\x46\x0e\xae\x28\x65\x34\x6a\x00\x0b\x85\xb4\x5b\x81\x65\x8c\x6a\x0c\xff\x0d\xaf\xcc\xaa\xba\x0e\x59\x84\xa6\x69\x79\xbb\x24\x7f\x89\x65\x1e\xbb\x07\x09\xa0\x4a\xd3\x74\x4c\x8c\x12\xad\xa6\x71\x1b\xbc\xd8\xd2\x69\xed\x8f\x79\x01\xe1\xa1\x7f\x96\x38\xe5\x4f\xf6\xc4\x09\xf8\x6f\xcc\xa2\xfe\x3b\xc3\xfa\xe6\x02\xd0\x2d\x93\x28\x2f\x00\xd5\xfb\x57\x1f\x1a\x2f\xe8\x68\xd4\xfd\xdf\x19\x91\xff\x67\x8f\x0a\xa2\x11\x9f\x76\xf2\xb5\x00\x38\x5d\x86\x9e\xfa\x36\x28\xc8\x1d\xff\x6f\x02\x19\x11\xb6\x7b\xb7\x62\x24\x19\x6f\x6f\xea\x50\x97\x6d\xef\xd8\xdb\x72\xd4\x63\x32\x6a\xdf\x95\xbc\x88\x1a\xe3\x1a\x3d\x83\xd7\xc6\x78\x80\x93\x91\x59\x43\x22\xdb\x67\x81\xdf\x10\x53\x8a\x4f\xc6\x7d\x6e\x12\xc8\x31\x13\x39\xe6\x30\x95\x62\x09\x8c\x48\x2d\xaa\xd4\x11\x99\x6c\xe0\xb5\x79\x6d\xff\x76\x4e\x73\xb8\x95\x75\xa0\x24\x25\xef\x09\x3b\x1b\x8a\x46\x7e\x39\x3d\xf4\x7f\x44\x23\xe7\x12\x00\x82\xc5\xd1\xc8\x7b\x8c\x9c\x58\xd0\x72\x8d\xce\x1f\x05\x0c\x3c\x32\xe7\x6b\xc8\x92\x47\xed\xf2\x89\x10\x85\x25\xf0\xab\xf1\xf3\x2b\xc1\x4b\xad\x2c\xf3\x25\x3e\x82\xf5\xfe\x62\x40\xec\x3f\x15\x10\x53\x89\x89\xb1\xca\xa8\x9b\x4f\x0d\x95\x43\x7b\xda\x1d\x78\xa9\x51\x4e\x59\x86\x75\xe3\xb4\x10\x4a\xcb\x49\x76\xbf\x1e\x8c\xc4\xb3\x04\xd4\x3a\x9b\x03\xeb\xd6\x91\x0e\x24\x66\xc8\x2b\xaf\x16\x12\xd1\xfd\x7a\xa2\x32\xc9\x57\x14\x69\x5a\x4f\xdf\xdb\xaf\x75\xae\x53\xe6\x2c\x3c\x73\x04\xad\x57\xb5\x3e\x73\x81\x9b\x6e\x47\x62\x71\x81\x26\xcd\x62\x25\xd1\x33\x91\x93\xfc\xaa\xdc\x38\x1f\x19\xee\x10\x67\x1d\x36\xc6\x10\x7b\x5d\xda\x27\x89\xd1\x80\xf1\x5a\x42\x8e\x09\x0e\xab\x20\x34\x27\x64\xc1\x62\x41\xd0\x27\x5e\x56\x05\xd7\xfe\xfb\x9f\x70\x13\x67\xe9\x4f\xb8\x19\x1b\xb3\xfc\x42\x2c\x42\x93\x1b\x6c\x53\x37\x89\xe5\x30\x21\x65\x45\x64\x18\x99\xb1\xb6\xe1\x3a\x0f\xc6\x0b\x58\x25\x1d\x95\x8b\xf0\x4c\x81\x77\x71\x30\xba\x80\xcc\x59\x66\xde\x44\x23\xf5\xc8\x75\x36\xef\xbe\x70\xa1\x60\xaf\x53\xc9\x98\xc2\xa1\x0f\xbb\xb0\x76\xff\x85\xa7\x6b\x98\x33\xd1\xa3\x02\xef\x73\xa2\x51\xe8\x1d\x9c\x67\xf9\x9b\x90\x4b\xa6\xe9\x93\xa6\x59\x54\xa9\xd5\x85\x7f\x18\x67\xa9\xc1\x61\x02\x2f\xaa\xc0\x19\xb7\xeb\xda\xd7\x63\xef\x36\xb6\x5d\x4c\x2b\xe3\x2c\x4b\x8c\xad\x25\xce\xdb\x90\x54\x47\xa3\xcc\x51\x80\x4b\x78\x51\x45\x23\xef\xf7\xa2\x51\x8e\x53\xb6\x2e\xf4\xc5\x73\xb4\xb4\xb5\x15\xa9\x8f\x12\x25\x4b\x33\xc8\x37\xbd\xb8\xcf\x6c\x36\x74\x8f\xda\xcb\x09\x14\x92\x51\xcf\xb1\x13\xdd\x76\x22\x44\x96\x56\xb5\x36\x72\x2d\xa4\x44\xb5\x12\xa5\x49\x0c\xda\xdc\x90\xd2\x9e\xf5\x2a\xa7\xec\x29\xad\x6b\xe7\xaa\xdb\x80\x0c\xde\xb7\xff\x52\xf2\xdf\xd7\x14\xe2\x1c\xb1\x5b\xf2\x9d\x41\xf6\xb1\xf1\x49\xca\xdc\x58\xf0\x76\x64\x86\xc7\xb9\xb0\xa0\xf8\x3b\xea\xb9\xc8\x9d\xba\xcf\xcf\x61\x69\xfe\x76\xc9\x24\x25\xe6\xfe\xbe\xa3\xd8\x12\xad\xbf\x52\x89\xe3\x7e\x48\xd5\x7f\x45\x2c\x52\x32\x65\x4f\x6a\x18\x30\xa1\xd7\xdc\xb1\xc4\x5a\xc3\x92\x2d\x88\xf1\x20\xb1\x4a\x3d\x22\x1c\x97\x6f\xb8\xd2\x3d\x1e\x2b\x9b\xdf\x39\xef\x99\xf3\xa9\x09\xd0\xba\xe5\x59\xcf\x59\x09\x13\x9c\x0a\xd9\xe5\x94\x5c\xab\xfd\x4c\x26\x60\x6e\x49\x12\x97\xc2\x3b\x35\xa7\x43\xda\xbb\x69\x5a\xce\xdd\x06\xdc\xa5\xad\x74\x5f\xa3\x23\x94\x39\xd1\x62\x39\x05\x2a\x2d\xdc\xca\xbc\xfd\xe8\x18\x15\xc3\x46\x29\x1e\x7b\x7c\xbb\x48\xda\x4b\x04\x43\xa0\x85\x89\x60\x62\x2c\x95\xf0\xf0\x9a\x4b\xcc\xf4\x4d\x49\x06\x26\x89\x0e\x19\x5d\xd3\xbc\x74\x28\xf6\x5f\x77\xa9\xe3\x88\x1c\xec\xc5\x25\xa9\x01\x63\xba\x2d\x18\xd7\x90\xc0\xb7\x5f\x7e\xf3\xe5\xb7\xe3\x68\xa4\xba\xfb\x43\x6a\xe9\x5e\xe9\x78\x61\x7c\xe1\xc0\x91\xf4\x5e\xbf\xff\xf6\xe2\xc3\x38\x1a\x61\xff\xe1\xd7\xaf\xcc\xd3\x1d\xee\x63\xd2\xe5\x93\x26\xdc\xf6\x3d\x49\x65\x0d\xdd\x3e\x88\xc7\x09\x54\x63\x9f\x21\x05\xfe\xc2\x99\xb0\xf1\x11\x4d\x34\x0a\xc5\xc9\xa7\x03\xeb\x39\x6a\x51\x2e\x53\xe2\x55\x97\x2a\xd5\x35\xd8\x83\x28\x38\xab\xce\xa0\x69\xb6\x92\xa6\xeb\x39\x66\x8b\x00\xea\x71\x68\x1a\xa1\xb0\x92\xed\x54\x12\x13\xe0\x2d\xeb\xf1\xf8\x94\x84\xab\xcf\xa2\x45\x0c\xf9\x6d\x51\xe4\xa1\xe7\x0e\x0f\xf8\x03\x1a\x4d\x24\x7b\x5c\xb8\xff\xd3\xfa\x68\x19\xbf\x10\x45\x1e\x38\x6f\x51\xe4\xce\x7d\xfb\x0d\xc7\xdf\x1d\x51\x43\xb8\xfd\xfd\x81\xed\x27\x2a\x0c\x12\xad\x1c\x4e\xdd\xc7\x11\xbc\xa7\x22\x4b\xa7\x54\x93\x29\x5d\xc1\xca\x48\x1e\x26\x6b\x72\x14\x40\x9a\x65\x45\xe1\xb2\x89\x05\x6e\x54\x1a\x8d\xdc\x12\x1b\xff\xaf\x45\x99\x31\xfd\xfd\x46\xa3\xa1\xa7\xec\x99\xc3\xfc\x30\x7e\x35\xee\x54\x15\x8d\xbc\x29\x76\xcf\xaf\x74\x6c\x69\xb6\xa8\x27\xcd\xa0\xea\xac\xd6\x90\xf6\xec\xed\x82\xa4\x4d\x17\x7f\x31\x61\x20\x88\x27\xe6\xdc\x2d\xbf\x19\x2b\x4c\xa1\x28\x48\xee\xf7\x41\x56\x14\xf9\x4e\xd0\xde\x19\xb7\xd7\x52\x32\xdb\xdf\x50\x8a\xf5\x1c\xf0\x06\xc8\x4d\x60\xc7\xfd\x64\x17\x78\xff\x90\x85\xdd\x96\x0a\xa5\xfe\xb3\x0f\xdb\x21\xf3\x80\xdb\x6b\xd5\x6d\x5d\xdf\x33\x94\xb1\xd8\x07\xbb\x95\x73\xc6\xc1\x81\xc7\x03\x79\x18\x83\x36\x57\x5e\x6b\x97\x27\x70\x63\xbe\x6f\xd5\x1e\xa3\xc9\x85\xfb\x44\xef\x3d\x51\xbf\xed\x36\xd9\x3e\xdd\x51\xf3\x7c\xbd\x7e\x1e\xbe\x2d\x82\x3e\x39\xdf\xfb\x92\x96\x81\x0d\xbb\x4c\xa0\xe0\x4a\x2b\xe3\x9a\x25\x4e\x13\x10\x45\x7e\x87\x53\xe2\xac\x4a\x07\x59\x0a\x85\x3b\x72\xbb\x5b\x8f\xbf\xa3\xec\x81\x18\x76\x1f\x0f\x2d\xc5\x66\x33\x7e\x4b\x6b\xd2\x76\xed\x69\x26\xda\xa3\xc6\xf2\x7c\x40\x4a\x9e\x4a\xa7\x0b\x4e\x3b\x2b\x04\x9d\x6d\xb9\x67\x1f\x17\x26\xba\x3d\xda\x3b\x6f\xaf\x78\xe2\x6e\xc9\x47\x73\xf4\x4f\x92\x9e\xf7\x13\xd7\xe7\xe5\x98\xa6\x38\x4a\x0b\xdc\xb5\xde\x26\xdc\xbe\xec\xe9\x52\xcf\x03\xe9\xad\xcb\x6d\x8f\x66\x95\x5b\xd5\xa5\x5d\x15\xc6\xcf\x98\x26\x76\x52\xfd\x6b\xe7\x36\x56\x8e\x74\x82\x23\x9f\xed\x4b\x55\xfe\x9d\x82\xfc\xc9\x29\xc8\xbf\xa3\xfa\xc9\x51\xfd\x8f\x46\xb7\xa3\xd1\x68\x57\xb8\xdb\xc1\x6e\x78\xaa\xce\x91\x9d\x1a\x4f\x02\xd3\xec\x3e\xb1\xe1\xe1\x87\xb0\x78\xd3\x56\x2d\x4e\x8c\x0d\xb7\x53\x28\x45\xb0\x90\x6a\x2c\x13\xc4\x92\x1a\xa3\x05\xcf\xb8\x2e\x36\x54\x0f\x32\x17\x0e\xb4\xfd\xa3\xde\x76\x8f\xbc\x28\xdc\x9e\x91\xab\xe8\x4a\x54\xeb\xc2\xd4\xc0\x4d\x79\x96\x62\x0e\x0b\x76\x30\x95\x09\xaa\x8e\x2f\x57\x7a\x03\x8a\x3c\x03\xad\x9d\x6c\x34\xaa\x41\xd7\xe8\x87\x3d\xc5\x82\x31\xc4\xfe\x79\x58\x0d\xdd\x2a\xf8\x55\x61\x8b\x27\xa4\x66\x60\x1c\x87\x2d\x25\x6c\xec\x7d\x9c\x7a\x36\x15\xf5\x6c\x2e\xe1\x6b\xa2\x39\xaa\xe0\x12\x2a\xea\x86\x84\x25\xb6\xca\xd0\xdd\x21\x7f\x43\xd8\x2b\xa1\xc7\x37\x49\x90\x65\x73\x5f\x3a\x29\x09\xeb\x7f\x40\x0d\x41\x67\x81\xd4\x11\x88\x9c\x94\x41\x5a\x98\x60\x4f\xe2\x26\x0c\x7b\x8a\xfd\x6e\xc4\xb3\xf5\x60\x18\x8c\x51\x85\xfd\xb8\x31\xc4\xef\x3f\xec\xd4\x88\x3b\x58\x1b\x75\x7b\xab\x5c\x77\x6c\xfc\x4f\x0e\xcc\x84\x5c\x4e\xd5\x31\xef\xed\x50\x19\xc5\xee\x8e\xd8\xa3\x2a\xac\xe2\xfc\x80\xda\x7a\xaf\x3c\x0e\x4e\x81\x79\xfa\xf0\x54\x26\xd4\xf1\x6e\xbb\x3b\x9e\xaf\xc0\x0d\x86\x3e\xc9\xe1\xa6\xe4\x45\x5b\x00\x26\x7f\x67\xc5\xf3\x9e\x7f\x20\x8c\xa5\x1d\xa8\xc7\x21\xd6\xec\xa2\xb6\x98\x4b\xfa\x1d\x6c\xe9\xfe\xee\xc1\x2d\x31\x50\x59\x2b\x37\x08\xd2\x63\xc5\x69\x77\x40\x25\x9e\x90\x4e\xc9\x0c\xc7\x10\x07\x7d\x97\x83\x06\xe6\x24\xb5\x3b\x5b\x19\x16\xd3\x27\x6a\x5f\x1d\x7d\xa2\x82\x9c\x77\xa7\x8d\x5d\x15\x85\xdf\xb6\xed\xd5\x7a\x33\x23\x13\xb0\x8d\x75\x67\x1a\xbe\x33\x3f\x67\x55\xcf\x16\x12\x98\xe0\x8c\x97\x34\xb2\x41\x82\xf4\x43\x32\xf6\x6b\x67\x99\x33\x89\x4c\x53\x43\x9f\x6a\xb0\x64\xb5\xbf\xaf\x59\x41\x05\xce\x97\x4a\x33\xa9\x5b\x9b\xbd\x22\x8d\x80\x79\xe4\x3a\x5f\x64\x7f\xd4\x05\x37\xd2\x5b\x49\xea\x70\x50\x0f\x88\xd9\x46\x19\x51\x14\xf0\x7f\x28\x45\x47\xc1\x77\xcc\x4a\x30\x23\x34\x5b\x5b\xd2\xf2\x6d\xba\x8e\x30\xf1\x5d\x30\x39\x43\xa5\x89\xdc\x4a\x28\xc5\x29\x8b\x31\x54\x07\x36\xbc\x4b\x80\xb1\x3d\xfc\x4b\x6f\xc8\x09\xd0\x64\x81\x1e\xc3\xc0\xc0\x0d\x06\x7a\x66\xed\xa2\xd2\x55\x51\xf8\xdc\xc6\x53\x1d\x18\x65\x62\x65\x94\x40\x39\x3e\xa0\xcc\x3b\x9a\xbd\x50\x78\xb2\x4e\x89\x61\x4f\x84\x46\x8a\x72\x54\x19\xda\x2b\x8e\x90\x39\xca\x40\xd5\x9d\x9e\xad\x6a\x3b\x55\x7b\xa1\x13\xb9\x23\xea\x35\x0d\xcc\x2d\x5d\x26\xa7\x68\x9d\xe8\x31\xa7\xec\x1e\xba\xa8\x65\x60\xb8\xdb\xa4\xf0\xdb\x1c\x4b\xb7\x1f\x57\x66\xcc\xcb\x78\x8b\x97\xfe\x91\xbb\xad\x11\x31\xd3\x5e\x34\x08\x5f\x2b\x62\x90\x9b\xf1\x2f\x06\x6a\x3d\x51\xf8\xfb\x9a\x7a\x9f\x19\xd5\x07\xb5\x38\x28\xec\x47\x9a\xdb\x20\x7a\x4e\xa1\x24\xa2\x12\x9f\x42\x99\xff\x55\xb0\xea\x8e\xfc\xcf\x81\x6c\x4b\xfc\x28\x72\x7f\x64\xca\x9f\xcc\x43\x95\xc1\xef\x6b\x9a\x1b\x5a\xba\xd9\x11\x37\x46\xe4\x20\xe6\x30\xdb\xf7\xce\x24\xd1\x9e\x83\xfe\x1f\xa2\xd0\x1e\x67\x20\x8e\x70\xd3\x78\x4c\x69\x90\x59\x1d\x70\xb5\xa8\xd2\x1f\x99\xf2\x6c\x0d\xf9\x18\x47\xfb\x07\x62\x1e\x48\xdd\x74\x49\x32\x5d\x40\x26\xb3\xf9\x81\xd1\x17\xcf\xf2\x7a\x45\xbe\x2c\xb0\x4c\x62\xc2\x99\xe4\xe0\x63\xd7\x65\xdb\xdd\xa4\x73\x17\x7c\x4f\xf7\x51\xc8\x9c\xb0\xc2\x0c\x48\x8c\x40\x8d\x7f\xb6\xcf\x79\x69\x25\x4d\xa6\xad\xa8\xed\xa6\xb3\x39\xd2\x10\xa4\x54\xfa\x73\xe0\xf4\xa8\xbc\x62\x73\x5c\xa0\xe9\xad\x72\xd6\x21\xd5\xc0\xf4\x01\x9f\xb4\x99\x36\x4a\x76\xa1\xd4\x92\xa6\x35\xcf\x6e\xc2\x38\x11\x11\x66\xbb\x50\x1b\x85\x53\x6b\x27\x0c\x39\x79\x8d\xf4\x34\x7c\x40\xbd\x6e\x10\xce\x1c\x9f\x75\xa6\x50\xd7\xe9\xc3\x66\x85\x37\x4f\x2b\xd9\xe6\xfe\x7a\x8e\x5c\xee\xab\xf4\x38\x2d\x3e\xcc\xdb\x48\x80\xb9\x1b\x21\x30\x89\x27\xf0\x6e\x66\x4d\x09\xa9\xb7\x06\xcd\x9e\xc1\x62\x5c\xf5\x4f\x37\x86\xd8\x7b\x0f\xb3\x59\xa8\x98\xed\x42\x81\x57\xd5\x70\xcb\x5e\x09\xe0\x39\x8a\x0b\xef\xd2\x81\xea\x0e\x65\xc7\x5f\x7e\x73\x34\x3f\xde\xb9\xfb\xae\x44\x79\x77\xf5\xa5\x57\xcc\x4a\xf7\xd3\x70\xc5\x05\x3a\xac\x9f\x66\x33\xf9\x71\xc7\x15\x4d\xb4\xed\x29\xe0\x78\xe4\xd3\x50\x5e\x50\x06\xeb\xaa\x07\x51\x70\x53\x26\x2c\x9f\xa0\xe0\xfd\x0e\xda\xd7\x28\x39\x2a\x22\x66\xc0\xfb\x0c\xe8\xd8\xc0\xe9\xe8\xf7\x53\x6d\x22\x77\xc8\x99\xff\x21\x60\xee\x76\xf9\x1d\xa9\x21\x36\xdb\xff\x76\x20\xf2\x7b\x79\x29\x7e\x34\x3c\xfb\xc3\x1b\xc4\xf7\x1b\x21\x16\xeb\xd5\x29\x1a\x09\x8a\xc4\x24\xb4\x9d\x63\x1d\x44\xd1\x38\x97\x76\xc8\xb6\x55\xe6\x21\xe7\xb2\xbf\x88\x6c\x46\x65\x85\xb4\x59\x8a\x1d\x78\x93\xa6\x9c\x5d\x8a\x72\xe8\xd8\x8f\x32\x72\xc8\x77\xec\xf4\xe7\x96\xa2\x27\xf7\xf1\xbe\x21\xac\x64\xdd\x53\xf4\x92\xad\x16\x76\x4e\xb3\xec\x98\xee\x09\xf3\x13\x57\x2d\x1a\x7c\x44\xd4\xb6\x2a\x47\xc1\xc0\xc7\x09\x95\xfb\x93\x5b\x10\x7d\x05\x1c\xe6\x21\xc6\x84\x4e\xb1\xb3\xc8\x5f\x6d\x55\x7c\x06\x54\x62\x3c\x65\xf0\xa2\xa2\x52\xe3\x1d\x4e\xbb\x3d\x25\x4e\xc7\x81\x42\x77\x9e\x90\x0e\x66\x2d\xe3\x3f\xfa\x73\x2c\x74\xd9\xed\x8c\xf1\x45\xe5\x35\x38\x8e\xf6\xd7\x25\x49\xfa\xc3\x96\x15\x3d\x51\x80\xc7\x46\x74\x4c\x63\x6b\xdd\xfe\x2c\x63\x0a\xdc\xb8\x39\x03\x7d\xca\x84\xc2\x30\x4a\x85\x4e\x6a\xc4\x90\x39\x0c\xcc\x60\x5f\xc3\x6c\xa7\xe0\x6d\x1f\x10\x2e\x2f\xe1\x55\x28\xd0\x76\x36\xad\x37\x6d\x6d\x15\x63\x09\x3b\xc1\x1e\xd5\x88\x2b\x5b\xbb\xe3\x0e\x8b\x39\x34\x6d\x6d\xdf\x5c\x5e\x02\x0e\x6b\x2e\xae\x02\x6c\x2a\xbd\x94\x5a\x10\x11\xf3\x5b\x98\x09\x82\x9a\x33\xd9\x82\xdb\x8e\x9d\x91\x5c\x50\x52\x20\x12\xe6\x62\xa5\xe8\x97\x32\x1c\x73\x90\x8c\xde\xbb\xd1\x55\x56\xc2\x52\xe4\x7c\xca\x09\xbc\x23\x54\x5d\xd8\x43\xf5\xfe\xc2\x15\xb7\xda\x7f\x3f\x50\x45\x78\x0b\x3d\x9d\x00\x7c\xc3\x87\x1e\x0d\x91\x83\x41\xbb\xf0\x05\xaa\x10\x3b\x06\x24\xbb\xea\xd2\xae\x49\x47\x50\xd9\xdd\xa6\x33\x9a\xe4\x53\xc7\x20\x09\x04\x73\x57\xc1\xdd\xc2\xc1\xce\xc2\xf7\x67\x86\x02\x7f\x16\x14\x86\xea\xe1\x17\x9c\x54\xa2\xde\xf3\x2f\xbf\xbe\xf8\x60\x13\x93\xd1\x27\x57\x4f\x8b\x3a\x47\x97\xf8\xef\xac\xbd\x97\x91\x7f\xbf\xd9\x76\x26\xdb\x51\xf3\x19\xb7\x2d\x53\x02\xb1\xbf\xcc\x71\xaa\x0f\x22\xa5\x5b\xd3\x65\xe3\x8e\xd6\x01\x07\x7e\x87\xf6\x57\x1f\xa6\xa6\xa2\x80\x69\xc8\xd6\x52\xb5\x3f\x29\xc1\x32\x57\xf0\x48\xf5\x0b\xda\xac\xc0\x72\x46\xc6\xd4\xfe\x8c\xa5\x97\xc6\xd3\x56\xaa\x4d\xe5\xbb\xfb\x59\xe9\xea\x1f\xd2\xed\xe3\x2a\x20\x34\xa7\x4a\x7d\x8f\xc4\x6d\x17\x94\x41\xcc\x88\xb5\x4f\xde\xb6\xca\x20\x83\x2a\x88\x11\xf0\x8e\xe4\xcd\x95\x3b\x88\x4e\x2b\xdd\x01\xf8\x4f\x52\x51\xec\x8e\x47\xa5\x34\x13\xdb\xaf\x9d\x74\x9e\x59\x9c\x08\x37\xfb\x53\x92\xba\x56\x8b\xbe\xc2\x71\x12\xbb\x77\xbd\x69\xf8\xcf\x00\xcb\x04\x78\x99\x15\x6b\x03\x49\x51\x16\x44\x8d\x46\x80\x1d\x05\x83\x08\x26\x07\xb5\x36\x61\xe8\xf9\x2a\xc0\xcb\x42\x3c\xa2\x34\x1d\xad\x0e\x86\x2f\xd7\xab\x15\xca\x16\xf4\xb6\x04\x68\xd7\x51\xb1\x86\xde\xc1\x44\xac\xcd\x27\xac\x6a\x77\x0a\x82\xb0\x75\x44\xeb\xd2\x2c\xc2\x7f\x15\xeb\x79\x26\x2e\x7c\xb5\xd5\x0c\x59\x1b\x51\xa8\x9e\xd1\x11\xbd\xad\x32\xe3\xf3\x8d\xce\xa0\x30\x36\xea\x49\x9c\x72\x5e\xf6\x10\x95\xc0\xc7\x99\x25\x5d\x55\x0b\x91\xc0\x9c\xbb\xce\x88\x89\x4d\x66\xc3\x30\x3a\x15\x02\x2e\xed\x53\x9f\xa4\xb7\x93\x15\xf6\x54\xc1\xda\x39\x87\x4b\x7b\xd6\xfe\xda\x4f\xeb\x01\xac\x64\x42\xd1\x1d\x71\x03\x96\xcb\x3f\xec\x0e\x06\xb5\xfb\x67\x38\x04\x4e\xa0\xb5\x5f\x9b\x90\x75\xd4\x33\xb8\x1c\xe5\x68\x05\xe9\xca\x5e\xf4\x1c\x00\x4c\xd5\x58\x79\xfb\x68\x5d\x4c\x68\xba\x66\x2e\x2a\xf5\xa6\xea\x8d\xd2\x6d\x78\xd8\x2e\x4f\x37\x4a\x22\x77\xcc\x2e\x3f\xb1\x51\x3a\xf9\xee\x08\x7b\x1f\x67\x7e\xae\x68\xfe\x71\x36\xf6\x89\x81\xdf\x15\xf2\xfd\x8a\x23\xd0\x0f\x21\xef\x89\xf9\x1f\x1b\x0c\xc6\xb7\x8c\x4d\xfc\x8a\x92\x4f\xbb\x7c\xa2\x7d\x9b\xd1\xe0\x7c\x1b\x12\x4c\x07\xc0\x40\x8a\xf0\xe5\xd7\x7a\xd8\x9b\xfb\x58\x26\x4a\x45\x89\x77\xa9\x3b\x9f\xb9\xb5\x54\xcf\x71\xa9\xb0\xa8\xd0\xfd\xf4\xda\x1b\x19\x6d\x41\x54\x78\xe9\xe9\x64\xe6\x17\x19\x53\x5e\xe6\x43\x8d\xee\x3e\x73\x6c\x3a\xf7\x5e\x73\xee\x47\xc2\x7d\x07\x48\xbd\xd4\xe1\x1a\x52\x9b\xf9\x61\xf9\xc5\x25\x90\xdf\x8e\x39\x76\xfa\x37\x54\x82\xdb\xc0\x88\x87\xd9\xb7\xf9\x39\x39\xa7\xcb\x50\xab\x7a\x4a\x8b\x47\xbb\x27\xb7\xb6\x7f\x98\xf0\x11\xd8\x78\x0e\x28\xdc\x70\xd8\x5e\xec\x53\xfd\x81\x04\xb0\x77\xca\x87\xbb\x4b\x4e\x38\xea\xe3\xc1\x6e\x5e\x76\xed\xfc\x3b\x9c\xac\x79\x91\x0f\x95\x03\xd2\x3e\x57\xc7\xd0\x64\x2e\xaa\x6d\xcf\x64\x0f\x38\x0d\x26\x68\xd4\x27\x44\xcb\xb6\xe1\xef\x39\x4a\xec\xd5\x59\x9f\xd5\x67\xcd\x09\xca\xea\x08\xed\x13\xa1\xf9\x32\x3e\x75\x4c\x6a\xeb\x12\xb5\xbd\x7f\x5f\x94\x07\xb7\xed\x44\xeb\x3d\x3f\x88\xe9\xe1\x48\xf5\xb1\x29\xeb\x5e\x49\x1f\x3c\x69\x27\x79\x2f\x81\x40\xb8\x7f\x09\x5b\x68\x53\x85\x63\xeb\xfa\x63\x2a\x83\x55\xa1\x6b\x6b\x23\x2e\x9a\x04\x29\x87\x7e\xa7\xd2\xb7\x02\x69\x89\xe7\x6a\xbf\x5a\x0e\x15\x27\x8d\x46\x8e\x9d\xbb\x37\x18\xf3\xfe\x83\xfd\xef\xd0\x4b\x56\x3b\x78\x0a\x2d\xa2\xad\x0a\x7e\x92\x11\x99\x7d\x36\xd3\x4d\x17\x51\xf6\x59\x29\xb7\xb1\x1f\xed\x34\x3f\x5b\x88\x46\x13\xa5\x7c\x43\xa9\xe3\x87\x2a\x55\xbc\x52\xe3\x6e\x64\x2a\x9c\x10\xe5\x95\xad\xb3\x4d\x94\xb2\xe3\x4a\xbc\xda\x99\xc0\x4e\x54\xeb\xda\x02\xd5\xb8\xff\x44\x75\x8d\x65\xde\x34\xd1\xff\x0f\x00\xac\x20\x0e\xd3\x2c\x47\x00\x00")
 
 func templatesKvschemaGotmplBytes() ([]byte, error) {
 	return bindataRead(
@@ -92,7 +92,7 @@ func templatesKvschemaGotmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "templates/kvschema.gotmpl", size: 5859, mode: os.FileMode(420), modTime: time.Unix(1566671246, 0)}
+	info := bindataFileInfo{name: "templates/kvschema.gotmpl", size: 18220, mode: os.FileMode(420), modTime: time.Unix(1792338139, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
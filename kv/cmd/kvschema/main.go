@@ -20,13 +20,9 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/build"
-	"go/importer"
-	"go/parser"
-	"go/token"
 	"go/types"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -36,6 +32,7 @@ import (
 
 	"github.com/google/note-maps/kv"
 	"github.com/google/note-maps/kv/cmd/kvschema/bindata"
+	"golang.org/x/tools/go/packages"
 )
 
 var (
@@ -50,8 +47,8 @@ func verboseLogf(format string, v ...interface{}) {
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage of kvschemer:\n")
-	fmt.Fprintf(os.Stderr, "\tkvschemer [flags] [directory]\n")
+	fmt.Fprintf(os.Stderr, "Usage of kvschema:\n")
+	fmt.Fprintf(os.Stderr, "\tkvschema [flags] [packages]\n")
 	fmt.Fprintf(os.Stderr, "Flags:\n")
 	flag.PrintDefaults()
 }
@@ -59,56 +56,100 @@ func usage() {
 func main() {
 	flag.Usage = usage
 	flag.Parse()
-	args := flag.Args()
-	if len(args) == 0 {
-		wd, err := os.Getwd()
-		if err != nil {
-			log.Fatal(err)
-		}
-		args = []string{wd}
-	} else if len(args) > 1 {
-		flag.Usage()
-		os.Exit(2)
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
 	}
-	dir := args[0]
-	pkgBuild, err := build.ImportDir(dir, 0)
+	pkgs, err := load("", nil, patterns...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	var paths []string
-	for _, gp := range pkgBuild.GoFiles {
-		paths = append(paths, filepath.Join(pkgBuild.Dir, gp))
+	// Type errors are expected at this point if other source files refer to
+	// code that kvschema has yet to generate, or if the previous output
+	// refers to index methods that have since been changed.
+	if err := typeErrors(pkgs); err != nil {
+		verboseLogf("before generating:\n%s", err)
 	}
-	fset := token.NewFileSet()
-	var files []*ast.File
-	for _, path := range paths {
-		f, err := parser.ParseFile(fset, path, nil, 0)
-		if err != nil {
-			log.Fatal(path, ":", err)
+	outputs := make(map[string][]byte)
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			log.Fatalf("%s: no Go files", pkg.PkgPath)
 		}
-		files = append(files, f)
+		var buf bytes.Buffer
+		if err := gen(pkg.Types, &buf); err != nil {
+			log.Fatalf("%s: %s", pkg.PkgPath, err)
+		}
+		opath := filepath.Join(filepath.Dir(pkg.GoFiles[0]), *output)
+		outputs[opath] = buf.Bytes()
 	}
-	conf := types.Config{
-		Importer:    importer.Default(),
-		FakeImportC: true,
+	// Nothing is written unless every package type checks with its new
+	// output in place.
+	if pkgs, err = load("", outputs, patterns...); err != nil {
+		log.Fatal(err)
+	} else if err = typeErrors(pkgs); err != nil {
+		log.Fatal(err)
 	}
-	info := &types.Info{}
-	pkg, err := conf.Check(pkgBuild.ImportPath, fset, files, info)
-	//pkg, err := importer.Default().Import(pkgBuild.ImportPath)
-	if err != nil {
-		log.Println(err)
+	for opath, bs := range outputs {
+		if err := ioutil.WriteFile(opath, bs, 0666); err != nil {
+			log.Fatal(err)
+		}
 	}
-	var buf bytes.Buffer
-	if err := gen(pkg, &buf); err != nil {
-		log.Fatal(err)
+}
+
+// load loads the packages matching patterns, interpreted as by "go list" run
+// in dir, with full type information.
+//
+// Each file named in overlay is read as though its content were the
+// corresponding value.
+//
+// An error is returned if the packages cannot be found or parsed, but not if
+// they contain type errors: see typeErrors.
+func load(dir string, overlay map[string][]byte, patterns ...string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		// Dependencies are type checked from source too, rather than
+		// compiled, so that a package can be loaded even though it
+		// does not compile.
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedImports | packages.NeedDeps,
+		Dir:     dir,
+		Overlay: overlay,
 	}
-	opath := filepath.Join(dir, *output)
-	w, err := os.Create(opath)
+	pkgs, err := packages.Load(cfg, patterns...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages match %s", strings.Join(patterns, " "))
 	}
-	defer w.Close()
-	buf.WriteTo(w)
+	var errs []string
+	for _, pkg := range pkgs {
+		for _, err := range pkg.Errors {
+			if err.Kind != packages.TypeError {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return pkgs, nil
+}
+
+// typeErrors returns an error listing the type errors in pkgs with their
+// positions, or nil if there are none.
+func typeErrors(pkgs []*packages.Package) error {
+	var errs []string
+	for _, pkg := range pkgs {
+		for _, err := range pkg.Errors {
+			if err.Kind == packages.TypeError {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
 }
 
 func gen(pkg *types.Package, w io.Writer) error {
@@ -136,11 +177,16 @@ func gen(pkg *types.Package, w io.Writer) error {
 	var (
 		encoderType    = kvInterface("Encoder")
 		decoderType    = kvInterface("Decoder")
+		formattedType  = kvInterface("Formatted")
 		componentTypes []*componentType
 	)
 	for _, name := range pkg.Scope().Names() {
 		if obj, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok {
-			named := obj.Type().(*types.Named)
+			named, ok := obj.Type().(*types.Named)
+			if !ok || obj.IsAlias() {
+				// Aliases name types that are defined elsewhere.
+				continue
+			}
 			typeName := named.Obj()
 			if !typeName.Exported() {
 				continue
@@ -168,9 +214,19 @@ func gen(pkg *types.Package, w io.Writer) error {
 			}
 			encoderImpl := implements(named, encoderType)
 			decoderImpl := implements(named, decoderType)
+			formatted := false
+			if formattedType != nil {
+				if impl := implements(named, formattedType); impl != noImplementation {
+					// Values are encoded and decoded through registered
+					// codecs, so the type's own Encode and Decode methods,
+					// if any, are not used.
+					formatted = true
+					encoderImpl, decoderImpl = impl, indirectImplementation
+				}
+			}
 			if encoderImpl == noImplementation || decoderImpl == noImplementation {
 				verboseLogf(
-					"%s does not implement both kv.Encoder and kv.Decoder",
+					"%s does not implement kv.Formatted, or both kv.Encoder and kv.Decoder",
 					typeName.Name())
 				continue
 			}
@@ -180,16 +236,57 @@ func gen(pkg *types.Package, w io.Writer) error {
 				PrefixName:    prefixName,
 				DirectEncoder: encoderImpl == directImplementation,
 				DirectDecoder: decoderImpl == directImplementation,
+				Formatted:     formatted,
+			}
+			if slice, ok := named.Underlying().(*types.Slice); ok {
+				c.entityList = isKVType(slice.Elem(), kvpath, "Entity")
 			}
 			methods := types.NewMethodSet(types.NewPointer(named))
 			for i := 0; i < methods.Len(); i++ {
 				selection := methods.At(i)
 				direct := !selection.Indirect()
 				name := selection.Obj().(*types.Func).Name()
+				sig := selection.Type().(*types.Signature)
+				if refName := strings.TrimPrefix(name, "Ref"); refName != name && refName != "" {
+					if sig.Params().Len() != 0 || sig.Results().Len() != 1 ||
+						!isKVType(sig.Results().At(0).Type(), kvpath, "Entity") {
+						verboseLogf("%s does not return just a kv.Entity", name)
+						continue
+					}
+					cascade := false
+					if u := strings.TrimPrefix(refName, "Cascade"); u != refName && u != "" {
+						// RefCascadeFoo defines a reference named Foo
+						// whose referrers are deleted along with the
+						// entity they refer to.
+						refName, cascade = u, true
+					}
+					setter := false
+					if m := methods.Lookup(pkg, "SetRef"+refName); m != nil {
+						msig := m.Type().(*types.Signature)
+						setter = msig.Params().Len() == 1 && msig.Results().Len() == 0 &&
+							isKVType(msig.Params().At(0).Type(), kvpath, "Entity")
+					}
+					c.Indexes = append(c.Indexes, &indexInfo{
+						ComponentName:       c.Name,
+						ComponentPrefixName: c.PrefixName,
+						Name:                refName,
+						PrefixName:          refName + "Prefix",
+						MethodName:          name,
+						MethodDirect:        direct,
+						Scalable:            true,
+						Ref:                 true,
+						Cascade:             cascade,
+						Setter:              setter,
+						TypeExpr:            "kv.Entity",
+						DirectEncoder:       true,
+						DirectDecoder:       false,
+					})
+					verboseLogf("reference found: %v", c.Indexes[len(c.Indexes)-1])
+					continue
+				}
 				if !strings.HasPrefix(name, "Index") {
 					continue
 				}
-				sig := selection.Type().(*types.Signature)
 				if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
 					verboseLogf(
 						"%s receives or returns a wrong number of values", name)
@@ -218,6 +315,22 @@ func gen(pkg *types.Package, w io.Writer) error {
 					expr = elemPkg.Name() + "." + expr
 				}
 				indexName := strings.TrimPrefix(name, "Index")
+				unique, scalable, text := false, false, false
+				if u := strings.TrimPrefix(indexName, "Unique"); u != indexName && u != "" {
+					// IndexUniqueFoo defines a unique index named Foo.
+					indexName, unique = u, true
+				} else if u := strings.TrimPrefix(indexName, "Scalable"); u != indexName && u != "" {
+					// IndexScalableFoo defines a scalable index named Foo.
+					indexName, scalable = u, true
+				} else if u := strings.TrimPrefix(indexName, "Text"); u != indexName && u != "" {
+					// IndexTextFoo defines a text index named Foo, which
+					// is stored as a scalable index of words.
+					if elemPkg.Path() != kvpath || elem.Obj().Name() != "String" {
+						verboseLogf("%s does not return a slice of kv.String", name)
+						continue
+					}
+					indexName, scalable, text = u, true, true
+				}
 				c.Indexes = append(c.Indexes, &indexInfo{
 					ComponentName:       c.Name,
 					ComponentPrefixName: c.PrefixName,
@@ -225,6 +338,9 @@ func gen(pkg *types.Package, w io.Writer) error {
 					PrefixName:          indexName + "Prefix",
 					MethodName:          name,
 					MethodDirect:        direct,
+					Unique:              unique,
+					Scalable:            scalable,
+					Text:                text,
 					TypeExpr:            expr,
 					DirectEncoder:       encoderImpl == directImplementation,
 					DirectDecoder:       decoderImpl == directImplementation,
@@ -238,6 +354,22 @@ func gen(pkg *types.Package, w io.Writer) error {
 	if len(componentTypes) == 0 {
 		return fmt.Errorf("did not find any component types")
 	}
+	for _, c := range componentTypes {
+		for _, ix := range c.Indexes {
+			if !ix.Ref {
+				continue
+			}
+			// A component named FooBars whose values are slices of
+			// entities lists, in order, the Bar entities that refer to
+			// each entity through their reference named Foo.
+			for _, l := range componentTypes {
+				if l.Name == ix.Name+c.Name+"s" && l.entityList {
+					ix.List, ix.ListDirectEncoder = l.Name, l.DirectEncoder
+					verboseLogf("list found: %s", l.Name)
+				}
+			}
+		}
+	}
 	tbs, err := bindata.Asset("templates/kvschema.gotmpl")
 	if err != nil {
 		return err
@@ -246,13 +378,24 @@ func gen(pkg *types.Package, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return t.ExecuteTemplate(w, "kvschema.go", &struct {
-		Package        *types.Package
-		ComponentTypes []*componentType
-	}{
-		pkg,
-		componentTypes,
-	})
+	return t.ExecuteTemplate(w, "kvschema.go", &schema{pkg, componentTypes})
+}
+
+type schema struct {
+	Package        *types.Package
+	ComponentTypes []*componentType
+}
+
+// Cascades returns true if any reference in s cascades deletes.
+func (s *schema) Cascades() bool {
+	for _, c := range s.ComponentTypes {
+		for _, ix := range c.Indexes {
+			if ix.Cascade {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type componentType struct {
@@ -261,7 +404,23 @@ type componentType struct {
 	SVar          string
 	DirectEncoder bool
 	DirectDecoder bool
+	Formatted     bool
 	Indexes       []*indexInfo
+
+	// entityList is true if values of the component are slices of
+	// entities, so that it can list the referrers of a reference.
+	entityList bool
+}
+
+// SliceIndexes returns true if any of c's indexes lists the entities under
+// each index value in a single kv.EntitySlice, rather than being scalable.
+func (c *componentType) SliceIndexes() bool {
+	for _, ix := range c.Indexes {
+		if !ix.Scalable {
+			return true
+		}
+	}
+	return false
 }
 
 type indexInfo struct {
@@ -271,9 +430,42 @@ type indexInfo struct {
 	PrefixName          string
 	MethodName          string
 	MethodDirect        bool
-	TypeExpr            string
-	DirectEncoder       bool
-	DirectDecoder       bool
+	Unique              bool
+	Scalable            bool
+	Text                bool
+	// Ref is true for the reverse index of a reference, and Cascade is
+	// true if the reference's referrers are deleted along with the entity
+	// they refer to rather than restricting its deletion. Setter is true
+	// if the component has a SetRef method for the reference.
+	Ref, Cascade, Setter bool
+	// List names the component that lists the referrers of each entity
+	// in order, if any, and ListDirectEncoder is true if that component's
+	// type implements kv.Encoder without indirection.
+	List              string
+	ListDirectEncoder bool
+	TypeExpr          string
+	DirectEncoder     bool
+	DirectDecoder     bool
+}
+
+// Values returns a Go expression for the index values of the component value
+// in the variable v.
+func (ix *indexInfo) Values(v string) string {
+	call := v + "." + ix.MethodName + "()"
+	switch {
+	case ix.Ref:
+		return "kv.ReferencedEntities(" + call + ")"
+	case ix.Text:
+		return "kv.TextTerms(" + call + ")"
+	}
+	return call
+}
+
+// isKVType returns true if t is the type named name in the package kvpath.
+func isKVType(t types.Type, kvpath, name string) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == kvpath && named.Obj().Name() == name
 }
 
 type implementation int
@@ -21,7 +21,13 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -29,6 +35,7 @@ var tests = []struct {
 	Name       string
 	Source     string
 	Substrings []string
+	Absent     []string
 }{
 	{
 		Name: "docs",
@@ -51,6 +58,245 @@ func (d *Document) IndexTitle() []kv.String { return nil }
 		Substrings: []string{
 			`type Txn struct.?{`,
 			`func \(.* Txn\) EntitiesMatchingDocumentTitle\(v kv\.String\)`,
+			`func \(.* Txn\) EntitiesByDocumentTitleRange\(lower, upper \*kv\.String,`,
+			`func \(.* Txn\) VerifyDocumentIndexes\(\) \(\[\]\*kv\.IndexError, error\)`,
+			`func \(.* Txn\) RebuildDocumentTitleIndex\(\) error`,
+			`func \(.* Txn\) HasDocument\(\) kv\.Query`,
+			`func \(.* Txn\) MatchingDocumentTitle\(v kv\.String\) kv\.Query`,
+			`func DecodeChange\(c kv\.Change\) \(ComponentChange, bool, error\)`,
+			`case DocumentPrefix:\s+if !c\.Deleted {\s+var v Document\s+if err := v\.Decode\(c\.Value\)`,
+		},
+	},
+	{
+		Name: "formatted",
+		Source: `
+package input
+
+import "github.com/google/note-maps/kv"
+
+const (
+	NotePrefix  kv.Component = 3
+	TitlePrefix kv.Component = 4
+	TagsPrefix  kv.Component = 5
+)
+
+type Note struct{ Title string }
+
+func (n *Note) ValueFormat() kv.Format  { return kv.GobFormat }
+func (n *Note) IndexTitle() []kv.String { return nil }
+
+type Tags []string
+
+func (Tags) ValueFormat() kv.Format { return kv.JSONFormat }
+`,
+		Substrings: []string{
+			`func \(.* Txn\) SetNote\(e kv\.Entity, v \*Note\) error`,
+			`func \(.* Txn\) SetTags\(e kv\.Entity, v Tags\) error`,
+			`kv\.EncodeFormatted\(v\.ValueFormat\(\), v\)`,
+			`kv\.GetDecoded\(s\.Partitioned\.Txn, key, decodeTags\)`,
+			`result\[i\] = v\.\(Tags\)`,
+			`kv\.DecodeFormatted\(bs, &v\)`,
+			`case TagsPrefix:\s+if !c\.Deleted {\s+var v Tags\s+if err := kv\.DecodeFormatted\(c\.Value, &v\)`,
+		},
+	},
+	{
+		Name: "ordered",
+		Source: `
+package input
+
+import "github.com/google/note-maps/kv"
+
+const (
+	NotePrefix         kv.Component = 3
+	ModifiedPrefix     kv.Component = 4
+	LanguageNamePrefix kv.Component = 5
+)
+
+type Note struct {
+	Modified int64
+	Language string
+	Name     string
+}
+
+func (n *Note) ValueFormat() kv.Format { return kv.GobFormat }
+func (n *Note) IndexModified() []kv.Int64 {
+	return []kv.Int64{kv.Int64(n.Modified)}
+}
+func (n *Note) IndexLanguageName() []kv.Tuple {
+	return []kv.Tuple{{kv.String(n.Language), kv.String(n.Name)}}
+}
+`,
+		Substrings: []string{
+			`func \(.* Txn\) EntitiesByNoteModifiedRange\(lower, upper \*kv\.Int64,`,
+			`func \(.* Txn\) EntitiesMatchingNoteLanguageName\(v kv\.Tuple\)`,
+			`func \(.* Txn\) RebuildNoteLanguageNameIndex\(\) error`,
+		},
+		Absent: []string{
+			// Every index must be updated before returning.
+			`return nil\n\n\t// Update`,
+		},
+	},
+	{
+		Name: "scalable",
+		Source: `
+package input
+
+import "github.com/google/note-maps/kv"
+
+const (
+	NotePrefix  kv.Component = 3
+	TitlePrefix kv.Component = 4
+)
+
+type Note struct{ Title string }
+
+func (n *Note) ValueFormat() kv.Format          { return kv.GobFormat }
+func (n *Note) IndexScalableTitle() []kv.String { return nil }
+`,
+		Substrings: []string{
+			`func \(.* Txn\) EntitiesMatchingNoteTitle\(v kv\.String\)`,
+			`s\.InsertScalableIndexEntry\(NotePrefix, TitlePrefix, iv\.Encode\(\), e\)`,
+			`s\.RemoveScalableIndexEntry\(NotePrefix, TitlePrefix, iv\.Encode\(\), e\)`,
+			`s\.EntitiesByScalableIndexRange\(NotePrefix, TitlePrefix,`,
+			`s\.CheckScalableIndex\(NotePrefix, TitlePrefix,`,
+			`s\.RebuildScalableIndex\(NotePrefix, TitlePrefix,`,
+			`kv\.MatchingScalableIndex\(NotePrefix, TitlePrefix, v\.Encode\(\)\)`,
+		},
+		Absent: []string{
+			`kv\.EntitySlice\n`,
+		},
+	},
+	{
+		Name: "text",
+		Source: `
+package input
+
+import "github.com/google/note-maps/kv"
+
+const (
+	NotePrefix  kv.Component = 3
+	WordsPrefix kv.Component = 4
+)
+
+type Note struct{ Title string }
+
+func (n *Note) ValueFormat() kv.Format      { return kv.GobFormat }
+func (n *Note) IndexTextWords() []kv.String { return nil }
+`,
+		Substrings: []string{
+			`func \(.* Txn\) SearchNoteWords\(query string, n int\) \(\[\]kv\.TextMatch, error\)`,
+			`range kv\.TextTerms\(v\.IndexTextWords\(\)\)`,
+			`s\.InsertScalableIndexEntry\(NotePrefix, WordsPrefix, iv\.Encode\(\), e\)`,
+			`s\.RebuildScalableIndex\(NotePrefix, WordsPrefix,`,
+		},
+		Absent: []string{
+			`EntitiesMatchingNoteWords`,
+			`EntitiesByNoteWords`,
+		},
+	},
+	{
+		Name: "references",
+		Source: `
+package input
+
+import "github.com/google/note-maps/kv"
+
+const (
+	FolderPrefix    kv.Component = 3
+	NotePrefix      kv.Component = 4
+	ParentPrefix    kv.Component = 5
+	FolderRefPrefix kv.Component = 6
+)
+
+type Folder struct{ Parent uint64 }
+
+func (f *Folder) ValueFormat() kv.Format { return kv.GobFormat }
+func (f *Folder) RefParent() kv.Entity   { return kv.Entity(f.Parent) }
+
+type Note struct{ Folder uint64 }
+
+func (n *Note) ValueFormat() kv.Format         { return kv.GobFormat }
+func (n *Note) RefCascadeFolderRef() kv.Entity { return kv.Entity(n.Folder) }
+func (n *Note) SetRefFolderRef(e kv.Entity)    { n.Folder = uint64(e) }
+`,
+		Substrings: []string{
+			`func \(.* Txn\) EntitiesMatchingNoteFolderRef\(v kv\.Entity\)`,
+			`range kv\.ReferencedEntities\(v\.RefCascadeFolderRef\(\)\)`,
+			`s\.InsertScalableIndexEntry\(NotePrefix, FolderRefPrefix, iv\.Encode\(\), e\)`,
+			`func \(.* Txn\) SetNoteFolderRef\(e, ref kv\.Entity\) error`,
+			`func \(.* Txn\) DeleteEntity\(e kv\.Entity\) error`,
+			`cascade = append\(cascade, es\.\.\.\)`,
+			`return &kv\.ReferenceError\{Component: FolderPrefix, Reference: ParentPrefix,`,
+			`s\.DeleteNote\(e\)`,
+		},
+		Absent: []string{
+			`SetFolderParent`,
+			`ReferenceError\{Component: NotePrefix`,
+			`func \(.* Txn\) (add|remove)\w*Entry`,
+		},
+	},
+	{
+		Name: "lists",
+		Source: `
+package input
+
+import "github.com/google/note-maps/kv"
+
+const (
+	FolderNotesPrefix kv.Component = 3
+	NotePrefix        kv.Component = 4
+	FolderPrefix      kv.Component = 5
+)
+
+type FolderNotes kv.EntitySlice
+
+func (fns FolderNotes) Encode() []byte { return kv.EntitySlice(fns).Encode() }
+func (fns *FolderNotes) Decode(bs []byte) error {
+	return (*kv.EntitySlice)(fns).Decode(bs)
+}
+
+type Note struct{ Folder uint64 }
+
+func (n *Note) ValueFormat() kv.Format      { return kv.GobFormat }
+func (n *Note) RefCascadeFolder() kv.Entity { return kv.Entity(n.Folder) }
+`,
+		Substrings: []string{
+			`func \(.* Txn\) addFolderNotesEntry\(ref, e kv\.Entity\) error`,
+			`func \(.* Txn\) removeFolderNotesEntry\(ref, e kv\.Entity\) error`,
+			`s\.removeFolderNotesEntry\(oldRef, e\)`,
+			`s\.addFolderNotesEntry\(ref, e\)`,
+			`s\.removeFolderNotesEntry\(old\.RefCascadeFolder\(\), e\)`,
+			`return s\.SetFolderNotes\(ref, es\)`,
+		},
+	},
+	{
+		Name: "unique",
+		Source: `
+package input
+
+import "github.com/google/note-maps/kv"
+
+const (
+	UserPrefix  kv.Component = 3
+	EmailPrefix kv.Component = 4
+	NamePrefix  kv.Component = 5
+)
+
+type User struct{ Email, Name string }
+
+func (u *User) ValueFormat() kv.Format        { return kv.GobFormat }
+func (u *User) IndexUniqueEmail() []kv.String { return nil }
+func (u *User) IndexName() []kv.String        { return nil }
+`,
+		Substrings: []string{
+			`func \(.* Txn\) LookupUserEmail\(v kv\.String\) \(kv\.Entity, error\)`,
+			`func \(.* Txn\) EntitiesMatchingUserEmail\(v kv\.String\)`,
+			`s\.CheckUniqueIndex\(UserPrefix, EmailPrefix, e, iv\.Encode\(\)\)`,
+		},
+		Absent: []string{
+			`LookupUserName`,
+			`ScalableIndex`,
+			`CheckUniqueIndex\(UserPrefix, NamePrefix`,
 		},
 	},
 }
@@ -103,5 +349,71 @@ func TestStuff(t *testing.T) {
 					test.Name, want)
 			}
 		}
+		for _, unwanted := range test.Absent {
+			if match, err := regexp.MatchString(unwanted, generated); err != nil {
+				t.Fatalf("bad regexp %#v in test: %s", match, err)
+			} else if match {
+				t.Errorf(
+					"%s: found pattern %#v in generated result, but did not want it",
+					test.Name, unwanted)
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvschema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, src := range map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\nconst A = 1\n",
+		"b/b.go": "package b\n\nvar B = Generated\n",
+		"c/c.go": "package c\n\nvar C int = \"c\"\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		} else if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgs, err := load(dir, nil, "./a", "./b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, pkg := range pkgs {
+		if pkg.Types == nil {
+			t.Errorf("%s: no type information", pkg.PkgPath)
+		}
+		paths = append(paths, pkg.PkgPath)
+	}
+	sort.Strings(paths)
+	if want := []string{"example.com/m/a", "example.com/m/b"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got packages %v, want %v", paths, want)
+	}
+	if err := typeErrors(pkgs); err == nil {
+		t.Error("want a type error for the identifier that is yet to be generated")
+	}
+	overlay := map[string][]byte{
+		filepath.Join(dir, "b", "kvschema.go"): []byte("package b\n\nconst Generated = 2\n"),
+	}
+	if pkgs, err = load(dir, overlay, "./a", "./b"); err != nil {
+		t.Fatal(err)
+	} else if err = typeErrors(pkgs); err != nil {
+		t.Errorf("want no type errors with the overlay, got %v", err)
+	}
+	if pkgs, err = load(dir, nil, "./c"); err != nil {
+		t.Fatal(err)
+	}
+	err = typeErrors(pkgs)
+	if want := filepath.Join("c", "c.go") + ":3:"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want a type error at %s", err, want)
+	}
+	if _, err = load(dir, nil, "./missing"); err == nil {
+		t.Error("want an error for a missing package")
 	}
 }
@@ -0,0 +1,152 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Format identifies the encoding of a format-tagged value, in which it is
+// stored as the first byte.
+//
+// Formats from 0x01 through 0x3f are reserved for codecs registered by this
+// package. Other packages may register codecs for formats from 0x40 through
+// 0xff. Format zero is never used.
+type Format byte
+
+const (
+	// GobFormat identifies values encoded with encoding/gob.
+	GobFormat Format = 0x01
+
+	// JSONFormat identifies values encoded with encoding/json.
+	JSONFormat Format = 0x02
+)
+
+// Codec encodes and decodes values in one Format.
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(src []byte, v interface{}) error
+}
+
+// Formatted is implemented by component value types whose values are stored
+// in a format-tagged encoding.
+//
+// When a component value type implements Formatted, code generated by
+// kvschema encodes its values with EncodeFormatted and decodes them with
+// DecodeFormatted, so the type need not implement Encoder or Decoder.
+type Formatted interface {
+	// ValueFormat returns the format in which the value should be stored.
+	ValueFormat() Format
+}
+
+// UnsupportedFormatError indicates that a value was found in the key-value
+// backing store with an unsupported format code, perhaps due to data
+// corruption.
+type UnsupportedFormatError byte
+
+func (e UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported format code 0x%x", byte(e))
+}
+
+var codecs = struct {
+	sync.RWMutex
+	m map[Format]Codec
+}{m: map[Format]Codec{
+	GobFormat:  gobCodec{},
+	JSONFormat: jsonCodec{},
+}}
+
+// RegisterCodec makes c available to encode and decode values in format f.
+//
+// RegisterCodec is typically called from an init function, and panics if f is
+// zero or if a codec has already been registered for f.
+func RegisterCodec(f Format, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	if f == 0 {
+		panic("kv: RegisterCodec with format zero")
+	} else if _, dup := codecs.m[f]; dup {
+		panic(fmt.Sprintf("kv: RegisterCodec called twice for format 0x%x", byte(f)))
+	}
+	codecs.m[f] = c
+}
+
+func codec(f Format) (Codec, error) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	if c, ok := codecs.m[f]; ok {
+		return c, nil
+	}
+	return nil, UnsupportedFormatError(f)
+}
+
+// EncodeFormatted encodes v with the codec registered for f, and returns the
+// result prefixed with f.
+func EncodeFormatted(f Format, v interface{}) ([]byte, error) {
+	c, err := codec(f)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := c.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return ConcatByteSlices([]byte{byte(f)}, bs), nil
+}
+
+// DecodeFormatted decodes src, a value returned by EncodeFormatted, into v
+// with the codec registered for its format.
+//
+// An empty src is taken to be a missing value, and leaves v unchanged.
+// DecodeFormatted returns an UnsupportedFormatError if no codec is registered
+// for the format of src.
+func DecodeFormatted(src []byte, v interface{}) error {
+	if len(src) == 0 {
+		return nil
+	}
+	c, err := codec(Format(src[0]))
+	if err != nil {
+		return err
+	}
+	return c.Decode(src[1:], v)
+}
+
+// FormattedDecoder returns a function that decodes its argument into v with
+// DecodeFormatted, suitable for passing to Txn.Get.
+func FormattedDecoder(v interface{}) func([]byte) error {
+	return func(src []byte) error { return DecodeFormatted(src, v) }
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Decode(src []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(src)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Decode(src []byte, v interface{}) error { return json.Unmarshal(src, v) }
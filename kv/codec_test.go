@@ -0,0 +1,61 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatted(t *testing.T) {
+	type value struct {
+		A string
+		B []int
+	}
+	want := value{"a", []int{1, 2}}
+	for _, f := range []Format{GobFormat, JSONFormat} {
+		bs, err := EncodeFormatted(f, &want)
+		if err != nil {
+			t.Fatal(err)
+		} else if Format(bs[0]) != f {
+			t.Errorf("want format 0x%x, got 0x%x", f, bs[0])
+		}
+		var got value
+		if err = DecodeFormatted(bs, &got); err != nil {
+			t.Error(err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("format 0x%x: want %v, got %v", f, want, got)
+		}
+	}
+	var got value
+	if err := DecodeFormatted(nil, &got); err != nil {
+		t.Error(err)
+	}
+	if _, err := EncodeFormatted(0x3f, &want); err != UnsupportedFormatError(0x3f) {
+		t.Errorf("want UnsupportedFormatError(0x3f), got %v", err)
+	}
+	if err := DecodeFormatted([]byte{0x3f, 1}, &got); err != UnsupportedFormatError(0x3f) {
+		t.Errorf("want UnsupportedFormatError(0x3f), got %v", err)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a second codec for JSONFormat did not panic")
+		}
+	}()
+	RegisterCodec(JSONFormat, jsonCodec{})
+}
@@ -0,0 +1,494 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crypt provides an implementation of kv.DB that encrypts the data it
+// stores in another kv.DB.
+//
+// Values are encrypted with AES-GCM and authenticated together with their
+// keys, so that a value cannot be moved to another key without detection.
+// Each encrypted value records the ID of the Key that encrypted it, so that
+// data encrypted with older keys remains readable until Rotate re-encrypts it
+// with a new one.
+//
+// Keys are stored in the clear unless Options.EncryptKeys is set. Since keys
+// include index values, such as the words of names, encrypting them may be
+// just as important as encrypting values. Keys are encrypted
+// deterministically, so that they can still be found, and one byte at a time,
+// each into two bytes by a strictly increasing function chosen by all the
+// bytes before it. Keys that share a prefix, such as the partition and
+// component at the start of every key, share an encrypted prefix, and
+// encrypted keys are ordered like the keys they encrypt, so iterators and
+// ranges map directly onto those of the underlying DB. In exchange, the
+// encryption reveals how keys are ordered, which keys share prefixes and how
+// long they are, and, as with any order-preserving encryption, something of
+// the values of their bytes.
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/note-maps/kv"
+)
+
+// MinSecretSize is the minimum length of a Key's secret, in bytes.
+const MinSecretSize = 16
+
+// rotateBatchSize is the number of key-value pairs that Rotate re-encrypts in
+// each transaction.
+const rotateBatchSize = 256
+
+var (
+	errNoKeys         = errors.New("crypt: no keys")
+	errCorrupt        = errors.New("crypt: encrypted value is too short")
+	errAuthentication = errors.New("crypt: encrypted value failed authentication")
+)
+
+// Key is a secret used to encrypt data, with an ID that is stored with the
+// data it encrypts.
+type Key struct {
+	ID     uint32
+	Secret []byte
+}
+
+// NewKey returns a Key with the given ID and a new random secret.
+func NewKey(id uint32) (Key, error) {
+	k := Key{ID: id, Secret: make([]byte, 32)}
+	_, err := rand.Read(k.Secret)
+	return k, err
+}
+
+// Options configures a DB.
+type Options struct {
+	// Keys holds every key that may have encrypted data stored in the
+	// underlying kv.DB. The first key encrypts everything that is written.
+	Keys []Key
+
+	// EncryptKeys enables the encryption of keys as well as values.
+	//
+	// Encrypted keys are only found with the key that encrypted them, so
+	// after a new key is placed first in Keys, data written with older keys
+	// is not visible until Rotate has re-encrypted it.
+	EncryptKeys bool
+}
+
+// DB implements kv.DB by encrypting the data stored in another kv.DB.
+type DB struct {
+	db          kv.DB
+	primary     *cipherKey
+	keys        map[uint32]*cipherKey
+	encryptKeys bool
+}
+
+// New returns a DB that stores encrypted data in db.
+func New(db kv.DB, opts Options) (*DB, error) {
+	if len(opts.Keys) == 0 {
+		return nil, errNoKeys
+	}
+	cdb := &DB{
+		db:          db,
+		keys:        make(map[uint32]*cipherKey),
+		encryptKeys: opts.EncryptKeys,
+	}
+	for _, k := range opts.Keys {
+		if _, ok := cdb.keys[k.ID]; ok {
+			return nil, fmt.Errorf("crypt: duplicate key %d", k.ID)
+		}
+		ck, err := newCipherKey(k)
+		if err != nil {
+			return nil, err
+		}
+		cdb.keys[k.ID] = ck
+		if cdb.primary == nil {
+			cdb.primary = ck
+		}
+	}
+	return cdb, nil
+}
+
+// NewTxn returns a transaction of the underlying DB that encrypts and
+// decrypts everything it writes and reads.
+func (db *DB) NewTxn(update bool) kv.TxnCommitDiscarder {
+	return txn{db: db, tx: db.db.NewTxn(update)}
+}
+
+// NewBatch returns a batch of the underlying DB that encrypts everything it
+// writes, or a batch that writes through a single transaction if the
+// underlying DB does not implement kv.Batcher.
+func (db *DB) NewBatch() kv.Batch {
+	if b, ok := db.db.(kv.Batcher); ok {
+		return batch{db: db, b: b.NewBatch()}
+	}
+	return txnBatch{db.NewTxn(true)}
+}
+
+// Close closes the underlying DB.
+func (db *DB) Close() error { return db.db.Close() }
+
+// Rotate re-encrypts every value, and every key if keys are encrypted, that
+// was encrypted with any key other than the first in Options.Keys.
+//
+// Rotate commits its changes in a series of transactions, so if it is
+// interrupted it can simply be called again. Once it returns nil, the other
+// keys are no longer needed.
+func (db *DB) Rotate() error {
+	var start []byte
+	for {
+		next, err := db.rotateBatch(start)
+		if err != nil || next == nil {
+			return err
+		}
+		start = next
+	}
+}
+
+// rotateBatch re-encrypts up to rotateBatchSize key-value pairs, beginning
+// with the first underlying key greater than or equal to start, in a single
+// transaction. It returns the underlying key at which to continue, or nil if
+// there are no more keys.
+func (db *DB) rotateBatch(start []byte) ([]byte, error) {
+	tx := db.db.NewTxn(true)
+	defer tx.Discard()
+	pairs, next, err := db.stale(tx, start)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pairs {
+		stored := db.encryptKey(p.key)
+		if !bytes.Equal(stored, p.stored) {
+			if err := tx.Delete(p.stored); err != nil {
+				return nil, err
+			}
+			// A value written with the first key since the keys
+			// changed is newer than the stale one, so keep it.
+			if ok, err := kv.Has(tx, stored); err != nil {
+				return nil, err
+			} else if ok {
+				continue
+			}
+		}
+		if err := tx.Set(stored, db.seal(p.key, p.value)); err != nil {
+			return nil, err
+		}
+	}
+	return next, tx.Commit()
+}
+
+// stalePair is a key-value pair that was encrypted with an older key.
+type stalePair struct {
+	// stored is the underlying key, while key and value are decrypted.
+	stored, key, value []byte
+}
+
+// stale returns up to rotateBatchSize key-value pairs in tx that were
+// encrypted with keys other than the first, beginning with the first
+// underlying key greater than or equal to start, and the underlying key at
+// which to continue, or nil if there are no more keys.
+func (db *DB) stale(tx kv.Txn, start []byte) ([]stalePair, []byte, error) {
+	var pairs []stalePair
+	iter := tx.PrefixIterator(nil)
+	defer iter.Discard()
+	for iter.Seek(start); iter.Valid(); iter.Next() {
+		stored := kv.ConcatByteSlices(iter.Key())
+		if len(pairs) == rotateBatchSize {
+			return pairs, stored, nil
+		}
+		if len(stored) < 8+2 {
+			// Backends may reserve shorter keys for their own use,
+			// and no other key is that short.
+			continue
+		}
+		current := !db.encryptKeys || db.primary.owns(stored)
+		if current {
+			if err := iter.Value(func(bs []byte) error {
+				current = db.primary.owns(bs)
+				return nil
+			}); err != nil {
+				return nil, nil, err
+			}
+		}
+		if current {
+			continue
+		}
+		key := stored
+		if db.encryptKeys {
+			k, err := db.key(stored)
+			if err != nil {
+				return nil, nil, err
+			}
+			key = k.decryptKey(stored)
+		}
+		value, err := db.get(iter, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		pairs = append(pairs, stalePair{stored, key, value})
+	}
+	return pairs, nil, nil
+}
+
+// get returns the decrypted value at the current position of iter, an
+// iterator over the underlying DB, where key is the decrypted key.
+func (db *DB) get(iter kv.Iterator, key []byte) ([]byte, error) {
+	var value []byte
+	err := iter.Value(func(bs []byte) (err error) {
+		value, err = db.open(key, bs)
+		return err
+	})
+	return value, err
+}
+
+// key returns the key with the ID at the start of bs.
+func (db *DB) key(bs []byte) (*cipherKey, error) {
+	if len(bs) < 4 {
+		return nil, errCorrupt
+	}
+	id := binary.BigEndian.Uint32(bs)
+	k, ok := db.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("crypt: data was encrypted with unknown key %d", id)
+	}
+	return k, nil
+}
+
+// encryptKey returns the underlying key that stores the value of key.
+func (db *DB) encryptKey(key []byte) []byte {
+	if !db.encryptKeys {
+		return key
+	}
+	return db.primary.encryptKey(key)
+}
+
+// encryptPrefix returns the encryption of a prefix of keys, which is also a
+// prefix of the encryption of each key that begins with it, and the state of
+// the encryption after it.
+func (db *DB) encryptPrefix(prefix []byte) ([]byte, keyState) {
+	out := make([]byte, 4, 4+2*len(prefix))
+	binary.BigEndian.PutUint32(out, db.primary.id)
+	var state keyState
+	return db.primary.encrypt(out, &state, prefix), state
+}
+
+// encryptSuffix returns the encryption of the rest of a key that begins with
+// a prefix after which the encryption has the given state.
+func (db *DB) encryptSuffix(state keyState, suffix []byte) []byte {
+	return db.primary.encrypt(make([]byte, 0, 2*len(suffix)), &state, suffix)
+}
+
+// decryptSuffix reverses encryptSuffix.
+func (db *DB) decryptSuffix(state keyState, enc []byte) []byte {
+	return db.primary.decrypt(make([]byte, 0, len(enc)/2), &state, enc)
+}
+
+// decryptKey returns the key stored as stored, which must have been returned
+// by encryptKey.
+func (db *DB) decryptKey(stored []byte) []byte {
+	if !db.encryptKeys {
+		return stored
+	}
+	return db.primary.decryptKey(stored)
+}
+
+// seal returns the encryption of the value of key.
+func (db *DB) seal(key, value []byte) []byte {
+	return db.primary.seal(key, value)
+}
+
+// open returns the decryption of sealed, the encrypted value of key.
+func (db *DB) open(key, sealed []byte) ([]byte, error) {
+	k, err := db.key(sealed)
+	if err != nil {
+		return nil, err
+	}
+	return k.open(key, sealed)
+}
+
+// cipherKey holds the ciphers derived from a Key.
+type cipherKey struct {
+	id uint32
+	// values encrypts values.
+	values cipher.AEAD
+	// keys is the block cipher from which the functions that encrypt
+	// keys are derived.
+	keys cipher.Block
+}
+
+func newCipherKey(k Key) (*cipherKey, error) {
+	if len(k.Secret) < MinSecretSize {
+		return nil, fmt.Errorf("crypt: secret of key %d is shorter than %d bytes", k.ID, MinSecretSize)
+	}
+	vb, err := aes.NewCipher(derive(k.Secret, "values"))
+	if err != nil {
+		return nil, err
+	}
+	values, err := cipher.NewGCM(vb)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := aes.NewCipher(derive(k.Secret, "keys"))
+	if err != nil {
+		return nil, err
+	}
+	return &cipherKey{id: k.ID, values: values, keys: keys}, nil
+}
+
+// derive returns a 256-bit key for the purpose described by label.
+func derive(secret []byte, label string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("note-maps kv/crypt " + label))
+	return mac.Sum(nil)
+}
+
+// owns returns true if bs begins with the ID of k.
+func (k *cipherKey) owns(bs []byte) bool {
+	return len(bs) >= 4 && binary.BigEndian.Uint32(bs) == k.id
+}
+
+// seal returns the ID of k, a random nonce, and the encryption of value
+// authenticated together with key.
+func (k *cipherKey) seal(key, value []byte) []byte {
+	n := k.values.NonceSize()
+	out := make([]byte, 4+n, 4+n+len(value)+k.values.Overhead())
+	binary.BigEndian.PutUint32(out, k.id)
+	if _, err := rand.Read(out[4:]); err != nil {
+		panic(err)
+	}
+	return k.values.Seal(out, out[4:], value, key)
+}
+
+// open reverses seal.
+func (k *cipherKey) open(key, sealed []byte) ([]byte, error) {
+	n := k.values.NonceSize()
+	if len(sealed) < 4+n {
+		return nil, errCorrupt
+	}
+	value, err := k.values.Open(nil, sealed[4:4+n], sealed[4+n:], key)
+	if err != nil {
+		return nil, errAuthentication
+	}
+	return value, nil
+}
+
+// keyState holds what the encryption of a key has learned from the bytes of
+// the key encrypted so far.
+type keyState [aes.BlockSize]byte
+
+// encryptKey returns the ID of k followed by the encryption of key.
+func (k *cipherKey) encryptKey(key []byte) []byte {
+	out := make([]byte, 4, 4+2*len(key))
+	binary.BigEndian.PutUint32(out, k.id)
+	var state keyState
+	return k.encrypt(out, &state, key)
+}
+
+// decryptKey reverses encryptKey.
+func (k *cipherKey) decryptKey(stored []byte) []byte {
+	var state keyState
+	return k.decrypt(make([]byte, 0, (len(stored)-4)/2), &state, stored[4:])
+}
+
+// encrypt appends to dst the encryption of bs, which follows the bytes that
+// brought the encryption to state, and advances state past bs.
+//
+// Each byte is encrypted as two bytes by a strictly increasing function chosen
+// by state, so encrypted keys are ordered like the keys they encrypt.
+func (k *cipherKey) encrypt(dst []byte, state *keyState, bs []byte) []byte {
+	var pad [aes.BlockSize]byte
+	for _, b := range bs {
+		k.pad(state, &pad)
+		var c [2]byte
+		binary.BigEndian.PutUint16(c[:], increasing(&pad, b))
+		dst = append(dst, c[:]...)
+		k.advance(state, b)
+	}
+	return dst
+}
+
+// decrypt reverses encrypt. If enc has an odd length, its last byte is
+// ignored.
+func (k *cipherKey) decrypt(dst []byte, state *keyState, enc []byte) []byte {
+	var pad [aes.BlockSize]byte
+	for ; len(enc) >= 2; enc = enc[2:] {
+		k.pad(state, &pad)
+		b := decreasing(&pad, binary.BigEndian.Uint16(enc))
+		dst = append(dst, b)
+		k.advance(state, b)
+	}
+	return dst
+}
+
+// pad derives from state the function that encrypts the next byte of a key.
+func (k *cipherKey) pad(state *keyState, pad *[aes.BlockSize]byte) {
+	in := *state
+	in[aes.BlockSize-1] ^= 1
+	k.keys.Encrypt(pad[:], in[:])
+}
+
+// advance updates state to follow one more byte, b, of a key.
+func (k *cipherKey) advance(state *keyState, b byte) {
+	state[0] ^= b
+	state[aes.BlockSize-1] ^= 2
+	k.keys.Encrypt(state[:], state[:])
+}
+
+// increasing maps b to a 16-bit value with a strictly increasing function
+// chosen by pad.
+//
+// The function divides the 16-bit range between the two halves of the byte
+// range, then each of those between the two quarters within it, and so on, for
+// each of the eight bits of b. Each division gives every half at least as many
+// values as it has bytes, and divides the rest in a proportion taken from pad
+// by the depth of the division and its position at that depth.
+func increasing(pad *[aes.BlockSize]byte, b byte) uint16 {
+	lo, size := 0, 1<<16
+	for depth := uint(0); depth < 8; depth++ {
+		left := split(pad, depth, int(b)>>(8-depth), size)
+		if b>>(7-depth)&1 == 0 {
+			size = left
+		} else {
+			lo, size = lo+left, size-left
+		}
+	}
+	return uint16(lo)
+}
+
+// decreasing is the inverse of increasing.
+func decreasing(pad *[aes.BlockSize]byte, c uint16) byte {
+	lo, size, b := 0, 1<<16, 0
+	for depth := uint(0); depth < 8; depth++ {
+		left := split(pad, depth, b, size)
+		b <<= 1
+		if int(c) < lo+left {
+			size = left
+		} else {
+			lo, size, b = lo+left, size-left, b|1
+		}
+	}
+	return byte(b)
+}
+
+// split returns how many of the size values available to the bytes that begin
+// with the depth bits of path go to those whose next bit is zero.
+func split(pad *[aes.BlockSize]byte, depth uint, path, size int) int {
+	half := 1 << (7 - depth)
+	r := int(pad[depth] + pad[8+depth]*byte(path))
+	return half + (size-2*half)*r>>8
+}
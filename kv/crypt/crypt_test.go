@@ -0,0 +1,261 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/crypt"
+	"github.com/google/note-maps/kv/kvtest"
+	"github.com/google/note-maps/kv/memory"
+)
+
+var (
+	key1 = crypt.Key{ID: 1, Secret: []byte("0123456789abcdef")}
+	key2 = crypt.Key{ID: 2, Secret: []byte("fedcba9876543210")}
+)
+
+func TestConformance(t *testing.T) {
+	for _, encryptKeys := range []bool{false, true} {
+		t.Run(fmt.Sprint("EncryptKeys=", encryptKeys), func(t *testing.T) {
+			kvtest.RunConformance(t, func(t *testing.T) kv.DB {
+				return kvtest.NewCryptDB(t, kvtest.NewBackendDB(t, kvtest.Backend()), encryptKeys)
+			})
+		})
+	}
+}
+
+// rawPairs returns every key-value pair stored in db.
+func rawPairs(t *testing.T, db kv.DB) [][2][]byte {
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	iter := txn.PrefixIterator(nil)
+	defer iter.Discard()
+	var pairs [][2][]byte
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		var value []byte
+		if err := iter.Value(func(bs []byte) error {
+			value = kv.ConcatByteSlices(bs)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, [2][]byte{kv.ConcatByteSlices(iter.Key()), value})
+	}
+	return pairs
+}
+
+func set(t *testing.T, db kv.DB, pairs ...string) {
+	t.Helper()
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		for i := 0; i < len(pairs); i += 2 {
+			if err := txn.Set([]byte(pairs[i]), []byte(pairs[i+1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, db kv.DB, key string) (string, error) {
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	var v string
+	err := txn.Get([]byte(key), func(bs []byte) error {
+		v = string(bs)
+		return nil
+	})
+	return v, err
+}
+
+func TestEncryption(t *testing.T) {
+	for _, encryptKeys := range []bool{false, true} {
+		raw := memory.NewDB()
+		db, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key1}, EncryptKeys: encryptKeys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		set(t, db, "partition/secret-name", "secret value", "partition/secret-word", "")
+		pairs := rawPairs(t, raw)
+		if len(pairs) != 2 {
+			t.Fatalf("want 2 stored pairs, got %d", len(pairs))
+		}
+		for _, p := range pairs {
+			if bytes.Contains(p[1], []byte("secret")) {
+				t.Errorf("value %q is stored in the clear", p[1])
+			}
+			if encryptKeys == bytes.Contains(p[0], []byte("secret")) {
+				t.Errorf("EncryptKeys is %v, but stored key %q", encryptKeys, p[0])
+			}
+		}
+		if encryptKeys {
+			// After the ID of the key, the encrypted keys share the
+			// two-byte encryption of each byte of their common prefix,
+			// and then differ in the same order as the keys.
+			a, b := pairs[0][0], pairs[1][0]
+			if n := 4 + 2*len("partition/secret-"); !bytes.Equal(a[:n], b[:n]) || bytes.Compare(a[n:n+2], b[n:n+2]) >= 0 {
+				t.Errorf("encrypted keys %x and %x do not share a prefix of %d bytes in order", a, b, n)
+			}
+		}
+		if v, err := get(t, db, "partition/secret-name"); err != nil {
+			t.Error(err)
+		} else if v != "secret value" {
+			t.Errorf("want %q, got %q", "secret value", v)
+		}
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	db, err := crypt.New(memory.NewDB(), crypt.Options{Keys: []crypt.Key{key1}, EncryptKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys are written in reverse order, so an iterator can only visit
+	// them in order if their encryptions are stored in the same order.
+	bs := []byte{0x00, 0x01, 0x7f, 0x80, 0xfe, 0xff}
+	var want []string
+	for _, a := range bs {
+		want = append(want, string([]byte{a}))
+		for _, b := range bs {
+			want = append(want, string([]byte{a, b}))
+		}
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		set(t, db, want[i], "")
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	iter := txn.PrefixIterator(nil)
+	defer iter.Discard()
+	var got []string
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		got = append(got, string(kv.ConcatByteSlices(iter.Key())))
+	}
+	if fmt.Sprintf("%x", got) != fmt.Sprintf("%x", want) {
+		t.Errorf("want keys %x, got %x", want, got)
+	}
+}
+
+func TestRotateKeepsNewerValues(t *testing.T) {
+	raw := memory.NewDB()
+	old, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key1}, EncryptKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set(t, old, "key-a-padding", "old", "key-b-padding", "old")
+	rotating, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key2, key1}, EncryptKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Written with the new key before Rotate has re-encrypted it.
+	set(t, rotating, "key-a-padding", "new")
+	if err := rotating.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rawPairs(t, raw)); n != 2 {
+		t.Errorf("want 2 stored pairs after Rotate, got %d", n)
+	}
+	for k, want := range map[string]string{"key-a-padding": "new", "key-b-padding": "old"} {
+		if v, err := get(t, rotating, k); err != nil {
+			t.Fatal(err)
+		} else if v != want {
+			t.Errorf("%s: want %q, got %q", k, want, v)
+		}
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	raw := memory.NewDB()
+	db, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set(t, db, "k", "v")
+	wrong, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{{ID: key1.ID, Secret: key2.Secret}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, wrong, "k"); err == nil {
+		t.Error("decrypted a value with the wrong secret")
+	}
+	unknown, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, unknown, "k"); err == nil {
+		t.Error("decrypted a value without its key")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, keys := range [][]crypt.Key{
+		nil,
+		{{ID: 1, Secret: []byte("short")}},
+		{key1, {ID: key1.ID, Secret: key2.Secret}},
+	} {
+		if _, err := crypt.New(memory.NewDB(), crypt.Options{Keys: keys}); err == nil {
+			t.Errorf("New succeeded with keys %v", keys)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	for _, encryptKeys := range []bool{false, true} {
+		raw := memory.NewDB()
+		old, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key1}, EncryptKeys: encryptKeys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Enough pairs to take more than one transaction to rotate.
+		var pairs []string
+		for i := 0; i < 600; i++ {
+			pairs = append(pairs, fmt.Sprintf("key%03d-padding", i), fmt.Sprint("value", i))
+		}
+		set(t, old, pairs...)
+		rotating, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key2, key1}, EncryptKeys: encryptKeys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rotating.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(rawPairs(t, raw)); n != len(pairs)/2 {
+			t.Errorf("want %d stored pairs after Rotate, got %d", len(pairs)/2, n)
+		}
+		rotated, err := crypt.New(raw, crypt.Options{Keys: []crypt.Key{key2}, EncryptKeys: encryptKeys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			if v, err := get(t, rotated, pairs[i]); err != nil {
+				t.Fatal(err)
+			} else if v != pairs[i+1] {
+				t.Fatalf("want %q, got %q", pairs[i+1], v)
+			}
+		}
+		// Keys encrypted with the old key are gone, while values
+		// encrypted with the new key cannot be decrypted with the old.
+		if v, err := get(t, old, pairs[0]); encryptKeys && (err != nil || v != "") {
+			t.Errorf("found %q, %v with the old key after Rotate", v, err)
+		} else if !encryptKeys && err == nil {
+			t.Error("decrypted a rotated value with the old key")
+		}
+	}
+}
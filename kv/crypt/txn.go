@@ -0,0 +1,155 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import "github.com/google/note-maps/kv"
+
+type txn struct {
+	db *DB
+	tx kv.TxnCommitDiscarder
+}
+
+func (s txn) Alloc() (kv.Entity, error) { return s.tx.Alloc() }
+
+func (s txn) Set(key, value []byte) error {
+	return s.tx.Set(s.db.encryptKey(key), s.db.seal(key, value))
+}
+
+func (s txn) Delete(key []byte) error { return s.tx.Delete(s.db.encryptKey(key)) }
+
+func (s txn) Get(key []byte, f func([]byte) error) error {
+	var value []byte
+	if err := s.tx.Get(s.db.encryptKey(key), func(bs []byte) (err error) {
+		if len(bs) > 0 {
+			value, err = s.db.open(key, bs)
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+	return f(value)
+}
+
+func (s txn) Has(key []byte) (bool, error) { return kv.Has(s.tx, s.db.encryptKey(key)) }
+
+func (s txn) PrefixIterator(prefix []byte) kv.Iterator {
+	if s.db.encryptKeys {
+		stored, state := s.db.encryptPrefix(prefix)
+		return &keyIterator{s.tx.PrefixIterator(stored), s.db, prefix, state}
+	}
+	return &iterator{s.tx.PrefixIterator(prefix), s.db, prefix}
+}
+
+func (s txn) ReversePrefixIterator(prefix []byte) kv.Iterator {
+	if s.db.encryptKeys {
+		stored, state := s.db.encryptPrefix(prefix)
+		return &keyIterator{s.tx.ReversePrefixIterator(stored), s.db, prefix, state}
+	}
+	return &iterator{s.tx.ReversePrefixIterator(prefix), s.db, prefix}
+}
+
+func (s txn) RangeIterator(prefix, lower, upper []byte) kv.Iterator {
+	if s.db.encryptKeys {
+		stored, state := s.db.encryptPrefix(prefix)
+		// Since encryption preserves order, the range of encrypted
+		// keys holds exactly the encryptions of the keys in range.
+		lo := s.db.encryptSuffix(state, lower)
+		var hi []byte
+		if upper != nil {
+			hi = s.db.encryptSuffix(state, upper)
+		}
+		return &keyIterator{s.tx.RangeIterator(stored, lo, hi), s.db, prefix, state}
+	}
+	return &iterator{s.tx.RangeIterator(prefix, lower, upper), s.db, prefix}
+}
+
+func (s txn) Commit() error { return s.tx.Commit() }
+
+func (s txn) Discard() { s.tx.Discard() }
+
+// iterator decrypts the values visited by an iterator over keys that are not
+// encrypted.
+type iterator struct {
+	kv.Iterator
+	db     *DB
+	prefix []byte
+}
+
+func (i *iterator) Value(f func([]byte) error) error {
+	var value []byte
+	if err := i.Iterator.Value(func(bs []byte) (err error) {
+		value, err = i.db.open(kv.ConcatByteSlices(i.prefix, i.Iterator.Key()), bs)
+		return err
+	}); err != nil {
+		return err
+	}
+	return f(value)
+}
+
+// keyIterator decrypts the keys and values visited by an iterator over
+// encrypted keys, which are ordered like the keys they encrypt.
+type keyIterator struct {
+	kv.Iterator
+	db     *DB
+	prefix []byte
+	// state is the state of the encryption after prefix.
+	state keyState
+}
+
+func (i *keyIterator) Seek(key []byte) {
+	if key == nil {
+		i.Iterator.Seek(nil)
+		return
+	}
+	i.Iterator.Seek(i.db.encryptSuffix(i.state, key))
+}
+
+func (i *keyIterator) Key() []byte {
+	return i.db.decryptSuffix(i.state, i.Iterator.Key())
+}
+
+func (i *keyIterator) Value(f func([]byte) error) error {
+	var value []byte
+	if err := i.Iterator.Value(func(bs []byte) (err error) {
+		value, err = i.db.open(kv.ConcatByteSlices(i.prefix, i.Key()), bs)
+		return err
+	}); err != nil {
+		return err
+	}
+	return f(value)
+}
+
+// batch encrypts the writes made through a batch of the underlying DB.
+type batch struct {
+	db *DB
+	b  kv.Batch
+}
+
+func (b batch) Set(key, value []byte) error {
+	return b.b.Set(b.db.encryptKey(key), b.db.seal(key, value))
+}
+
+func (b batch) Delete(key []byte) error { return b.b.Delete(b.db.encryptKey(key)) }
+
+func (b batch) Flush() error { return b.b.Flush() }
+
+func (b batch) Cancel() { b.b.Cancel() }
+
+// txnBatch implements kv.Batch with a single transaction.
+type txnBatch struct{ kv.TxnCommitDiscarder }
+
+func (b txnBatch) Flush() error { return b.Commit() }
+
+func (b txnBatch) Cancel() { b.Discard() }
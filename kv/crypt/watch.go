@@ -0,0 +1,78 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/google/note-maps/kv"
+)
+
+// Watch returns a subscription to the changes made to keys that begin with
+// prefix, decrypted from a subscription to the underlying DB, or
+// kv.ErrWatchUnsupported if the underlying DB does not implement kv.Watcher.
+func (db *DB) Watch(prefix []byte) (kv.Subscription, error) {
+	sub, err := kv.Watch(db.db, db.encryptKey(prefix))
+	if err != nil {
+		return nil, err
+	}
+	s := &subscription{db: db, sub: sub, Feed: kv.NewFeed(sub.Close)}
+	go s.run()
+	return s, nil
+}
+
+// subscription publishes the decryption of the changes received from a
+// subscription to the underlying DB.
+type subscription struct {
+	*kv.Feed
+	db  *DB
+	sub kv.Subscription
+}
+
+// run publishes decrypted changes until either subscription ends.
+func (s *subscription) run() {
+	for changes := range s.sub.Changes() {
+		decrypted, err := s.decrypt(changes)
+		if err != nil {
+			s.End(err)
+			return
+		}
+		s.Publish(decrypted)
+	}
+	s.End(s.sub.Err())
+}
+
+// decrypt returns the decryption of changes, sorted by key.
+func (s *subscription) decrypt(changes []kv.Change) ([]kv.Change, error) {
+	decrypted := make([]kv.Change, len(changes))
+	for i, c := range changes {
+		key := s.db.decryptKey(c.Key)
+		decrypted[i] = kv.Change{Key: key, Deleted: c.Deleted}
+		if !c.Deleted {
+			value, err := s.db.open(key, c.Value)
+			if err != nil {
+				return nil, err
+			}
+			decrypted[i].Value = value
+		}
+	}
+	if s.db.encryptKeys {
+		sort.Slice(decrypted, func(i, j int) bool {
+			return bytes.Compare(decrypted[i].Key, decrypted[j].Key) < 0
+		})
+	}
+	return decrypted, nil
+}
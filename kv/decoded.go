@@ -0,0 +1,46 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+// DecodeFunc decodes a value read from a Txn, which is nil or empty if the
+// key has no value.
+type DecodeFunc func([]byte) (interface{}, error)
+
+// DecodedGetter is implemented by transactions that can avoid reading and
+// decoding values again, such as those of kv/cache.
+type DecodedGetter interface {
+	// GetDecoded returns the result of decode applied to the value of key,
+	// or a result that it returned earlier for the same value.
+	//
+	// Every call for the same key must use an equivalent decode function.
+	GetDecoded(key []byte, decode DecodeFunc) (interface{}, error)
+}
+
+// GetDecoded returns the result of decode applied to the value of key in
+// txn, using txn's GetDecoded method if it implements DecodedGetter.
+//
+// Since the result may be shared with other callers, slices, maps and
+// pointers within it must not be modified.
+func GetDecoded(txn Txn, key []byte, decode DecodeFunc) (interface{}, error) {
+	if dg, ok := txn.(DecodedGetter); ok {
+		return dg.GetDecoded(key, decode)
+	}
+	var v interface{}
+	err := txn.Get(key, func(bs []byte) (err error) {
+		v, err = decode(bs)
+		return err
+	})
+	return v, err
+}
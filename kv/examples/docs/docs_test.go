@@ -17,6 +17,8 @@ package docs
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/note-maps/kv"
@@ -204,3 +206,331 @@ func TestAllDocumentEntities(t *testing.T) {
 	}
 	kvtest.Deflake(t, test)
 }
+
+func TestIteratorReverse(t *testing.T) {
+	test := func(s_ kv.Txn) {
+		s := New(s_)
+		createDocuments(&s, sampleDocuments("Foo", 5))
+		createDocuments(&s, sampleDocuments("Foo", 5))
+		createDocuments(&s, sampleDocuments("Bar", 5))
+		for pageSize := 1; pageSize < 11; pageSize++ {
+			var (
+				cursor  kv.IndexCursor
+				docs    []Document
+				already = make(map[kv.Entity]bool)
+			)
+			for {
+				es, err := s.EntitiesByDocumentTitleReverse(&cursor, pageSize)
+				if err != nil {
+					panic(err)
+				}
+				for _, e := range es {
+					if already[e] {
+						t.Fatalf("duplicate %v", e)
+					}
+					already[e] = true
+				}
+				ds, err := s.GetDocumentSlice(es)
+				if err != nil {
+					panic(err)
+				}
+				docs = append(docs, ds...)
+				if len(es) < pageSize {
+					break
+				}
+			}
+			if len(docs) != 15 {
+				t.Fatalf("want 15 documents, got %v", len(docs))
+			}
+			for i := 1; i < len(docs); i++ {
+				if docs[i-1].Title < docs[i].Title {
+					t.Fatalf("want %#v before %#v, got after",
+						docs[i].Title, docs[i-1].Title)
+				}
+			}
+		}
+	}
+	kvtest.Deflake(t, test)
+}
+
+func TestAllDocumentEntitiesReverse(t *testing.T) {
+	test := func(s_ kv.Txn) {
+		s := New(s_)
+		want := createDocuments(&s, sampleDocuments("All", 5))
+		sort.Slice(want, func(a, b int) bool { return want[a] > want[b] })
+		for pageSize := 0; pageSize < len(want)+1; pageSize++ {
+			var (
+				start kv.Entity
+				got   []kv.Entity
+			)
+			for {
+				buf, err := s.AllDocumentEntitiesReverse(&start, pageSize)
+				if err != nil {
+					panic(err)
+				}
+				got = append(got, buf...)
+				if pageSize > 0 && pageSize < len(buf) {
+					t.Fatalf("want <= %d entities, got %d", pageSize, len(buf))
+				} else if pageSize == 0 || len(buf) < pageSize {
+					break
+				}
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("want %#v, got %#v", want, got)
+			}
+		}
+	}
+	kvtest.Deflake(t, test)
+}
+
+func TestIteratorRange(t *testing.T) {
+	test := func(s_ kv.Txn) {
+		s := New(s_)
+		createDocuments(&s, sampleDocuments("Bar", 5))
+		createDocuments(&s, sampleDocuments("Baz", 5))
+		createDocuments(&s, sampleDocuments("Foo", 5))
+		lower, upper := kv.String("baz"), kv.String("foo")
+		for pageSize := 1; pageSize < 7; pageSize++ {
+			var (
+				cursor kv.IndexCursor
+				docs   []Document
+			)
+			for {
+				es, err := s.EntitiesByDocumentTitleRange(&lower, &upper, &cursor, pageSize)
+				if err != nil {
+					panic(err)
+				}
+				ds, err := s.GetDocumentSlice(es)
+				if err != nil {
+					panic(err)
+				}
+				docs = append(docs, ds...)
+				if len(es) < pageSize {
+					break
+				}
+			}
+			if len(docs) != 5 {
+				t.Fatalf("want 5 documents, got %v", len(docs))
+			}
+			for _, d := range docs {
+				if !strings.HasPrefix(d.Title, "Baz") {
+					t.Fatalf("want only Baz documents, got %#v", d.Title)
+				}
+			}
+		}
+	}
+	kvtest.Deflake(t, test)
+}
+
+func TestBatchTxn(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	bt, err := kv.NewBatchTxn(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flush often so that index updates span several batches.
+	bt.MaxPendingBytes = 256
+	s := New(bt)
+	des := createDocuments(&s, sampleDocuments("Initial", 50))
+	revised := sampleDocuments("Revised", 50)
+	for i, de := range des {
+		if err := s.SetDocument(de, &revised[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	s = New(txn)
+	verifyDocuments(&s, des, revised)
+	initial, err := s.EntitiesMatchingDocumentTitle(kv.String("Initial 0"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(initial) != 0 {
+		t.Errorf("want no documents with initial title, got %v", initial)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	test := func(s_ kv.Txn) {
+		s := New(s_)
+		samples := sampleDocuments("Delete", 5)
+		des := createDocuments(&s, samples)
+		if err := s.DeleteDocument(des[0]); err != nil {
+			panic(err)
+		}
+		verifyDocuments(&s, des[1:], samples[1:])
+		matches, err := s.EntitiesMatchingDocumentTitle(samples[0].IndexTitle()[0])
+		if err != nil {
+			panic(err)
+		} else if len(matches) != 0 {
+			panic(fmt.Sprintf("want no matches for a deleted document, got %v", matches))
+		}
+		if err := s.DeletePartition(); err != nil {
+			panic(err)
+		}
+		es, err := s.AllDocumentEntities(nil, 0)
+		if err != nil {
+			panic(err)
+		} else if len(es) != 0 {
+			panic(fmt.Sprintf("want no documents after DeletePartition, got %v", es))
+		}
+	}
+	kvtest.Deflake(t, test)
+}
+
+func TestVerifyComponentIndex(t *testing.T) {
+	txn := kvtest.New(t)
+	defer txn.Discard()
+	s := New(txn)
+	des := createDocuments(&s, sampleDocuments("Verify", 3))
+	values := func(bs []byte) ([][]byte, error) {
+		var d Document
+		if err := d.Decode(bs); err != nil {
+			return nil, err
+		}
+		return [][]byte{d.IndexTitle()[0].Encode()}, nil
+	}
+	if err := s.VerifyComponentIndex(DocumentPrefix, TitlePrefix, values); err != nil {
+		t.Fatal(err)
+	}
+	// List des[0] under a title that it does not have, and remove it from
+	// the title that it does have.
+	key := kv.ConcatByteSlices(
+		s.Partition.Encode(), DocumentPrefix.Encode(), kv.Entity(0).Encode(),
+		TitlePrefix.Encode())
+	if err := txn.Set(append(key, "bogus"...), kv.EntitySlice{des[0]}.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	err := s.VerifyComponentIndex(DocumentPrefix, TitlePrefix, values)
+	if ie, ok := err.(*kv.IndexError); !ok || ie.Missing || ie.Entity != des[0] {
+		t.Errorf("want an IndexError for an unexpected entry, got %v", err)
+	}
+	if err := txn.Set(append(key, "bogus"...), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Set(append(key, "verify 0"...), nil); err != nil {
+		t.Fatal(err)
+	}
+	err = s.VerifyComponentIndex(DocumentPrefix, TitlePrefix, values)
+	if ie, ok := err.(*kv.IndexError); !ok || !ie.Missing || ie.Entity != des[0] {
+		t.Errorf("want an IndexError for a missing entry, got %v", err)
+	}
+}
+
+func TestVerifyRebuildIndexes(t *testing.T) {
+	txn := kvtest.New(t)
+	defer txn.Discard()
+	s := New(txn)
+	des := createDocuments(&s, sampleDocuments("Rebuild", 3))
+	if ies, err := s.VerifyDocumentIndexes(); err != nil {
+		t.Fatal(err)
+	} else if len(ies) != 0 {
+		t.Fatalf("want no inconsistencies, got %v", ies)
+	}
+	// Leave des[1] out of its index entry, and list it under a bogus one.
+	key := kv.ConcatByteSlices(
+		s.Partition.Encode(), DocumentPrefix.Encode(), kv.Entity(0).Encode(),
+		TitlePrefix.Encode())
+	if err := txn.Set(append(key, "rebuild 1"...), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Set(append(key, "bogus"...), kv.EntitySlice{des[1]}.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	ies, err := s.VerifyDocumentIndexes()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ies) != 2 {
+		t.Fatalf("want 2 inconsistencies, got %v", ies)
+	}
+	if err := s.RebuildDocumentTitleIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if ies, err := s.VerifyDocumentIndexes(); err != nil {
+		t.Fatal(err)
+	} else if len(ies) != 0 {
+		t.Fatalf("want no inconsistencies after rebuild, got %v", ies)
+	}
+	verifyDocuments(&s, des, sampleDocuments("Rebuild", 3))
+}
+
+func TestQueryEntities(t *testing.T) {
+	test := func(s_ kv.Txn) {
+		s := New(s_)
+		// Enough documents that queries must read them in several batches.
+		ds := make([]Document, 200)
+		for i := range ds {
+			ds[i].Title = []string{"Even", "Odd"}[i%2]
+		}
+		es := createDocuments(&s, ds)
+		var even, odd, all []kv.Entity
+		for i, e := range es {
+			if i%7 == 0 {
+				if err := s.DeleteDocument(e); err != nil {
+					panic(err)
+				}
+				continue
+			}
+			all = append(all, e)
+			if i%2 == 0 {
+				even = append(even, e)
+			} else {
+				odd = append(odd, e)
+			}
+		}
+		for _, test := range []struct {
+			name  string
+			query kv.Query
+			want  []kv.Entity
+		}{
+			{"has", s.HasDocument(), all},
+			{"matching", s.MatchingDocumentTitle("even"), even},
+			{"and", kv.And(s.HasDocument(), s.MatchingDocumentTitle("odd")), odd},
+			{"or", kv.Or(s.MatchingDocumentTitle("odd"), s.MatchingDocumentTitle("even")), all},
+			{"and not", kv.AndNot(s.HasDocument(), s.MatchingDocumentTitle("even")), odd},
+			{"empty and", kv.And(s.MatchingDocumentTitle("even"), s.MatchingDocumentTitle("odd")), nil},
+		} {
+			for _, pageSize := range []int{0, 1, 7, 64, 65} {
+				var (
+					start kv.Entity
+					got   []kv.Entity
+				)
+				for {
+					buf, err := s.QueryEntities(test.query, &start, pageSize)
+					if err != nil {
+						panic(err)
+					}
+					got = append(got, buf...)
+					if pageSize == 0 || len(buf) < pageSize {
+						break
+					}
+				}
+				if !kv.EntitySlice(test.want).Equal(kv.EntitySlice(got)) {
+					t.Fatalf("%s with page size %d: want %v, got %v",
+						test.name, pageSize, test.want, got)
+				}
+			}
+			var got []kv.Entity
+			if err := s.ForEachQueryEntity(test.query, func(e kv.Entity) error {
+				got = append(got, e)
+				return nil
+			}); err != nil {
+				panic(err)
+			}
+			if !kv.EntitySlice(test.want).Equal(kv.EntitySlice(got)) {
+				t.Fatalf("%s: want %v, got %v", test.name, test.want, got)
+			}
+		}
+	}
+	// Too many operations for Deflake, but a read-write transaction checks
+	// that queries do not hold more than one iterator at a time.
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	test(txn)
+}
@@ -117,14 +117,22 @@ func (s Txn) GetDocumentSlice(es []kv.Entity) ([]Document, error) {
 	DocumentPrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeDocument)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(Document)
 	}
 	return result, nil
 }
 
+// decodeDocument decodes a Document, for use with kv.GetDecoded.
+func decodeDocument(bs []byte) (interface{}, error) {
+	var v Document
+	err := v.Decode(bs)
+	return v, err
+}
+
 // AllDocumentEntities returns the first n entities that have a Document, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -136,6 +144,27 @@ func (s Txn) AllDocumentEntities(start *kv.Entity, n int) (es []kv.Entity, err e
 	return s.AllComponentEntities(DocumentPrefix, start, n)
 }
 
+// AllDocumentEntitiesReverse returns the first n entities that have a
+// Document in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllDocumentEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllDocumentEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(DocumentPrefix, start, n)
+}
+
+// HasDocument returns a query matching every entity that has a Document,
+// for use with QueryEntities.
+func (s Txn) HasDocument() kv.Query {
+	return kv.HasComponent(DocumentPrefix)
+}
+
 // EntitiesMatchingDocumentTitle returns entities with Document values that return a matching kv.String from their IndexTitle method.
 //
 // The returned EntitySlice is already sorted.
@@ -150,6 +179,13 @@ func (s Txn) EntitiesMatchingDocumentTitle(v kv.String) (kv.EntitySlice, error)
 	return es, s.Get(key, es.Decode)
 }
 
+// MatchingDocumentTitle returns a query matching the entities
+// that EntitiesMatchingDocumentTitle would return, for use with
+// QueryEntities.
+func (s Txn) MatchingDocumentTitle(v kv.String) kv.Query {
+	return kv.MatchingComponentIndex(DocumentPrefix, TitlePrefix, v.Encode())
+}
+
 // EntitiesByDocumentTitle returns entities with
 // Document values ordered by the kv.String values from their
 // IndexTitle method.
@@ -161,3 +197,138 @@ func (s Txn) EntitiesMatchingDocumentTitle(v kv.String) (kv.EntitySlice, error)
 func (s Txn) EntitiesByDocumentTitle(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
 	return s.EntitiesByComponentIndex(DocumentPrefix, TitlePrefix, cursor, n)
 }
+
+// EntitiesByDocumentTitleRange returns entities with
+// Document values ordered by the kv.String values from their
+// IndexTitle method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesByDocumentTitleRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesByDocumentTitleRange(lower, upper *kv.String, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByComponentIndexRange(DocumentPrefix, TitlePrefix, lo, hi, cursor, n)
+}
+
+// EntitiesByDocumentTitleReverse returns entities with
+// Document values in reverse order by the kv.String values from
+// their IndexTitle method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesByDocumentTitleReverse would return next n entities.
+func (s Txn) EntitiesByDocumentTitleReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByComponentIndexReverse(DocumentPrefix, TitlePrefix, cursor, n)
+}
+
+// VerifyDocumentIndexes checks that every index of Document values is
+// consistent with the Document values themselves, and returns every
+// inconsistency it finds.
+func (s Txn) VerifyDocumentIndexes() ([]*kv.IndexError, error) {
+	var ies []*kv.IndexError
+	report := func(ie *kv.IndexError) error {
+		ies = append(ies, ie)
+		return nil
+	}
+	if err := s.CheckComponentIndex(DocumentPrefix, TitlePrefix, indexDocumentTitle, report); err != nil {
+		return ies, err
+	}
+	return ies, nil
+}
+
+// RebuildDocumentIndexes rebuilds every index of Document values, so that
+// VerifyDocumentIndexes finds no inconsistencies.
+func (s Txn) RebuildDocumentIndexes() error {
+	if err := s.RebuildDocumentTitleIndex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebuildDocumentTitleIndex rebuilds the index of
+// Document values by the kv.String values from their
+// IndexTitle method.
+func (s Txn) RebuildDocumentTitleIndex() error {
+	return s.RebuildComponentIndex(DocumentPrefix, TitlePrefix, indexDocumentTitle)
+}
+
+// indexDocumentTitle decodes a Document and returns
+// the encoded kv.String values from its IndexTitle method.
+func indexDocumentTitle(bs []byte) ([][]byte, error) {
+	var v Document
+	if err := v.Decode(bs); err != nil {
+		return nil, err
+	}
+	ivs := v.IndexTitle()
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
+// DeleteEntity deletes every component associated with e.
+//
+// Entities that refer to e through a reference are deleted first if the
+// reference cascades. If the reference restricts deletion instead,
+// DeleteEntity returns a *kv.ReferenceError, and any changes already made to
+// delete other entities through cascading references should be discarded.
+func (s Txn) DeleteEntity(e kv.Entity) error {
+	if err := s.DeleteDocument(e); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ComponentChange is a change to a component of an entity, decoded from a
+// kv.Change by DecodeChange.
+type ComponentChange struct {
+	Partition kv.Entity
+	Component kv.Component
+	Entity    kv.Entity
+
+	// Deleted is true if the component was deleted.
+	Deleted bool
+
+	// Value points to a new value of the component's type, or is nil if
+	// the component was deleted.
+	Value interface{}
+}
+
+// DecodeChange decodes a change to a component from c, such as a change
+// received from a kv.Subscription.
+//
+// DecodeChange returns false if c changed any other key, such as the key of an
+// index entry.
+func DecodeChange(c kv.Change) (ComponentChange, bool, error) {
+	p, component, e, ok := kv.SplitComponentKey(c.Key)
+	if !ok {
+		return ComponentChange{}, false, nil
+	}
+	cc := ComponentChange{Partition: p, Component: component, Entity: e, Deleted: c.Deleted}
+	switch component {
+	case DocumentPrefix:
+		if !c.Deleted {
+			var v Document
+			if err := v.Decode(c.Value); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	default:
+		return ComponentChange{}, false, nil
+	}
+	return cc, true, nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+// KeyChecker is implemented by transactions that can tell whether a key
+// exists without creating an iterator, which can be expensive in update
+// transactions, such as those of kv/badger.
+type KeyChecker interface {
+	// Has reports whether key has a value, even an empty one.
+	Has(key []byte) (bool, error)
+}
+
+// Has reports whether key has a value in txn, even an empty one, using txn's
+// Has method if it implements KeyChecker.
+//
+// Unlike Get, Has distinguishes a key with an empty value from a key that
+// does not exist.
+func Has(txn Txn, key []byte) (bool, error) {
+	if kc, ok := txn.(KeyChecker); ok {
+		return kc.Has(key)
+	}
+	iter := txn.PrefixIterator(key)
+	defer iter.Discard()
+	iter.Seek(nil)
+	return iter.Valid() && len(iter.Key()) == 0, nil
+}
@@ -38,22 +38,86 @@
 package kv
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"sort"
+	"time"
 )
 
+// ErrConflict is returned by Commit when a transaction conflicts with another
+// transaction that committed first. The transaction can usually be retried;
+// see Update.
+var ErrConflict = errors.New("kv: transaction conflict")
+
 // DB represents the functions a database connection should implement to be
 // convenient for code that uses this package.
+//
+// Implementations must provide snapshot isolation:
+//
+//   - Every transaction reads from a consistent snapshot of the store as it
+//     was when the transaction was created, together with its own writes.
+//     Writes committed by other transactions after that point are not
+//     visible to it, not even to iterators created later in the same
+//     transaction.
+//   - Writes made in a transaction are not visible to any other transaction
+//     until Commit returns successfully, and Discard drops them entirely.
+//   - Commit returns ErrConflict, and applies none of the transaction's
+//     writes, if any key read by the transaction was modified by another
+//     transaction that committed after the first transaction was created.
+//   - A transaction created with update set to false is a read-only view of
+//     its snapshot: Set and Delete return errors.
 type DB interface {
-	// NewTxn returns a new TxnCommitDiscarder that optionally supports updates.
+	// NewTxn returns a new TxnCommitDiscarder that optionally supports
+	// updates. The transaction observes a snapshot of the store taken when
+	// NewTxn is called.
 	NewTxn(update bool) TxnCommitDiscarder
 
 	// Close releases any resources held by this DB and closes the connection.
 	Close() error
 }
 
+// These variables control how Update retries transactions that conflict.
+var (
+	updateAttempts   = 10
+	updateMinBackoff = time.Millisecond
+	updateMaxBackoff = 100 * time.Millisecond
+)
+
+// Update calls f with a new update transaction in db and commits it if f
+// returns nil.
+//
+// If the commit fails with ErrConflict, Update waits for a randomized and
+// increasing interval and then tries again with a new transaction, so f must
+// be safe to call more than once. After too many conflicts, Update gives up
+// and returns ErrConflict.
+func Update(db DB, f func(Txn) error) error {
+	backoff := updateMinBackoff
+	for attempt := 1; ; attempt++ {
+		err := update(db, f)
+		if err != ErrConflict || attempt == updateAttempts {
+			return err
+		}
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff))))
+		if backoff *= 2; backoff > updateMaxBackoff {
+			backoff = updateMaxBackoff
+		}
+	}
+}
+
+func update(db DB, f func(Txn) error) error {
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	if err := f(txn); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
 // Txn represents the functions a key-value store transaction must implement in
 // order to be used as a backing transaction in this package.
 type Txn interface {
@@ -91,6 +155,26 @@ type Txn interface {
 	// prefix, so for prefix {1,2} an underlying key {1,2,3,4} will be visible
 	// through this iterator as merely {3,4}.
 	PrefixIterator(prefix []byte) Iterator
+
+	// ReversePrefixIterator returns an iterator over all key-value pairs with
+	// keys matching the given prefix, in descending order by key.
+	//
+	// Keys are relative to the given prefix just as they are for
+	// PrefixIterator, and the initial state of the iterator is not valid. See
+	// Iterator.Seek for how seeking works on a reverse iterator.
+	ReversePrefixIterator(prefix []byte) Iterator
+
+	// RangeIterator returns an iterator over all key-value pairs with keys
+	// matching the given prefix and, relative to that prefix, greater than or
+	// equal to lower and less than upper.
+	//
+	// A nil lower bound includes all keys from the beginning of the prefix, and
+	// a nil upper bound includes all keys through to the end of the prefix.
+	//
+	// Keys are relative to the given prefix just as they are for
+	// PrefixIterator, and the initial state of the iterator is not valid.
+	// Seeking to a key before lower moves the iterator to lower.
+	RangeIterator(prefix, lower, upper []byte) Iterator
 }
 
 // Iterator supports iteration over key-value pairs.
@@ -102,9 +186,16 @@ type Iterator interface {
 	//
 	// If there is no such key-value pair, Seek moves to the item with first key
 	// after the given key.
+	//
+	// For a reverse iterator, "after" means "less than": Seek moves to the
+	// last key-value pair with a key less than or equal to the given key. As a
+	// special case, a nil key is treated as greater than every key, so that
+	// Seek(nil) moves a reverse iterator to its last key-value pair. A
+	// non-nil empty key is just the empty key.
 	Seek(key []byte)
 
-	// Next moves to the iterator to the next key-value pair.
+	// Next moves to the iterator to the next key-value pair, which for a
+	// reverse iterator is the pair with the next smaller key.
 	Next()
 
 	// Valid returns true if the iterator is at a valid key-value pair.
@@ -205,6 +296,43 @@ func (t Partitioned) AllComponentEntities(c Component, start *Entity, n int) (es
 	return
 }
 
+// AllComponentEntitiesReverse returns the first n entities that have values
+// associated with c in descending order, beginning with the greatest entity
+// less than *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllComponentEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (t Partitioned) AllComponentEntitiesReverse(c Component, start *Entity, n int) (es []Entity, err error) {
+	prefix := make(Prefix, 8+2)
+	t.Partition.EncodeAt(prefix)
+	c.EncodeAt(prefix[8:])
+	iter := t.ReversePrefixIterator(prefix)
+	defer iter.Discard()
+	if start == nil || *start == 0 {
+		iter.Seek(nil)
+	} else {
+		iter.Seek((*start - 1).Encode())
+	}
+	for ; iter.Valid() && (n <= 0 || len(es) < n); iter.Next() {
+		var e Entity
+		e.Decode(iter.Key())
+		if e == 0 {
+			// Entity zero is where indexes are stored.
+			break
+		}
+		es = append(es, e)
+	}
+	if start != nil && len(es) > 0 {
+		*start = es[len(es)-1]
+	}
+	return
+}
+
 // EntitiesByComponentIndex returns entities with c values ordered by their ix
 // values.
 //
@@ -220,6 +348,33 @@ func (s Partitioned) EntitiesByComponentIndex(c, ix Component, cursor *IndexCurs
 	ix.EncodeAt(key[18:])
 	iter := s.PrefixIterator(key)
 	defer iter.Discard()
+	return entitiesByIndex(iter, cursor, n)
+}
+
+// EntitiesByComponentIndexRange returns entities with c values ordered by
+// their ix values, including only those entities with an ix value that encodes
+// to something greater than or equal to lower and less than upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to EntitiesByComponentIndexRange with the
+// same bounds would return the next n entities.
+func (s Partitioned) EntitiesByComponentIndexRange(c, ix Component, lower, upper []byte, cursor *IndexCursor, n int) (es []Entity, err error) {
+	key := make(Prefix, 8+2+8+2)
+	s.Partition.EncodeAt(key)
+	c.EncodeAt(key[8:])
+	Entity(0).EncodeAt(key[10:])
+	ix.EncodeAt(key[18:])
+	iter := s.RangeIterator(key, lower, upper)
+	defer iter.Discard()
+	return entitiesByIndex(iter, cursor, n)
+}
+
+// entitiesByIndex implements reading a page of entities from an iterator over
+// an index, for EntitiesByComponentIndex and EntitiesByComponentIndexRange.
+func entitiesByIndex(iter Iterator, cursor *IndexCursor, n int) (es []Entity, err error) {
 	iter.Seek(cursor.Key)
 	if !iter.Valid() {
 		return
@@ -256,6 +411,314 @@ func (s Partitioned) EntitiesByComponentIndex(c, ix Component, cursor *IndexCurs
 	return
 }
 
+// EntitiesByComponentIndexReverse returns entities with c values in reverse
+// order by their ix values.
+//
+// Entities that share the same ix value are also returned in descending order.
+//
+// A zero IndexCursor starts reading from the greatest ix value. Reading ends
+// when the length of the returned Entity slice is less than n. When reading is
+// not complete, cursor is updated such that using it in a subsequent call to
+// EntitiesByComponentIndexReverse would return the next n entities.
+//
+// A cursor used with EntitiesByComponentIndexReverse must not be used with
+// EntitiesByComponentIndex, or vice versa.
+func (s Partitioned) EntitiesByComponentIndexReverse(c, ix Component, cursor *IndexCursor, n int) (es []Entity, err error) {
+	key := make(Prefix, 8+2+8+2)
+	s.Partition.EncodeAt(key)
+	c.EncodeAt(key[8:])
+	Entity(0).EncodeAt(key[10:])
+	ix.EncodeAt(key[18:])
+	iter := s.ReversePrefixIterator(key)
+	defer iter.Discard()
+	var buf EntitySlice
+	for iter.Seek(cursor.Key); iter.Valid(); iter.Next() {
+		if err = iter.Value(buf.Decode); err != nil {
+			return
+		}
+		// A nil cursor.Key means "start at the end", so the key of the current
+		// index value must be recorded in a non-nil slice even when it is
+		// empty.
+		if cursor.Key == nil || !bytes.Equal(cursor.Key, iter.Key()) {
+			cursor.Key = append(make([]byte, 0, len(iter.Key())), iter.Key()...)
+			cursor.Offset = 0
+		}
+		for i := len(buf) - 1 - cursor.Offset; i >= 0; i-- {
+			if n > 0 && len(es) >= n {
+				return
+			}
+			es = append(es, buf[i])
+			cursor.Offset++
+		}
+	}
+	return
+}
+
+// IndexError describes an inconsistency between an index and the component
+// values it indexes.
+type IndexError struct {
+	Component, Index Component
+	Entity           Entity
+	// Value is the encoded index value.
+	Value []byte
+	// Missing is true if the index does not list Entity under Value even
+	// though its component value yields Value, and false if the index lists
+	// Entity under Value even though its component value does not yield it.
+	Missing bool
+}
+
+func (e *IndexError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("kv: index %d of component %d does not list entity %d under %q",
+			e.Index, e.Component, e.Entity, e.Value)
+	}
+	return fmt.Sprintf("kv: index %d of component %d lists entity %d under %q, which its value does not yield",
+		e.Index, e.Component, e.Entity, e.Value)
+}
+
+// VerifyComponentIndex checks that the ix index of c values is consistent
+// with the c values themselves, and returns an *IndexError describing the
+// first inconsistency it finds.
+//
+// The values func must decode a c value and return the encoded ix values
+// that it yields.
+//
+// Every entity listed in the index under some value must have a c value that
+// yields that value, and every entity with a c value must be listed in the
+// index under each of the values it yields.
+func (s Partitioned) VerifyComponentIndex(c, ix Component, values func([]byte) ([][]byte, error)) error {
+	return s.CheckComponentIndex(c, ix, values, func(ie *IndexError) error {
+		return ie
+	})
+}
+
+// CheckComponentIndex is like VerifyComponentIndex, except that it calls f
+// for each inconsistency it finds, and stops at the first error returned by
+// f.
+func (s Partitioned) CheckComponentIndex(c, ix Component, values func([]byte) ([][]byte, error), f func(*IndexError) error) error {
+	return s.checkIndex(c, ix, false, values, f)
+}
+
+func (s Partitioned) checkIndex(c, ix Component, scalable bool, values func([]byte) ([][]byte, error), f func(*IndexError) error) error {
+	prefix := make(Prefix, 8+2)
+	s.Partition.EncodeAt(prefix)
+	c.EncodeAt(prefix[8:])
+	index := ConcatByteSlices(prefix, Entity(0).Encode(), ix.Encode())
+
+	// Check that every entity listed in the index yields its index value.
+	if err := s.forEachIndexEntry(index, scalable, func(e Entity, iv []byte) error {
+		var found bool
+		if err := s.Get(ConcatByteSlices(prefix, e.Encode()), func(bs []byte) error {
+			if len(bs) == 0 {
+				return nil
+			}
+			ivs, err := values(bs)
+			for _, v := range ivs {
+				found = found || bytes.Equal(v, iv)
+			}
+			return err
+		}); err != nil {
+			return err
+		} else if !found {
+			return f(&IndexError{c, ix, e, iv, false})
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Check that every entity with a c value is listed under each of the
+	// index values it yields.
+	return s.forEachComponentValue(prefix, values, func(e Entity, ivs [][]byte) error {
+		for _, iv := range ivs {
+			if ok, err := s.indexContains(index, scalable, iv, e); err != nil {
+				return err
+			} else if !ok {
+				if err := f(&IndexError{c, ix, e, iv, true}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RebuildComponentIndex deletes every entry in the ix index of c values and
+// then indexes each c value again.
+//
+// The values func must decode a c value and return the encoded ix values
+// that it yields.
+func (s Partitioned) RebuildComponentIndex(c, ix Component, values func([]byte) ([][]byte, error)) error {
+	return s.rebuildIndex(c, ix, false, values)
+}
+
+func (s Partitioned) rebuildIndex(c, ix Component, scalable bool, values func([]byte) ([][]byte, error)) error {
+	prefix := make(Prefix, 8+2)
+	s.Partition.EncodeAt(prefix)
+	c.EncodeAt(prefix[8:])
+	index := ConcatByteSlices(prefix, Entity(0).Encode(), ix.Encode())
+	iter := s.PrefixIterator(index)
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		if err := s.Delete(ConcatByteSlices(index, iter.Key())); err != nil {
+			iter.Discard()
+			return err
+		}
+	}
+	iter.Discard()
+	return s.forEachComponentValue(prefix, values, func(e Entity, ivs [][]byte) error {
+		for _, iv := range ivs {
+			if err := s.insertIndexEntry(index, scalable, iv, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UniqueIndexError is returned when setting a component value would list an
+// entity in a unique index under a value that is already held by another
+// entity.
+type UniqueIndexError struct {
+	Component, Index Component
+	// Entity is the entity whose component value was not set.
+	Entity Entity
+	// Holder is the entity that already holds Value.
+	Holder Entity
+	// Value is the encoded index value.
+	Value []byte
+}
+
+func (e *UniqueIndexError) Error() string {
+	return fmt.Sprintf("kv: unique index %d of component %d already lists entity %d under %q, so entity %d cannot be listed",
+		e.Index, e.Component, e.Holder, e.Value, e.Entity)
+}
+
+// CheckUniqueIndex returns a *UniqueIndexError if the ix index of c values
+// lists any entity other than e under the encoded index value v.
+func (s Partitioned) CheckUniqueIndex(c, ix Component, e Entity, v []byte) error {
+	es, err := s.entitiesMatchingIndex(c, ix, v)
+	if err != nil {
+		return err
+	}
+	for _, holder := range es {
+		if holder != e {
+			return &UniqueIndexError{c, ix, e, holder, ConcatByteSlices(v)}
+		}
+	}
+	return nil
+}
+
+// LookupComponentIndex returns the entity listed in the ix index of c values
+// under the encoded index value v, or zero if there is none.
+//
+// If the index lists more than one entity under v, as it may if it became
+// unique only after values were indexed, LookupComponentIndex returns the
+// least of them.
+func (s Partitioned) LookupComponentIndex(c, ix Component, v []byte) (Entity, error) {
+	es, err := s.entitiesMatchingIndex(c, ix, v)
+	if err != nil || len(es) == 0 {
+		return 0, err
+	}
+	return es[0], nil
+}
+
+func (s Partitioned) entitiesMatchingIndex(c, ix Component, v []byte) (EntitySlice, error) {
+	var es EntitySlice
+	return es, s.Get(append(s.indexPrefix(c, ix), v...), es.Decode)
+}
+
+// forEachIndexEntry calls f for each entity listed under each value in the
+// index identified by the given key prefix, and stops at the first error
+// returned by f.
+func (s Partitioned) forEachIndexEntry(index Prefix, scalable bool, f func(Entity, []byte) error) error {
+	iter := s.PrefixIterator(index)
+	defer iter.Discard()
+	var es EntitySlice
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		if scalable {
+			iv, e, err := splitScalableIndexKey(iter.Key())
+			if err != nil {
+				return err
+			} else if err = f(e, iv); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := iter.Value(es.Decode); err != nil {
+			return err
+		}
+		iv := ConcatByteSlices(iter.Key())
+		for _, e := range es {
+			if err := f(e, iv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexContains returns true if the index identified by the given key prefix
+// lists e under the encoded index value iv.
+func (s Partitioned) indexContains(index Prefix, scalable bool, iv []byte, e Entity) (bool, error) {
+	if scalable {
+		var found bool
+		err := s.Get(scalableIndexKey(index, iv, e), func(bs []byte) error {
+			found = len(bs) > 0
+			return nil
+		})
+		return found, err
+	}
+	var es EntitySlice
+	if err := s.Get(ConcatByteSlices(index, iv), es.Decode); err != nil {
+		return false, err
+	}
+	i := es.Search(e)
+	return i < len(es) && es[i] == e, nil
+}
+
+// insertIndexEntry lists e under the encoded index value iv in the index
+// identified by the given key prefix.
+func (s Partitioned) insertIndexEntry(index Prefix, scalable bool, iv []byte, e Entity) error {
+	if scalable {
+		return s.Set(scalableIndexKey(index, iv, e), scalableIndexValue)
+	}
+	k := ConcatByteSlices(index, iv)
+	var es EntitySlice
+	if err := s.Get(k, es.Decode); err != nil {
+		return err
+	}
+	if es.Insert(e) {
+		return s.Set(k, es.Encode())
+	}
+	return nil
+}
+
+// forEachComponentValue calls f with each entity that has a value for the
+// component identified by the given key prefix, along with the index values
+// returned by values for that component value, and stops at the first error
+// returned by f.
+func (s Partitioned) forEachComponentValue(prefix Prefix, values func([]byte) ([][]byte, error), f func(Entity, [][]byte) error) error {
+	iter := s.PrefixIterator(prefix)
+	defer iter.Discard()
+	for iter.Seek(Entity(1).Encode()); iter.Valid(); iter.Next() {
+		var e Entity
+		if len(iter.Key()) != 8 || e.Decode(iter.Key()) != nil {
+			continue
+		}
+		var ivs [][]byte
+		if err := iter.Value(func(bs []byte) (err error) {
+			ivs, err = values(bs)
+			return
+		}); err != nil {
+			return err
+		}
+		if err := f(e, ivs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ConcatByteSlices returns a concatentation of the given byte slices.
 //
 // ConcatByteSlices is intended for constructing keys from an optional prefix
@@ -275,6 +738,20 @@ func ConcatByteSlices(bss ...[]byte) []byte {
 	return key
 }
 
+// PrefixEnd returns the smallest key that is greater than every key that
+// begins with prefix, or nil if there is no such key, so that backends can
+// turn a prefix into a range of keys.
+func PrefixEnd(prefix []byte) []byte {
+	end := ConcatByteSlices(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
 // Encoder is an interface implemented by any type that is to be stored in the
 // key or value of a key-value pair.
 type Encoder interface {
@@ -343,6 +820,12 @@ func (c Component) Encode() []byte {
 	return bs[:]
 }
 
+// Decode decodes the first two bytes of src into c.
+func (c *Component) Decode(src []byte) error {
+	*c = Component(binary.BigEndian.Uint16(src))
+	return nil
+}
+
 // Entity is an identifier that can be associated with Go values via
 // Components, and
 //
@@ -455,10 +938,15 @@ func (es EntitySlice) Encode() []byte {
 }
 
 // Decode decodes src into es.
+//
+// The length of es after decoding always matches the number of entities
+// encoded in src, even when es is reused.
 func (es *EntitySlice) Decode(src []byte) error {
 	ln := len(src) / 8
-	if len(*es) < ln {
+	if cap(*es) < ln {
 		*es = make([]Entity, ln)
+	} else {
+		*es = (*es)[:ln]
 	}
 	for i := 0; i < ln; i++ {
 		(*es)[i].Decode(src[i*8:])
@@ -15,8 +15,10 @@
 package kv
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 var (
@@ -119,6 +121,21 @@ func TestPrefixAppendComponent(t *testing.T) {
 	}
 }
 
+func TestPrefixEnd(t *testing.T) {
+	for _, test := range []struct {
+		Prefix, Want []byte
+	}{
+		{nil, nil},
+		{[]byte{0xff, 0xff}, nil},
+		{[]byte{1, 2}, []byte{1, 3}},
+		{[]byte{1, 0xff}, []byte{2}},
+	} {
+		if got := PrefixEnd(test.Prefix); !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("PrefixEnd(%x) returned %x, want %x", test.Prefix, got, test.Want)
+		}
+	}
+}
+
 func TestComponentEncode(t *testing.T) {
 	for _, c := range testComponents {
 		t.Run(c.Name, func(t *testing.T) {
@@ -292,3 +309,88 @@ func TestStringSliceEncodeDecode(t *testing.T) {
 		}
 	}
 }
+
+func TestEntitySliceDecodeReuse(t *testing.T) {
+	es := EntitySlice{1, 2, 3}
+	if err := es.Decode(EntitySlice{42}.Encode()); err != nil {
+		t.Fatal(err)
+	} else if !es.Equal(EntitySlice{42}) {
+		t.Error("want", EntitySlice{42}, "got", es)
+	}
+	if err := es.Decode(nil); err != nil {
+		t.Fatal(err)
+	} else if len(es) != 0 {
+		t.Error("want empty slice, got", es)
+	}
+}
+
+type conflictDB struct {
+	conflicts int
+	commits   int
+}
+
+func (db *conflictDB) NewTxn(update bool) TxnCommitDiscarder {
+	return conflictTxn{db}
+}
+
+func (db *conflictDB) Close() error { return nil }
+
+type conflictTxn struct{ db *conflictDB }
+
+func (conflictTxn) Alloc() (Entity, error)                             { return 0, nil }
+func (conflictTxn) Set(key, value []byte) error                        { return nil }
+func (conflictTxn) Delete(key []byte) error                            { return nil }
+func (conflictTxn) Get(key []byte, f func([]byte) error) error         { return f(nil) }
+func (conflictTxn) PrefixIterator(prefix []byte) Iterator              { return nil }
+func (conflictTxn) ReversePrefixIterator(prefix []byte) Iterator       { return nil }
+func (conflictTxn) RangeIterator(prefix, lower, upper []byte) Iterator { return nil }
+func (conflictTxn) Discard()                                           {}
+
+func (s conflictTxn) Commit() error {
+	if s.db.conflicts > 0 {
+		s.db.conflicts--
+		return ErrConflict
+	}
+	s.db.commits++
+	return nil
+}
+
+func TestUpdate(t *testing.T) {
+	defer func(d time.Duration) { updateMinBackoff = d }(updateMinBackoff)
+	updateMinBackoff = time.Microsecond
+	for _, test := range []struct {
+		Name      string
+		Conflicts int
+		Err       error
+		Want      error
+		Calls     int
+		Commits   int
+	}{
+		{"no conflict", 0, nil, nil, 1, 1},
+		{"some conflicts", 3, nil, nil, 4, 1},
+		{"too many conflicts", updateAttempts, nil, ErrConflict, updateAttempts, 0},
+		{"error", 3, errors.New("oops"), nil, 1, 0},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			db := &conflictDB{conflicts: test.Conflicts}
+			calls := 0
+			err := Update(db, func(Txn) error {
+				calls++
+				return test.Err
+			})
+			want := test.Want
+			if test.Err != nil {
+				want = test.Err
+			}
+			if err != want {
+				t.Errorf("got error %v, want %v", err, want)
+			}
+			if calls != test.Calls {
+				t.Errorf("got %v calls, want %v", calls, test.Calls)
+			}
+			if db.commits != test.Commits {
+				t.Errorf("got %v commits, want %v", db.commits, test.Commits)
+			}
+		})
+	}
+}
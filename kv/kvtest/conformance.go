@@ -0,0 +1,531 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvtest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/note-maps/kv"
+)
+
+// RunConformance runs tests that every implementation of kv.DB should pass,
+// each one against a new, empty kv.DB returned by newDB, which is passed the
+// *testing.T of the test that will use the kv.DB.
+//
+// Backends may serialize update transactions, so the tests never wait for
+// an update transaction while holding another in the same goroutine. Backends
+// may also reserve keys for their own use, as kv/badger does for its entity
+// sequence, so the tests only use keys that start with a lowercase letter.
+func RunConformance(t *testing.T, newDB func(*testing.T) kv.DB) {
+	for _, test := range []struct {
+		name string
+		f    func(*testing.T, kv.DB)
+	}{
+		{"Alloc", testAlloc},
+		{"GetMissing", testGetMissing},
+		{"IteratorKey", testIteratorKey},
+		{"Seek", testSeek},
+		{"ReverseSeek", testReverseSeek},
+		{"RangeSeek", testRangeSeek},
+		{"DeleteDuringIteration", testDeleteDuringIteration},
+		{"ReadOwnWrites", testReadOwnWrites},
+		{"Has", testHas},
+		{"CommitVisibility", testCommitVisibility},
+		{"Discard", testDiscard},
+		{"ReadOnly", testReadOnly},
+		{"CommitConflict", testCommitConflict},
+		{"Watch", testWatch},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := newDB(t)
+			defer db.Close()
+			test.f(t, db)
+		})
+	}
+}
+
+// set stores key-value pairs in a new update transaction in db, where each
+// key is also its own value.
+func set(t *testing.T, db kv.DB, keys ...string) {
+	t.Helper()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	for _, k := range keys {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get returns the value of key in txn as a string.
+func get(t *testing.T, txn kv.Txn, key string) string {
+	t.Helper()
+	var v string
+	if err := txn.Get([]byte(key), func(bs []byte) error {
+		v = string(bs)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// keys collects the keys visited by iter after seeking to seek, and discards
+// iter.
+func keys(iter kv.Iterator, seek []byte) []string {
+	defer iter.Discard()
+	var ks []string
+	for iter.Seek(seek); iter.Valid(); iter.Next() {
+		ks = append(ks, string(iter.Key()))
+	}
+	return ks
+}
+
+func testAlloc(t *testing.T, db kv.DB) {
+	const n = 100
+	var (
+		wg sync.WaitGroup
+		ch = make(chan kv.Entity, n)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			txn := db.NewTxn(false)
+			defer txn.Discard()
+			for k := 0; k < n; k++ {
+				e, err := txn.Alloc()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ch <- e
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		txn := db.NewTxn(true)
+		defer txn.Discard()
+		for k := 0; k < n; k++ {
+			e, err := txn.Alloc()
+			if err != nil {
+				t.Error(err)
+				break
+			}
+			ch <- e
+		}
+		if err := txn.Commit(); err != nil {
+			t.Error(err)
+		}
+		close(ch)
+	}()
+	done := make(map[kv.Entity]bool)
+	for e := range ch {
+		if e == 0 {
+			t.Error("allocated zero")
+		} else if done[e] {
+			t.Error("allocated twice:", e)
+		}
+		done[e] = true
+	}
+}
+
+func testGetMissing(t *testing.T, db kv.DB) {
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	called := false
+	if err := txn.Get([]byte("missing"), func(bs []byte) error {
+		called = true
+		if len(bs) != 0 {
+			t.Errorf("got %q for a missing key", bs)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("Get did not call f for a missing key")
+	}
+	want := errors.New("from f")
+	if err := txn.Get([]byte("missing"), func([]byte) error {
+		return want
+	}); err == nil {
+		t.Error("Get did not return an error from f")
+	}
+}
+
+func testIteratorKey(t *testing.T, db kv.DB) {
+	set(t, db, "a", "ab0", "ab1", "ac0", "b0")
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	for _, test := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"ab", []string{"0", "1"}},
+		{"a", []string{"", "b0", "b1", "c0"}},
+		{"ac0", []string{""}},
+		{"c", nil},
+	} {
+		got := keys(txn.PrefixIterator([]byte(test.prefix)), nil)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: want %q, got %q", test.prefix, test.want, got)
+		}
+	}
+	iter := txn.PrefixIterator([]byte("ab"))
+	defer iter.Discard()
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		k := string(iter.Key())
+		if err := iter.Value(func(v []byte) error {
+			if string(v) != "ab"+k {
+				t.Errorf("%q: got value %q", k, v)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testSeek(t *testing.T, db kv.DB) {
+	set(t, db, "a0", "a2", "a4", "b0")
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	for _, test := range []struct {
+		seek []byte
+		want []string
+	}{
+		{nil, []string{"0", "2", "4"}},
+		{[]byte{}, []string{"0", "2", "4"}},
+		{[]byte("2"), []string{"2", "4"}},
+		{[]byte("3"), []string{"4"}},
+		{[]byte("5"), nil},
+	} {
+		got := keys(txn.PrefixIterator([]byte("a")), test.seek)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Seek(%q): want %q, got %q", test.seek, test.want, got)
+		}
+	}
+	// Seeking again repositions an iterator.
+	iter := txn.PrefixIterator([]byte("a"))
+	defer iter.Discard()
+	iter.Seek([]byte("4"))
+	iter.Seek([]byte("1"))
+	if !iter.Valid() || string(iter.Key()) != "2" {
+		t.Error("Seek did not reposition the iterator")
+	}
+}
+
+func testReverseSeek(t *testing.T, db kv.DB) {
+	set(t, db, "a0", "a2", "a4", "a\xff", "b0")
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	for _, test := range []struct {
+		seek []byte
+		want []string
+	}{
+		{nil, []string{"\xff", "4", "2", "0"}},
+		{[]byte{}, nil},
+		{[]byte("2"), []string{"2", "0"}},
+		{[]byte("3"), []string{"2", "0"}},
+		{[]byte("\xff\xff"), []string{"\xff", "4", "2", "0"}},
+	} {
+		got := keys(txn.ReversePrefixIterator([]byte("a")), test.seek)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Seek(%q): want %q, got %q", test.seek, test.want, got)
+		}
+	}
+}
+
+func testRangeSeek(t *testing.T, db kv.DB) {
+	set(t, db, "a0", "a1", "a2", "a3", "b0")
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	for _, test := range []struct {
+		lower, upper []byte
+		seek         []byte
+		want         []string
+	}{
+		{nil, nil, nil, []string{"0", "1", "2", "3"}},
+		{[]byte("1"), []byte("3"), nil, []string{"1", "2"}},
+		{[]byte("1"), []byte("3"), []byte("0"), []string{"1", "2"}},
+		{[]byte("1"), []byte("3"), []byte("2"), []string{"2"}},
+		{[]byte("10"), nil, nil, []string{"2", "3"}},
+		{nil, []byte("1"), nil, []string{"0"}},
+		{[]byte("2"), []byte("2"), nil, nil},
+	} {
+		got := keys(txn.RangeIterator([]byte("a"), test.lower, test.upper), test.seek)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("[%q,%q) Seek(%q): want %q, got %q",
+				test.lower, test.upper, test.seek, test.want, got)
+		}
+	}
+}
+
+func testDeleteDuringIteration(t *testing.T, db kv.DB) {
+	var want []string
+	for i := 0; i < 300; i++ {
+		want = append(want, fmt.Sprintf("%03d", i))
+	}
+	all := make([]string, len(want))
+	for i, k := range want {
+		all[i] = "a" + k
+	}
+	set(t, db, append(all, "b")...)
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	var got []string
+	iter := txn.PrefixIterator([]byte("a"))
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		k := string(iter.Key())
+		got = append(got, k)
+		if err := txn.Delete([]byte("a" + k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	iter.Discard()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("visited %v of %v keys while deleting", len(got), len(want))
+	}
+	if got := keys(txn.PrefixIterator([]byte("a")), nil); got != nil {
+		t.Errorf("found %q after deleting them", got)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	r := db.NewTxn(false)
+	defer r.Discard()
+	if got := keys(r.PrefixIterator([]byte("a")), nil); got != nil {
+		t.Errorf("found %q after commit", got)
+	}
+	if got := get(t, r, "b"); got != "b" {
+		t.Errorf("got %q for a key that was not deleted, want %q", got, "b")
+	}
+}
+
+func testReadOwnWrites(t *testing.T, db kv.DB) {
+	set(t, db, "a0", "a1")
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	if err := txn.Set([]byte("a2"), []byte("a2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Delete([]byte("a0")); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, txn, "a2"); got != "a2" {
+		t.Errorf("got %q for own write, want %q", got, "a2")
+	}
+	if got := get(t, txn, "a0"); got != "" {
+		t.Errorf("got %q for own deletion, want nothing", got)
+	}
+	want := []string{"1", "2"}
+	if got := keys(txn.PrefixIterator([]byte("a")), nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func testHas(t *testing.T, db kv.DB) {
+	set(t, db, "a", "ab", "c")
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	if err := txn.Set([]byte("b"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Delete([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		key  string
+		want bool
+	}{
+		{"a", true},
+		{"ab", true},
+		{"aa", false},
+		{"b", true},
+		{"c", false},
+		{"d", false},
+	} {
+		if got, err := kv.Has(txn, []byte(test.key)); err != nil {
+			t.Error(err)
+		} else if got != test.want {
+			t.Errorf("Has(%q) returned %v, want %v", test.key, got, test.want)
+		}
+	}
+}
+
+func testCommitVisibility(t *testing.T, db kv.DB) {
+	before := db.NewTxn(false)
+	defer before.Discard()
+	set(t, db, "k")
+	if got := get(t, before, "k"); got != "" {
+		t.Errorf("got %q in a transaction created before commit", got)
+	}
+	if got := keys(before.PrefixIterator([]byte("k")), nil); got != nil {
+		t.Errorf("iterated over %q in a transaction created before commit", got)
+	}
+	after := db.NewTxn(false)
+	defer after.Discard()
+	if got := get(t, after, "k"); got != "k" {
+		t.Errorf("got %q in a transaction created after commit, want %q", got, "k")
+	}
+}
+
+func testDiscard(t *testing.T, db kv.DB) {
+	txn := db.NewTxn(true)
+	if err := txn.Set([]byte("k"), []byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	txn.Discard()
+	r := db.NewTxn(false)
+	defer r.Discard()
+	if got := get(t, r, "k"); got != "" {
+		t.Errorf("got %q after Discard", got)
+	}
+}
+
+func testReadOnly(t *testing.T, db kv.DB) {
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	if err := txn.Set([]byte("k"), []byte("k")); err == nil {
+		t.Error("Set succeeded in a read-only transaction")
+	}
+	if err := txn.Delete([]byte("k")); err == nil {
+		t.Error("Delete succeeded in a read-only transaction")
+	}
+}
+
+// testCommitConflict checks that a transaction does not commit after reading
+// a key that was modified since it began. Backends that serialize update
+// transactions avoid the conflict by making the second one wait instead.
+func testCommitConflict(t *testing.T, db kv.DB) {
+	a := db.NewTxn(true)
+	defer a.Discard()
+	get(t, a, "k")
+	if err := a.Set([]byte("x"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b := db.NewTxn(true)
+		defer b.Discard()
+		if err := b.Set([]byte("k"), []byte("b")); err != nil {
+			t.Error(err)
+		} else if err := b.Commit(); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+		if err := a.Commit(); err != kv.ErrConflict {
+			t.Errorf("got %v, want %v", err, kv.ErrConflict)
+		}
+	case <-time.After(100 * time.Millisecond):
+		// b is probably waiting for a.
+		if err := a.Commit(); err != nil && err != kv.ErrConflict {
+			t.Error(err)
+		}
+		<-done
+	}
+}
+
+// testWatch checks that a subscription reports the sets and deletes made by
+// each commit to keys with its prefix, in the order in which they were
+// committed, and nothing else.
+func testWatch(t *testing.T, db kv.DB) {
+	sub, err := kv.Watch(db, []byte("w"))
+	if err == kv.ErrWatchUnsupported {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	set(t, db, "wa", "wb", "x")
+	discarded := db.NewTxn(true)
+	if err := discarded.Set([]byte("wz"), []byte("wz")); err != nil {
+		t.Fatal(err)
+	}
+	discarded.Discard()
+	set(t, db, "x")
+	txn := db.NewTxn(true)
+	if err := txn.Delete([]byte("wa")); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Set([]byte("wc"), []byte("wc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]kv.Change{
+		{{Key: []byte("wa"), Value: []byte("wa")}, {Key: []byte("wb"), Value: []byte("wb")}},
+		{{Key: []byte("wa"), Deleted: true}, {Key: []byte("wc"), Value: []byte("wc")}},
+	}
+	if batcher, ok := db.(kv.Batcher); ok {
+		batch := batcher.NewBatch()
+		if err := batch.Set([]byte("wd"), []byte("wd")); err != nil {
+			t.Fatal(err)
+		}
+		if err := batch.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, []kv.Change{{Key: []byte("wd"), Value: []byte("wd")}})
+	}
+	for _, want := range want {
+		select {
+		case got, ok := <-sub.Changes():
+			if !ok {
+				t.Fatalf("subscription ended early: %v", sub.Err())
+			}
+			if g, w := formatChanges(got), formatChanges(want); g != w {
+				t.Errorf("want %s, got %s", w, g)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", formatChanges(want))
+		}
+	}
+	sub.Close()
+	for range sub.Changes() {
+		// Changes that were not received before Close may be dropped or
+		// delivered, but the channel must be closed.
+	}
+	if err := sub.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+// formatChanges returns a string describing the changes made by a commit.
+func formatChanges(cs []kv.Change) string {
+	var ss []string
+	for _, c := range cs {
+		if c.Deleted {
+			ss = append(ss, fmt.Sprintf("delete %q", c.Key))
+		} else {
+			ss = append(ss, fmt.Sprintf("set %q=%q", c.Key, c.Value))
+		}
+	}
+	return "[" + strings.Join(ss, ", ") + "]"
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvtest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/note-maps/kv"
+)
+
+// crashEnv is the name of an environment variable through which
+// CrashRecovery.Run tells a child process where to find the database it
+// should write to.
+const crashEnv = "KVTEST_CRASH_DIR"
+
+// crashReady is written by a child process once it has opened its database.
+const crashReady = "kvtest: ready"
+
+// CrashRecovery describes a test of whether a database stays consistent when
+// the process writing to it is killed at arbitrary points.
+type CrashRecovery struct {
+	// Open opens the database in dir.
+	Open func(dir string) (kv.DB, error)
+
+	// Write is called repeatedly in a child process until the child is
+	// killed.
+	Write func(db kv.DB) error
+
+	// Check is called with the reopened database after each crash, and
+	// should return an error if the database is inconsistent.
+	Check func(db kv.DB) error
+
+	// Crashes is the number of times a writer will be killed.
+	Crashes int
+
+	// MaxDelay is the longest time a writer may run before it is killed.
+	MaxDelay time.Duration
+}
+
+// Run runs the test described by c.
+//
+// Run forks the test binary to run the current test again in a child
+// process, in which Run calls c.Write until the child is killed. In the
+// parent process, Run kills each child after a random delay, and then reopens
+// the database and calls c.Check.
+//
+// Run skips the test in short mode.
+func (c CrashRecovery) Run(t *testing.T) {
+	if dir := os.Getenv(crashEnv); dir != "" {
+		c.write(dir)
+		return
+	}
+	if testing.Short() {
+		t.Skip("skipping crash recovery test in short mode")
+	}
+	dir, err := ioutil.TempDir("", "kvtest-crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i := 0; i < c.Crashes; i++ {
+		c.crash(t, dir)
+		db, err := c.Open(dir)
+		if err != nil {
+			t.Fatalf("crash %d: %v", i, err)
+		}
+		err = c.Check(db)
+		db.Close()
+		if err != nil {
+			t.Fatalf("crash %d: %v", i, err)
+		}
+	}
+}
+
+// crash starts a child process writing to the database in dir, and kills it
+// after a random delay.
+func (c CrashRecovery) crash(t *testing.T, dir string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run="+testPattern(t.Name()))
+	cmd.Env = append(os.Environ(), crashEnv+"="+dir)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ready := false
+	for scanner := bufio.NewScanner(stdout); !ready && scanner.Scan(); {
+		ready = scanner.Text() == crashReady
+	}
+	if ready && c.MaxDelay > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(c.MaxDelay))))
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	if !ready || cmd.ProcessState.Exited() {
+		t.Fatalf("writer stopped before it was killed: %s", stderr.Bytes())
+	}
+}
+
+// write runs in a child process, calling c.Write until the process is
+// killed.
+func (c CrashRecovery) write(dir string) {
+	db, err := c.Open(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(crashReady)
+	for {
+		if err := c.Write(db); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// testPattern returns a -test.run pattern that matches only the test with the
+// given name.
+func testPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(parts[i]) + "$"
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,406 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvtest
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/memory"
+)
+
+// Method is a set of methods of kv.Txn and kv.Iterator in which a Flaky may
+// inject faults.
+type Method uint
+
+const (
+	// AllocMethod is kv.Txn.Alloc.
+	AllocMethod Method = 1 << iota
+	// GetMethod is kv.Txn.Get.
+	GetMethod
+	// SetMethod is kv.Txn.Set.
+	SetMethod
+	// DeleteMethod is kv.Txn.Delete.
+	DeleteMethod
+	// IteratorMethod is any of kv.Txn.PrefixIterator,
+	// kv.Txn.ReversePrefixIterator, and kv.Txn.RangeIterator.
+	//
+	// These methods cannot return an error, so when one of them fails it
+	// returns an iterator whose Value method always returns the error.
+	IteratorMethod
+	// ValueMethod is kv.Iterator.Value.
+	ValueMethod
+	// CommitMethod is kv.TxnCommitDiscarder.Commit.
+	CommitMethod
+
+	// ErrorMethods includes every method that can return an error.
+	ErrorMethods = AllocMethod | GetMethod | SetMethod | DeleteMethod |
+		ValueMethod | CommitMethod
+
+	// AllMethods includes every method in which Flaky may inject faults.
+	AllMethods = ErrorMethods | IteratorMethod
+)
+
+// FlakyConfig describes the faults to be injected by a Flaky.
+type FlakyConfig struct {
+	// FailAt is the count of error checks at which a call will fail. If
+	// FailAt is zero, no call will fail because of the count of error
+	// checks.
+	FailAt int
+
+	// Methods selects the methods that count as error checks. Calls to other
+	// methods are passed through unchanged. If Methods is zero, it is taken
+	// to be ErrorMethods.
+	Methods Method
+
+	// KeyPrefix, if not nil, makes every error check fail when the key it
+	// involves begins with KeyPrefix. Iterators involve the key of their
+	// prefix, and Iterator.Value involves the full key of the current
+	// key-value pair.
+	KeyPrefix []byte
+
+	// Latency is added to every error check, which can help to widen the
+	// window for races between concurrent transactions.
+	Latency time.Duration
+
+	// PartialCommit makes a failing Commit apply the first half of the
+	// writes made in its transaction before returning an error, simulating a
+	// store that fails part way through writing a transaction. It has no
+	// effect on a Flaky that was not created by a FlakyDB.
+	PartialCommit bool
+}
+
+// Flake is the type of error returned by a Flaky store.
+type Flake int
+
+// Error returns a simple human-readable string describing this flake.
+func (f Flake) Error() string { return fmt.Sprintf("flake#%d", int(f)) }
+
+// faults counts error checks and decides which of them fail. It may be shared
+// by several Flaky transactions.
+type faults struct {
+	FlakyConfig
+	errCheckCount int32
+
+	mutex      sync.Mutex
+	stackTrace string
+}
+
+func newFaults(c FlakyConfig) *faults {
+	if c.Methods == 0 {
+		c.Methods = ErrorMethods
+	}
+	return &faults{FlakyConfig: c}
+}
+
+// check counts an error check on a call to m involving key, and returns an
+// error if the call should fail.
+func (f *faults) check(m Method, key []byte) error {
+	if f.Methods&m == 0 {
+		return nil
+	}
+	if f.Latency > 0 {
+		time.Sleep(f.Latency)
+	}
+	n := int(atomic.AddInt32(&f.errCheckCount, 1))
+	if n != f.FailAt && (f.KeyPrefix == nil || !bytes.HasPrefix(key, f.KeyPrefix)) {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stackTrace = string(debug.Stack())
+	return Flake(n)
+}
+
+// ErrCheckCount returns the total number of error checks counted so far.
+func (f *faults) ErrCheckCount() int {
+	return int(atomic.LoadInt32(&f.errCheckCount))
+}
+
+// StackTrace returns a formatted stacktrace taken from the moment an error was
+// most recently returned.
+func (f *faults) StackTrace() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.stackTrace
+}
+
+// NewFlaky returns a new Flaky that wraps the given a kv.Txn and will fail
+// when the count of error checks reaches failAtCount.
+//
+// If failAtCount is zero, the resulting Flaky will never deliberately return
+// an error.
+func NewFlaky(t *testing.T, failAtCount int) *Flaky {
+	return NewFlakyTxn(memory.New(), FlakyConfig{FailAt: failAtCount})
+}
+
+// NewFlakyTxn returns a new Flaky that wraps txn and injects faults as
+// described by c.
+func NewFlakyTxn(txn kv.Txn, c FlakyConfig) *Flaky {
+	return &Flaky{Txn: txn, faults: newFaults(c)}
+}
+
+// Flaky is a kv.Txn implemention that injects faults into calls to the
+// kv.Txn it wraps.
+//
+// Flaky counts each call to any method that could return an error as another
+// error check. When, during a call to such a method, the number of error
+// checks reaches a preset value, then that call will return an error. A
+// FlakyConfig can restrict error checks to a chosen set of methods, fail
+// every call involving keys with a given prefix, and add latency.
+//
+// Usage might involve running a test once with failAtCount set to zero to test
+// the successful case and count the number of error checks, and then to run it
+// again for each possible value of failAtCount from 1 to the number of error
+// checks, to make sure all errors are handled appropriately.
+type Flaky struct {
+	kv.Txn
+	*faults
+
+	// db is set when this Flaky was created by a FlakyDB, so that Commit can
+	// simulate a partial commit by replaying writes into a new transaction.
+	db     kv.DB
+	writes []write
+}
+
+// write is a recorded write, where a nil value means deletion.
+type write struct{ key, value []byte }
+
+func (s *Flaky) record(key, value []byte) {
+	if s.db != nil && s.PartialCommit {
+		s.writes = append(s.writes, write{
+			kv.ConcatByteSlices(key),
+			kv.ConcatByteSlices(value),
+		})
+	}
+}
+
+// Alloc fails if the count of error checks has reached failAtCount.
+func (s *Flaky) Alloc() (kv.Entity, error) {
+	if err := s.check(AllocMethod, nil); err != nil {
+		return 0, err
+	}
+	return s.Txn.Alloc()
+}
+
+// Get fails if the count of error checks has reached failAtCount.
+func (s *Flaky) Get(k []byte, f func([]byte) error) error {
+	if err := s.check(GetMethod, k); err != nil {
+		return err
+	}
+	return s.Txn.Get(k, f)
+}
+
+// Set fails if the count of error checks has reached failAtCount.
+func (s *Flaky) Set(k, v []byte) error {
+	if err := s.check(SetMethod, k); err != nil {
+		return err
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	s.record(k, v)
+	return s.Txn.Set(k, v)
+}
+
+// Delete fails if the count of error checks has reached failAtCount.
+func (s *Flaky) Delete(k []byte) error {
+	if err := s.check(DeleteMethod, k); err != nil {
+		return err
+	}
+	s.record(k, nil)
+	return s.Txn.Delete(k)
+}
+
+// PrefixIterator returns an iterator whose Value method may fail.
+func (s *Flaky) PrefixIterator(prefix []byte) kv.Iterator {
+	return s.iterator(prefix, s.Txn.PrefixIterator(prefix))
+}
+
+// ReversePrefixIterator returns an iterator whose Value method may fail.
+func (s *Flaky) ReversePrefixIterator(prefix []byte) kv.Iterator {
+	return s.iterator(prefix, s.Txn.ReversePrefixIterator(prefix))
+}
+
+// RangeIterator returns an iterator whose Value method may fail.
+func (s *Flaky) RangeIterator(prefix, lower, upper []byte) kv.Iterator {
+	return s.iterator(prefix, s.Txn.RangeIterator(prefix, lower, upper))
+}
+
+func (s *Flaky) iterator(prefix []byte, iter kv.Iterator) kv.Iterator {
+	return &flakyIterator{
+		Iterator: iter,
+		faults:   s.faults,
+		prefix:   prefix,
+		err:      s.check(IteratorMethod, prefix),
+	}
+}
+
+// Commit fails if the count of error checks has reached failAtCount, and
+// otherwise commits the wrapped kv.Txn if it has a Commit method.
+//
+// When Commit fails in a Flaky created by a FlakyDB configured for partial
+// commits, the first half of the writes made in the transaction are committed
+// in a new transaction before the error is returned.
+func (s *Flaky) Commit() error {
+	if err := s.check(CommitMethod, nil); err != nil {
+		s.Discard()
+		if s.db != nil && s.PartialCommit {
+			s.commitPartial()
+		}
+		return err
+	}
+	if c, ok := s.Txn.(kv.TxnCommitDiscarder); ok {
+		return c.Commit()
+	}
+	return nil
+}
+
+func (s *Flaky) commitPartial() {
+	txn := s.db.NewTxn(true)
+	defer txn.Discard()
+	for _, w := range s.writes[:len(s.writes)/2] {
+		var err error
+		if w.value == nil {
+			err = txn.Delete(w.key)
+		} else {
+			err = txn.Set(w.key, w.value)
+		}
+		if err != nil {
+			return
+		}
+	}
+	txn.Commit()
+}
+
+// Discard discards the wrapped kv.Txn if it has a Discard method.
+func (s *Flaky) Discard() {
+	if d, ok := s.Txn.(kv.TxnDiscarder); ok {
+		d.Discard()
+	}
+}
+
+type flakyIterator struct {
+	kv.Iterator
+	*faults
+	prefix []byte
+	err    error
+}
+
+// Value fails if the iterator was created by a failing call, or if the count
+// of error checks has reached failAtCount.
+func (i *flakyIterator) Value(f func([]byte) error) error {
+	if i.err != nil {
+		return i.err
+	}
+	if err := i.check(ValueMethod, kv.ConcatByteSlices(i.prefix, i.Key())); err != nil {
+		return err
+	}
+	return i.Iterator.Value(f)
+}
+
+// FlakyDB is a kv.DB implementation that creates Flaky transactions, all of
+// which share one count of error checks.
+type FlakyDB struct {
+	kv.DB
+	*faults
+}
+
+// NewFlakyDB returns a new FlakyDB that wraps db and injects faults as
+// described by c.
+func NewFlakyDB(db kv.DB, c FlakyConfig) *FlakyDB {
+	return &FlakyDB{DB: db, faults: newFaults(c)}
+}
+
+// NewTxn returns a new Flaky wrapping a transaction created by the wrapped
+// kv.DB.
+func (db *FlakyDB) NewTxn(update bool) kv.TxnCommitDiscarder {
+	return &Flaky{Txn: db.DB.NewTxn(update), faults: db.faults, db: db.DB}
+}
+
+// Deflake calls test repeatedly to check that all errors returned from
+// kv.Txn methods produce failures in the test.
+//
+// Deflake(t, test) will pass if and only if: test completes when given a
+// well-behaved kv.Txn that never returns errors, and test panics when given
+// a kv.Txn that returns errors "unpredictably".
+//
+// The test func must use panic to communicate failures. It might be nice to
+// use a *testing.T like sane people do, but this approach requires a test that
+// can succeed successfully when the kv.Txn doesn't return any errors, and
+// fail successfully when it does return an error. Unfortunately, the testing
+// package doesn't support this, and we have to panic instead.
+func Deflake(t *testing.T, test func(kv.Txn)) {
+	DeflakeMethods(t, ErrorMethods, test)
+}
+
+// DeflakeMethods is like Deflake, but only injects errors into calls to the
+// given methods.
+func DeflakeMethods(t *testing.T, methods Method, test func(kv.Txn)) {
+	deflake(t, FlakyConfig{Methods: methods}, func(c FlakyConfig) checker {
+		flaky := NewFlakyTxn(memory.New(), c)
+		defer flaky.Discard()
+		test(flaky)
+		return flaky
+	})
+}
+
+// DeflakeDB is like Deflake, but gives test a kv.DB so that it can create
+// and commit its own transactions. Every transaction created through the
+// kv.DB shares one count of error checks.
+//
+// The kv.DB injects faults as described by c, except that FailAt is set for
+// each run of test.
+func DeflakeDB(t *testing.T, c FlakyConfig, test func(kv.DB)) {
+	deflake(t, c, func(c FlakyConfig) checker {
+		db := NewFlakyDB(memory.NewDB(), c)
+		defer db.Close()
+		test(db)
+		return db
+	})
+}
+
+type checker interface {
+	ErrCheckCount() int
+	StackTrace() string
+}
+
+// deflake runs test once with c.FailAt set to zero to count error checks, and
+// then once more for each error check with c.FailAt set to that check.
+func deflake(t *testing.T, c FlakyConfig, test func(FlakyConfig) checker) {
+	c.FailAt = 0
+	var count int
+	t.Run("success", func(*testing.T) {
+		count = test(c).ErrCheckCount()
+	})
+	for want := 1; want < count; want++ {
+		c.FailAt = want
+		t.Run(Flake(want).Error(), func(t *testing.T) {
+			var flaky checker
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("error did not cause test failure: " + flaky.StackTrace())
+				}
+			}()
+			flaky = test(c)
+		})
+	}
+}
@@ -20,25 +20,113 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"runtime/debug"
-	"sync/atomic"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/note-maps/kv"
 	"github.com/google/note-maps/kv/badger"
+	"github.com/google/note-maps/kv/bolt"
+	"github.com/google/note-maps/kv/cache"
+	"github.com/google/note-maps/kv/crypt"
 	"github.com/google/note-maps/kv/memory"
+	bbolt "go.etcd.io/bbolt"
 )
 
+// BackendEnv is the name of an environment variable that selects the storage
+// backend used by NewDB and New: "memory", "badger", "bolt" or "sqlite". By
+// default, tests use memory in short mode and badger otherwise.
+//
+// The "sqlite" backend requires cgo, so it is only available when cgo is
+// enabled.
+const BackendEnv = "KVTEST_BACKEND"
+
+// openers holds functions that open storage backends in a temporary
+// directory, for backends that are only available in some builds.
+var openers = make(map[string]func(dir string) (kv.DB, error))
+
+// Backend returns the name of the storage backend used by NewDB and New.
+func Backend() string {
+	if b := os.Getenv(BackendEnv); b != "" {
+		return b
+	} else if testing.Short() {
+		return "memory"
+	}
+	return "badger"
+}
+
+// CryptEnv is the name of an environment variable that makes NewDB and New
+// encrypt the data they store with kv/crypt: "values" encrypts values, and
+// "keys" encrypts both keys and values. By default, nothing is encrypted.
+const CryptEnv = "KVTEST_CRYPT"
+
+// CacheEnv is the name of an environment variable that, when set to any
+// value, makes NewDB and New cache decoded values with kv/cache.
+const CacheEnv = "KVTEST_CACHE"
+
 // NewDB returns a new kv.DB suitable for use in a unit test.
 //
 // It's still important to call Close() in order to delete any temporary files
-// created by the kv.Txn.
-func NewDB(t *testing.T) kv.DB {
-	dir, err := ioutil.TempDir("", "kvtest-badger")
+// created by the kv.DB.
+func NewDB(t testing.TB) kv.DB {
+	db := NewBackendDB(t, Backend())
+	switch mode := os.Getenv(CryptEnv); mode {
+	case "":
+	case "values", "keys":
+		db = NewCryptDB(t, db, mode == "keys")
+	default:
+		db.Close()
+		t.Fatalf("unknown %s: %q", CryptEnv, mode)
+	}
+	if os.Getenv(CacheEnv) != "" {
+		db = cache.New(db, cache.Options{})
+	}
+	return db
+}
+
+// NewCryptDB returns a kv.DB that encrypts the data it stores in db with a
+// fixed key, and optionally encrypts keys as well as values.
+func NewCryptDB(t testing.TB, db kv.DB, encryptKeys bool) kv.DB {
+	cdb, err := crypt.New(db, crypt.Options{
+		Keys:        []crypt.Key{{ID: 1, Secret: []byte("kvtest secret key")}},
+		EncryptKeys: encryptKeys,
+	})
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	return cdb
+}
+
+// NewBackendDB returns a new kv.DB using the named storage backend, as
+// described by BackendEnv.
+//
+// It's still important to call Close() in order to delete any temporary files
+// created by the kv.DB.
+func NewBackendDB(t testing.TB, backend string) kv.DB {
+	if backend == "memory" {
+		return memory.NewDB()
+	}
+	dir, err := ioutil.TempDir("", "kvtest-"+backend)
 	if err != nil {
 		t.Fatal(err)
 	}
-	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(badgerLogger{t}))
+	var db kv.DB
+	switch backend {
+	case "badger":
+		db, err = badger.Open(badger.DefaultOptions(dir).WithLogger(badgerLogger{t}))
+	case "bolt":
+		// Tests often commit while holding read-only transactions, which
+		// bbolt only allows when it does not need to grow its memory map.
+		db, err = bolt.Open(filepath.Join(dir, "bolt.db"), 0600, &bbolt.Options{
+			InitialMmapSize: 1 << 26,
+		})
+	default:
+		if open, ok := openers[backend]; ok {
+			db, err = open(dir)
+		} else {
+			err = fmt.Errorf("unknown or unavailable %s: %q", BackendEnv, backend)
+		}
+	}
 	if err != nil {
 		os.RemoveAll(dir)
 		t.Fatal(err)
@@ -51,6 +139,14 @@ type tmpDB struct {
 	dir string
 }
 
+func (db *tmpDB) NewBatch() kv.Batch {
+	return db.DB.(kv.Batcher).NewBatch()
+}
+
+func (db *tmpDB) Watch(prefix []byte) (kv.Subscription, error) {
+	return kv.Watch(db.DB, prefix)
+}
+
 func (db *tmpDB) Close() error {
 	db.DB.Close()
 	os.RemoveAll(db.dir)
@@ -61,24 +157,15 @@ func (db *tmpDB) Close() error {
 //
 // It's still important to call Close() in order to delete any temporary files
 // created by the kv.Txn.
-func New(t *testing.T) kv.TxnCommitDiscarder {
-	if testing.Short() {
+func New(t testing.TB) kv.TxnCommitDiscarder {
+	if Backend() == "memory" && os.Getenv(CryptEnv) == "" && os.Getenv(CacheEnv) == "" {
 		return memory.New()
 	}
-	dir, err := ioutil.TempDir("", "kvtest-badger")
-	if err != nil {
-		t.Fatal(err)
-	}
-	b, err := badger.Open(badger.DefaultOptions(dir).WithLogger(badgerLogger{t}))
-	if err != nil {
-		os.RemoveAll(dir)
-		t.Fatal(err)
-	}
+	db := NewDB(t)
 	return &tmpTxn{
-		TxnCommitDiscarder: b.NewTxn(true),
+		TxnCommitDiscarder: db.NewTxn(true),
 		discard: func() {
-			b.Close()
-			os.RemoveAll(dir)
+			db.Close()
 		},
 	}
 }
@@ -94,7 +181,7 @@ func (s *tmpTxn) Discard() {
 }
 
 type badgerLogger struct {
-	*testing.T
+	testing.TB
 }
 
 func (l badgerLogger) Errorf(f string, v ...interface{})   { l.Logf(f, v...) }
@@ -102,117 +189,6 @@ func (l badgerLogger) Warningf(f string, v ...interface{}) { l.Logf(f, v...) }
 func (l badgerLogger) Infof(f string, v ...interface{})    { l.Logf(f, v...) }
 func (l badgerLogger) Debugf(f string, v ...interface{})   { l.Logf(f, v...) }
 
-// NewFlaky returns a new Flaky that wraps the given a kv.Txn and will fail
-// when the count of error checks reaches failAtCount.
-//
-// If failAtCount is zero, the resulting Flaky will never deliberately return
-// an error.
-func NewFlaky(t *testing.T, failAtCount int) *Flaky {
-	return &Flaky{
-		Txn:         memory.New(),
-		failAtCount: failAtCount,
-		err:         Flake(failAtCount),
-	}
-}
-
-// Flake is the type of error returned by a Flaky store.
-type Flake int
-
-// Error returns a simple human-readable string describing this flake.
-func (f Flake) Error() string { return fmt.Sprintf("flake#%d", int(f)) }
-
-// Flaky is a kv.Txn implemention that
-//
-// Flaky counts each call to any method that could return an error as another
-// error check. When, during a call to such a method, the number of error
-// checks reaches a preset value, then that call will return an error.
-//
-// Usage might involve running a test once with failAtCount set to zero to test
-// the successful case and count the number of error checks, and then to run it
-// again for each possible value of failAtCount from 1 to the number of error
-// checks, to make sure all errors are handled appropriately.
-type Flaky struct {
-	kv.Txn
-	errCheckCount int32
-	failAtCount   int
-	err           error
-	stackTrace    string
-}
-
-// ErrCheckCount returns the total number of error checks this Flaky has
-// counted so far.
-func (s *Flaky) ErrCheckCount() int {
-	return int(atomic.LoadInt32(&s.errCheckCount))
-}
-
-// StackTrace returns a formatted stacktrace taken from the moment an error was
-// returned.
-func (s *Flaky) StackTrace() string {
-	return s.stackTrace
-}
-
-func (s *Flaky) fail() bool {
-	f := int(atomic.AddInt32(&s.errCheckCount, 1)) == s.failAtCount
-	if f {
-		s.stackTrace = string(debug.Stack())
-	}
-	return f
-}
-
-// Alloc fails if the count of error checks has reached failAtCount.
-func (s *Flaky) Alloc() (kv.Entity, error) {
-	if s.fail() {
-		return 0, s.err
-	}
-	return s.Txn.Alloc()
-}
-
-// Get fails if the count of error checks has reached failAtCount.
-func (s *Flaky) Get(k []byte, f func([]byte) error) error {
-	if s.fail() {
-		return s.err
-	}
-	return s.Txn.Get(k, f)
-}
-
-// Set fails if the count of error checks has reached failAtCount.
-func (s *Flaky) Set(k, v []byte) error {
-	if s.fail() {
-		return s.err
-	}
-	return s.Txn.Set(k, v)
-}
-
-// Deflake calls test repeatedly to check that all errors returned from
-// kv.Txn methods produce failures in the test.
-//
-// Deflake(t, test) will pass if and only if: test completes when given a
-// well-behaved kv.Txn that never returns errors, and test panics when given
-// a kv.Txn that returns errors "unpredictably".
-//
-// The test func must use panic to communicate failures. It might be nice to
-// use a *testing.T like sane people do, but this approach requires a test that
-// can succeed successfully when the kv.Txn doesn't return any errors, and
-// fail successfully when it does return an error. Unfortunately, the testing
-// package doesn't support this, and we have to panic instead.
-func Deflake(t *testing.T, test func(kv.Txn)) {
-	successful := NewFlaky(t, 0)
-	t.Run("success", func(*testing.T) {
-		test(successful)
-	})
-	for want := 1; want < successful.ErrCheckCount(); want++ {
-		t.Run(Flake(want).Error(), func(t *testing.T) {
-			flaky := NewFlaky(t, want)
-			defer func() {
-				if r := recover(); r == nil {
-					t.Error("error did not cause test failure: " + flaky.StackTrace())
-				}
-			}()
-			test(flaky)
-		})
-	}
-}
-
 // DumpDB writes a mostly human-readable representation of the entire contents
 // of db to w.
 func DumpDB(w io.Writer, db kv.DB) {
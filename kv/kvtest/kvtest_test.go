@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/memory"
 )
 
 func TestNew(t *testing.T) {
@@ -30,19 +31,12 @@ func TestNew(t *testing.T) {
 	}
 }
 
-type flakyMethod int
+var methods = []Method{AllocMethod, GetMethod, SetMethod, DeleteMethod, ValueMethod}
 
-const (
-	Alloc flakyMethod = iota
-	Get
-	Set
-	FlakyMethodCount
-)
-
-func randMethods(n int) []flakyMethod {
-	ms := make([]flakyMethod, n)
+func randMethods(n int) []Method {
+	ms := make([]Method, n)
 	for i := range ms {
-		ms[i] = flakyMethod(rand.Int()) % FlakyMethodCount
+		ms[i] = methods[rand.Intn(len(methods))]
 	}
 	return ms
 }
@@ -55,19 +49,30 @@ func randBytes(n int) []byte {
 	return ms
 }
 
-func callMethod(s kv.Txn, m flakyMethod) error {
+func callMethod(s kv.Txn, m Method) error {
 	switch m {
-	case Alloc:
+	case AllocMethod:
 		_, err := s.Alloc()
 		return err
-	case Get:
+	case GetMethod:
 		err := s.Get(randBytes(4), func([]byte) error { return nil })
 		return err
-	case Set:
+	case SetMethod:
 		err := s.Set(randBytes(4), randBytes(32))
 		return err
+	case DeleteMethod:
+		err := s.Delete(randBytes(4))
+		return err
+	case ValueMethod:
+		if err := s.Set([]byte("v"), randBytes(32)); err != nil {
+			return err
+		}
+		iter := s.PrefixIterator([]byte("v"))
+		defer iter.Discard()
+		iter.Seek(nil)
+		return iter.Value(func([]byte) error { return nil })
 	default:
-		panic(fmt.Sprintf("unrecognized flakyMethod %v", m))
+		panic(fmt.Sprintf("unrecognized Method %v", m))
 	}
 }
 
@@ -84,3 +89,100 @@ func TestFlakyDeflake(t *testing.T) {
 	)
 	Deflake(t, test)
 }
+
+func TestFlakyMethods(t *testing.T) {
+	s := NewFlakyTxn(memory.New(), FlakyConfig{FailAt: 2, Methods: DeleteMethod})
+	for i := 0; i < 3; i++ {
+		if err := s.Set([]byte{byte(i)}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete([]byte{1}); err != Flake(2) {
+		t.Errorf("want %v, got %v", Flake(2), err)
+	}
+	if err := s.Delete([]byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.ErrCheckCount(); got != 3 {
+		t.Errorf("want 3 error checks, got %v", got)
+	}
+}
+
+func TestFlakyKeyPrefix(t *testing.T) {
+	s := NewFlakyTxn(memory.New(), FlakyConfig{
+		Methods:   AllMethods,
+		KeyPrefix: []byte("bad"),
+	})
+	if err := s.Set([]byte("good"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set([]byte("bad"), nil); err == nil {
+		t.Error("Set did not fail for a key matching KeyPrefix")
+	}
+	if err := s.Get([]byte("bad1"), func([]byte) error { return nil }); err == nil {
+		t.Error("Get did not fail for a key matching KeyPrefix")
+	}
+	iter := s.PrefixIterator([]byte("ba"))
+	defer iter.Discard()
+	iter.Seek(nil)
+	if iter.Valid() {
+		t.Error("found a key that should not have been set")
+	}
+	iter = s.PrefixIterator([]byte("bad"))
+	defer iter.Discard()
+	if err := iter.Value(func([]byte) error { return nil }); err == nil {
+		t.Error("Value did not fail in an iterator whose prefix matches KeyPrefix")
+	}
+}
+
+func TestFlakyDBPartialCommit(t *testing.T) {
+	db := NewFlakyDB(memory.NewDB(), FlakyConfig{
+		FailAt:        1,
+		Methods:       CommitMethod,
+		PartialCommit: true,
+	})
+	defer db.Close()
+	txn := db.NewTxn(true)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := txn.Commit(); err != Flake(1) {
+		t.Fatalf("want %v, got %v", Flake(1), err)
+	}
+	r := db.NewTxn(false)
+	defer r.Discard()
+	for _, test := range []struct {
+		key  string
+		want bool
+	}{{"a", true}, {"b", true}, {"c", false}, {"d", false}} {
+		var got bool
+		if err := r.Get([]byte(test.key), func(v []byte) error {
+			got = len(v) > 0
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%q: want committed=%v, got %v", test.key, test.want, got)
+		}
+	}
+}
+
+func TestDeflakeDB(t *testing.T) {
+	DeflakeDB(t, FlakyConfig{}, func(db kv.DB) {
+		if err := kv.Update(db, func(txn kv.Txn) error {
+			e, err := txn.Alloc()
+			if err != nil {
+				return err
+			}
+			return txn.Set(e.Encode(), []byte("x"))
+		}); err != nil {
+			panic(err)
+		}
+	})
+}
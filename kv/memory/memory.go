@@ -12,105 +12,600 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package memory provides an in-memory implementation of kv.Txn.
+// Package memory provides in-memory implementations of kv.DB and kv.Txn.
+//
+// Transactions provide the snapshot isolation described by kv.DB: each
+// transaction reads from the state of the store as it was when the transaction
+// was created, its writes are buffered until Commit, and Commit fails if any
+// key read by the transaction was modified by another transaction in the
+// meantime.
 package memory
 
 import (
-	"sort"
-	"strings"
+	"bytes"
+	"errors"
 	"sync"
 
+	"github.com/google/btree"
 	"github.com/google/note-maps/kv"
 )
 
+var (
+	errReadOnly  = errors.New("memory: cannot update a read-only transaction")
+	errDiscarded = errors.New("memory: transaction has been discarded")
+)
+
+// degree is the degree of the B-trees used to store key-value pairs.
+const degree = 32
+
+// batchSize is the number of items an iterator copies out of a B-tree at a
+// time.
+const batchSize = 32
+
 // New returns a memory-backed implementation of the kv.Txn interface
 // intended exclusively for use in tests, and not in production.
+//
+// The result is an update transaction on a new, empty store.
 func New() kv.TxnCommitDiscarder {
-	return &txn{
-		m: make(map[string][]byte),
+	return NewDB().NewTxn(true)
+}
+
+// NewDB returns a new, empty, memory-backed implementation of the kv.DB
+// interface intended exclusively for use in tests, and not in production.
+//
+// Transactions created by the result are independent of each other: writes are
+// buffered until Commit and dropped by Discard, while Alloc draws from a
+// sequence shared by all transactions.
+func NewDB() kv.DB {
+	return &db{
+		tree:          btree.New(degree),
+		versions:      make(map[string]uint64),
+		open:          make(map[uint64]int),
+		subscriptions: make(map[*subscription]bool),
+	}
+}
+
+// db holds the committed state that is shared between transactions.
+type db struct {
+	mutex sync.Mutex
+
+	// tree holds the most recently committed key-value pairs. It is never
+	// modified after it has been shared with a transaction: each commit
+	// modifies a copy-on-write clone instead.
+	tree *btree.BTree
+
+	// versions maps each key that has been written by a commit to the
+	// version of that commit, for as long as an open update transaction
+	// began before that commit and so could conflict with it.
+	versions map[string]uint64
+	version  uint64
+
+	// written lists the keys written by each commit that may still have
+	// entries in versions, oldest first.
+	written []written
+
+	// open counts the open update transactions by the version at which
+	// each began.
+	open map[uint64]int
+
+	next kv.Entity
+
+	// subscriptions holds every open subscription.
+	subscriptions map[*subscription]bool
+}
+
+func (db *db) NewTxn(update bool) kv.TxnCommitDiscarder {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	s := &txn{
+		db:       db,
+		update:   update,
+		snapshot: db.tree,
+		version:  db.version,
+	}
+	if update {
+		s.reads = make(map[string]bool)
+		db.open[s.version]++
+	}
+	return s
+}
+
+// written holds the keys written by the commit of a version.
+type written struct {
+	version uint64
+	keys    []string
+}
+
+// Close ends every subscription to changes in db.
+func (db *db) Close() error {
+	db.mutex.Lock()
+	subscriptions := db.subscriptions
+	db.subscriptions = make(map[*subscription]bool)
+	db.mutex.Unlock()
+	for s := range subscriptions {
+		s.Close()
+	}
+	return nil
+}
+
+// NewBatch returns a kv.Batch that applies all of its writes in a single
+// commit when flushed. Since a batch never reads, the commit cannot conflict.
+func (db *db) NewBatch() kv.Batch {
+	return batch{db.NewTxn(true).(*txn)}
+}
+
+type batch struct{ *txn }
+
+func (b batch) Flush() error { return b.Commit() }
+
+func (b batch) Cancel() { b.Discard() }
+
+func (db *db) alloc() kv.Entity {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.next++
+	return db.next
+}
+
+// commit applies the writes of s to db, or returns kv.ErrConflict if any key
+// read by s was written by a commit that s could not see.
+func (db *db) commit(s *txn) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	defer db.endLocked(s)
+	for k := range s.reads {
+		if db.versions[k] > s.version {
+			return kv.ErrConflict
+		}
 	}
+	tree := db.tree.Clone()
+	db.version++
+	done := written{version: db.version}
+	var changes []kv.Change
+	s.writes.Ascend(func(i btree.Item) bool {
+		w := i.(*item)
+		if w.deleted {
+			tree.Delete(w)
+		} else {
+			tree.ReplaceOrInsert(w)
+		}
+		k := string(w.key)
+		db.versions[k] = db.version
+		done.keys = append(done.keys, k)
+		if len(db.subscriptions) > 0 {
+			changes = append(changes, kv.Change{Key: w.key, Value: w.value, Deleted: w.deleted})
+		}
+		return true
+	})
+	db.tree = tree
+	db.written = append(db.written, done)
+	// Publishing while db.mutex is held ensures that every subscription
+	// receives commits in the order in which they were applied.
+	for sub := range db.subscriptions {
+		sub.publish(changes)
+	}
+	return nil
+}
+
+// end records that update transaction s has ended.
+func (db *db) end(s *txn) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.endLocked(s)
+}
+
+// endLocked records that update transaction s has ended, and then forgets the
+// versions of keys written by commits that every open update transaction can
+// see, since they can no longer cause a conflict.
+func (db *db) endLocked(s *txn) {
+	if db.open[s.version]--; db.open[s.version] == 0 {
+		delete(db.open, s.version)
+	}
+	oldest := db.version
+	for v := range db.open {
+		if v < oldest {
+			oldest = v
+		}
+	}
+	i := 0
+	for ; i < len(db.written) && db.written[i].version <= oldest; i++ {
+		for _, k := range db.written[i].keys {
+			if db.versions[k] == db.written[i].version {
+				delete(db.versions, k)
+			}
+		}
+	}
+	db.written = db.written[i:]
+}
+
+// Watch returns a subscription to the changes made to keys that begin with
+// prefix by each subsequent commit.
+func (db *db) Watch(prefix []byte) (kv.Subscription, error) {
+	s := &subscription{prefix: kv.ConcatByteSlices(prefix)}
+	s.Feed = kv.NewFeed(func() {
+		db.mutex.Lock()
+		delete(db.subscriptions, s)
+		db.mutex.Unlock()
+	})
+	db.mutex.Lock()
+	db.subscriptions[s] = true
+	db.mutex.Unlock()
+	return s, nil
+}
+
+// subscription publishes the changes to keys that begin with prefix.
+type subscription struct {
+	*kv.Feed
+	prefix []byte
+}
+
+// publish publishes the changes that match s.prefix, if any.
+func (s *subscription) publish(changes []kv.Change) {
+	var matched []kv.Change
+	for _, c := range changes {
+		if bytes.HasPrefix(c.Key, s.prefix) {
+			// Copy the change so that the subscriber cannot modify
+			// the committed items.
+			c.Key, c.Value = kv.ConcatByteSlices(c.Key), kv.ConcatByteSlices(c.Value)
+			matched = append(matched, c)
+		}
+	}
+	s.Publish(matched)
+}
+
+// item is a key-value pair stored in a B-tree, or a buffered deletion.
+type item struct {
+	key, value []byte
+	deleted    bool
+}
+
+func (a *item) Less(b btree.Item) bool {
+	return bytes.Compare(a.key, b.(*item).key) < 0
 }
 
 type txn struct {
-	m     map[string][]byte
-	next  kv.Entity
+	db       *db
+	update   bool
+	snapshot *btree.BTree
+	version  uint64
+
 	mutex sync.Mutex
+
+	// writes holds buffered writes, and is nil until the first write.
+	writes *btree.BTree
+
+	// reads holds every key read by an update transaction.
+	reads map[string]bool
+
+	discarded bool
 }
 
 func (s *txn) Alloc() (kv.Entity, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	s.next++
-	return s.next, nil
+	return s.db.alloc(), nil
 }
 
 func (s *txn) Get(k []byte, f func([]byte) error) error {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	return f(s.m[string(k)])
+	if s.discarded {
+		s.mutex.Unlock()
+		return errDiscarded
+	}
+	var v []byte
+	key := &item{key: k}
+	if w, ok := s.get(s.writes, key); ok {
+		v = w.value
+	} else if i, ok := s.get(s.snapshot, key); ok {
+		v = i.value
+	}
+	s.readLocked(k)
+	s.mutex.Unlock()
+	return f(v)
+}
+
+func (s *txn) get(tree *btree.BTree, key *item) (*item, bool) {
+	if tree == nil {
+		return nil, false
+	}
+	i := tree.Get(key)
+	if i == nil {
+		return nil, false
+	}
+	return i.(*item), true
 }
 
 func (s *txn) Set(k, v []byte) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	s.m[string(k)] = v
-	return nil
+	return s.write(&item{
+		key:   kv.ConcatByteSlices(k),
+		value: kv.ConcatByteSlices(v),
+	})
 }
 
 func (s *txn) Delete(k []byte) error {
+	return s.write(&item{key: kv.ConcatByteSlices(k), deleted: true})
+}
+
+func (s *txn) write(w *item) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	delete(s.m, string(k))
+	if s.discarded {
+		return errDiscarded
+	} else if !s.update {
+		return errReadOnly
+	}
+	if s.writes == nil {
+		s.writes = btree.New(degree)
+	}
+	s.writes.ReplaceOrInsert(w)
 	return nil
 }
 
 func (s *txn) PrefixIterator(prefix []byte) kv.Iterator {
-	var iter iterator
-	p := string(prefix)
-	for k, v := range s.m {
-		if strings.HasPrefix(k, p) {
-			iter.pairs = append(iter.pairs, pair{
-				key:   k[len(p):],
-				value: v,
-			})
+	return s.newIterator(prefix, prefix, kv.PrefixEnd(prefix), false)
+}
+
+func (s *txn) ReversePrefixIterator(prefix []byte) kv.Iterator {
+	return s.newIterator(prefix, prefix, kv.PrefixEnd(prefix), true)
+}
+
+func (s *txn) RangeIterator(prefix, lower, upper []byte) kv.Iterator {
+	hi := kv.PrefixEnd(prefix)
+	if upper != nil {
+		hi = kv.ConcatByteSlices(prefix, upper)
+	}
+	return s.newIterator(prefix, kv.ConcatByteSlices(prefix, lower), hi, false)
+}
+
+func (s *txn) newIterator(prefix, lo, hi []byte, reverse bool) *iterator {
+	s.mutex.Lock()
+	writes := s.writes
+	s.mutex.Unlock()
+	iter := &iterator{
+		txn:      s,
+		prefix:   prefix,
+		reverse:  reverse,
+		snapshot: newCursor(s.snapshot, nil, lo, hi, reverse),
+		writes:   newCursor(writes, &s.mutex, lo, hi, reverse),
+	}
+	iter.Seek(nil)
+	return iter
+}
+
+// Discard discards any writes that have not been committed.
+func (s *txn) Discard() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.update && !s.discarded {
+		s.db.end(s)
+	}
+	s.discarded = true
+	s.writes = nil
+}
+
+// Commit makes all writes in this transaction visible to transactions created
+// after it returns, or returns kv.ErrConflict if some key read by this
+// transaction has been modified since it began.
+//
+// Whether or not Commit succeeds, the transaction is discarded.
+func (s *txn) Commit() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.discarded {
+		return errDiscarded
+	}
+	s.discarded = true
+	if s.writes == nil {
+		if s.update {
+			s.db.end(s)
 		}
+		return nil
 	}
-	sort.Slice(
-		iter.pairs,
-		func(a, b int) bool { return iter.pairs[a].key < iter.pairs[b].key })
-	return &iter
+	return s.db.commit(s)
 }
 
-func (s *txn) Discard()      {}
-func (s *txn) Commit() error { return nil }
+// read records that an update transaction has read key k.
+func (s *txn) read(k []byte) {
+	if !s.update {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.readLocked(k)
+}
 
-type pair struct {
-	key   string
-	value []byte
+func (s *txn) readLocked(k []byte) {
+	if s.update && !s.discarded {
+		s.reads[string(k)] = true
+	}
 }
 
-type iterator struct {
-	pairs []pair
+// cursor iterates through the items of a B-tree that lie within [lo, hi),
+// copying them out in small batches.
+type cursor struct {
+	tree    *btree.BTree
+	lock    sync.Locker
+	lo, hi  []byte
+	reverse bool
+
+	items []*item
 	i     int
+	more  bool
+
+	// pivot and skip are used while filling items.
+	pivot item
+	skip  []byte
+	visit btree.ItemIterator
 }
 
-func (i *iterator) Seek(key []byte) {
-	k := string(key)
-	i.i = 0
-	for i.i = 0; i.i < len(i.pairs) && i.pairs[i.i].key < k; i.i++ {
+func newCursor(tree *btree.BTree, lock sync.Locker, lo, hi []byte, reverse bool) *cursor {
+	c := &cursor{
+		tree:    tree,
+		lock:    lock,
+		lo:      lo,
+		hi:      hi,
+		reverse: reverse,
+		items:   make([]*item, 0, batchSize),
+	}
+	c.visit = c.collect
+	return c
+}
+
+func (c *cursor) collect(i btree.Item) bool {
+	x := i.(*item)
+	if c.skip != nil && bytes.Equal(x.key, c.skip) {
+		return true
+	}
+	if c.reverse {
+		if bytes.Compare(x.key, c.lo) < 0 {
+			c.more = false
+			return false
+		}
+		if c.hi != nil && bytes.Compare(x.key, c.hi) >= 0 {
+			return true
+		}
+	} else if c.hi != nil && bytes.Compare(x.key, c.hi) >= 0 {
+		c.more = false
+		return false
+	}
+	c.items = append(c.items, x)
+	return len(c.items) < batchSize
+}
+
+// fill replaces the current batch with the items that follow from, which is
+// included only if exclusive is false. In reverse, a nil from means the end
+// of the B-tree.
+func (c *cursor) fill(from []byte, exclusive bool) {
+	c.items, c.i, c.more = c.items[:0], 0, true
+	if c.tree == nil {
+		c.more = false
+		return
+	}
+	c.skip = nil
+	if exclusive {
+		c.skip = from
+	}
+	c.pivot.key = from
+	if c.lock != nil {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+	}
+	switch {
+	case !c.reverse:
+		c.tree.AscendGreaterOrEqual(&c.pivot, c.visit)
+	case from == nil:
+		c.tree.Descend(c.visit)
+	default:
+		c.tree.DescendLessOrEqual(&c.pivot, c.visit)
+	}
+	if len(c.items) < batchSize {
+		c.more = false
+	}
+}
+
+// seek moves to the first item at or after k in the direction of iteration.
+// In reverse, a nil k means the end of the range.
+func (c *cursor) seek(k []byte) {
+	switch {
+	case !c.reverse:
+		if bytes.Compare(k, c.lo) < 0 {
+			k = c.lo
+		}
+		c.fill(k, false)
+	case k == nil || (c.hi != nil && bytes.Compare(k, c.hi) >= 0):
+		c.fill(c.hi, true)
+	default:
+		c.fill(k, false)
 	}
 }
 
-func (i *iterator) Next() { i.i++ }
+func (c *cursor) valid() bool { return c.i < len(c.items) }
+
+func (c *cursor) item() *item { return c.items[c.i] }
+
+func (c *cursor) next() {
+	c.i++
+	if c.i == len(c.items) && c.more {
+		c.fill(c.items[len(c.items)-1].key, true)
+	}
+}
 
-func (i *iterator) Valid() bool {
-	return 0 <= i.i && i.i < len(i.pairs)
+// iterator merges the committed snapshot with the buffered writes of a
+// transaction.
+type iterator struct {
+	txn      *txn
+	prefix   []byte
+	reverse  bool
+	snapshot *cursor
+	writes   *cursor
+	key      []byte
 }
 
-func (i *iterator) Key() []byte { return []byte(i.pairs[i.i].key) }
+// head returns the cursor holding the current item, preferring buffered
+// writes over the snapshot when both hold the same key.
+func (i *iterator) head() *cursor {
+	a, b := i.snapshot, i.writes
+	switch {
+	case !b.valid():
+		if a.valid() {
+			return a
+		}
+		return nil
+	case !a.valid():
+		return b
+	}
+	c := bytes.Compare(a.item().key, b.item().key)
+	if i.reverse {
+		c = -c
+	}
+	if c < 0 {
+		return a
+	}
+	return b
+}
+
+func (i *iterator) advance() {
+	k := i.head().item().key
+	for _, c := range []*cursor{i.snapshot, i.writes} {
+		if c.valid() && bytes.Equal(c.item().key, k) {
+			c.next()
+		}
+	}
+}
+
+// settle skips buffered deletions.
+func (i *iterator) settle() {
+	for h := i.head(); h != nil && h.item().deleted; h = i.head() {
+		i.advance()
+	}
+}
+
+func (i *iterator) Seek(key []byte) {
+	var k []byte
+	if key != nil || !i.reverse {
+		i.key = append(append(i.key[:0], i.prefix...), key...)
+		k = i.key
+	}
+	i.snapshot.seek(k)
+	i.writes.seek(k)
+	i.settle()
+}
+
+func (i *iterator) Next() {
+	i.advance()
+	i.settle()
+}
+
+func (i *iterator) Valid() bool { return i.head() != nil }
+
+func (i *iterator) Key() []byte {
+	k := i.head().item().key
+	i.txn.read(k)
+	return k[len(i.prefix):]
+}
 
 func (i *iterator) Value(f func([]byte) error) error {
-	return f(i.pairs[i.i].value)
+	x := i.head().item()
+	i.txn.read(x.key)
+	return f(x.value)
 }
 
 func (i *iterator) Discard() {}
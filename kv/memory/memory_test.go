@@ -17,6 +17,8 @@ package memory
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 
@@ -119,3 +121,445 @@ func TestIterator(t *testing.T) {
 		t.Error("want", want, "got", got)
 	}
 }
+
+func TestReverseIterator(t *testing.T) {
+	txn := New()
+	for _, k := range []string{"a0", "a1", "a2", "b0"} {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		Seek []byte
+		Want []string
+	}{
+		{nil, []string{"2", "1", "0"}},
+		{[]byte("1"), []string{"1", "0"}},
+		{[]byte("10"), []string{"1", "0"}},
+		{[]byte{}, nil},
+	} {
+		var got []string
+		iter := txn.ReversePrefixIterator([]byte("a"))
+		for iter.Seek(test.Seek); iter.Valid(); iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		iter.Discard()
+		if !reflect.DeepEqual(test.Want, got) {
+			t.Errorf("Seek(%q): want %q, got %q", test.Seek, test.Want, got)
+		}
+	}
+}
+
+func TestRangeIterator(t *testing.T) {
+	txn := New()
+	for _, k := range []string{"a0", "a1", "a2", "a3", "b0"} {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		Lower, Upper []byte
+		Want         []string
+	}{
+		{nil, nil, []string{"0", "1", "2", "3"}},
+		{[]byte("1"), []byte("3"), []string{"1", "2"}},
+		{[]byte("10"), nil, []string{"2", "3"}},
+		{nil, []byte("1"), []string{"0"}},
+		{[]byte("2"), []byte("2"), nil},
+	} {
+		var got []string
+		iter := txn.RangeIterator([]byte("a"), test.Lower, test.Upper)
+		for iter.Seek(nil); iter.Valid(); iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		iter.Discard()
+		if !reflect.DeepEqual(test.Want, got) {
+			t.Errorf("[%q,%q): want %q, got %q",
+				test.Lower, test.Upper, test.Want, got)
+		}
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	db := NewDB()
+	w := db.NewTxn(true)
+	if err := w.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	r := db.NewTxn(false)
+	defer r.Discard()
+	get := func(txn kv.Txn, k string) (v string) {
+		if err := txn.Get([]byte(k), func(bs []byte) error {
+			v = string(bs)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if got := get(w, "a"); got != "1" {
+		t.Errorf("got %q in writer, want %q", got, "1")
+	}
+	if got := get(r, "a"); got != "" {
+		t.Errorf("got %q before commit, want nothing", got)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(r, "a"); got != "" {
+		t.Errorf("got %q in older snapshot, want nothing", got)
+	}
+	iter := r.PrefixIterator(nil)
+	iter.Seek(nil)
+	if iter.Valid() {
+		t.Errorf("older snapshot iterator found key %q", iter.Key())
+	}
+	iter.Discard()
+	r2 := db.NewTxn(false)
+	defer r2.Discard()
+	if got := get(r2, "a"); got != "1" {
+		t.Errorf("got %q after commit, want %q", got, "1")
+	}
+	if err := r2.Set([]byte("b"), []byte("2")); err == nil {
+		t.Error("expected error from Set in read-only transaction")
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	db := NewDB()
+	w := db.NewTxn(true)
+	if err := w.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	w.Discard()
+	if err := w.Commit(); err == nil {
+		t.Error("expected error from Commit after Discard")
+	}
+	r := db.NewTxn(false)
+	defer r.Discard()
+	if err := r.Get([]byte("a"), func(bs []byte) error {
+		if bs != nil {
+			t.Errorf("got %q after Discard, want nothing", bs)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitConflict(t *testing.T) {
+	db := NewDB()
+	a, b := db.NewTxn(true), db.NewTxn(true)
+	if err := a.Get([]byte("k"), func([]byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set([]byte("x"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set([]byte("k"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Commit(); err != kv.ErrConflict {
+		t.Errorf("got %v, want %v", err, kv.ErrConflict)
+	}
+	c := db.NewTxn(false)
+	defer c.Discard()
+	if err := c.Get([]byte("x"), func(bs []byte) error {
+		if bs != nil {
+			t.Errorf("conflicting write was applied: %q", bs)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPruneVersions(t *testing.T) {
+	d := NewDB().(*db)
+	write := func(keys ...string) {
+		t.Helper()
+		txn := d.NewTxn(true)
+		for _, k := range keys {
+			if err := txn.Set([]byte(k), []byte(k)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a", "b")
+	if n := len(d.versions); n != 0 {
+		t.Errorf("got %d versions with no open transaction, want 0", n)
+	}
+	old, reader := d.NewTxn(true), d.NewTxn(false)
+	defer reader.Discard()
+	if err := old.Get([]byte("k"), func([]byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Set([]byte("x"), []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	write("k")
+	write("a", "b")
+	// Only the update transaction that began before these commits needs
+	// their versions.
+	if n := len(d.versions); n != 3 {
+		t.Errorf("got %d versions with an open transaction, want 3", n)
+	}
+	if err := old.Commit(); err != kv.ErrConflict {
+		t.Errorf("got %v, want %v", err, kv.ErrConflict)
+	}
+	if n, w := len(d.versions), len(d.written); n != 0 || w != 0 {
+		t.Errorf("got %d versions of %d commits after the last transaction ended, want none", n, w)
+	}
+	discarded := d.NewTxn(true)
+	write("c")
+	discarded.Discard()
+	discarded.Discard()
+	if n, o := len(d.versions), len(d.open); n != 0 || o != 0 {
+		t.Errorf("got %d versions and %d open versions after Discard, want none", n, o)
+	}
+}
+
+func TestDBAlloc(t *testing.T) {
+	db := NewDB()
+	a, b := db.NewTxn(true), db.NewTxn(false)
+	defer a.Discard()
+	defer b.Discard()
+	done := make(map[kv.Entity]bool)
+	for i := 0; i < 10; i++ {
+		for _, txn := range []kv.Txn{a, b} {
+			e, err := txn.Alloc()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if done[e] {
+				t.Fatal("duplicated", e)
+			}
+			done[e] = true
+		}
+	}
+}
+
+func TestIteratorMerge(t *testing.T) {
+	db := NewDB()
+	want := make(map[string]string)
+	base := db.NewTxn(true)
+	for i := 0; i < 200; i++ {
+		k := fmt.Sprintf("k%03d", i)
+		base.Set([]byte(k), []byte("base"))
+		want[k] = "base"
+	}
+	base.Set([]byte("j"), []byte("outside"))
+	base.Set([]byte("l"), []byte("outside"))
+	if err := base.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	for i := 0; i < 200; i++ {
+		k := fmt.Sprintf("k%03d", i)
+		switch {
+		case i%3 == 0:
+			txn.Delete([]byte(k))
+			delete(want, k)
+		case i%5 == 0:
+			txn.Set([]byte(k), []byte("updated"))
+			want[k] = "updated"
+		case i%7 == 0:
+			txn.Set([]byte(k+"x"), []byte("added"))
+			want[k+"x"] = "added"
+		}
+	}
+	var keys []string
+	for k := range want {
+		keys = append(keys, k[1:])
+	}
+	sort.Strings(keys)
+	reversed := make([]string, len(keys))
+	for i, k := range keys {
+		reversed[len(keys)-1-i] = k
+	}
+	collect := func(iter kv.Iterator, seek []byte) (got []string) {
+		defer iter.Discard()
+		for iter.Seek(seek); iter.Valid(); iter.Next() {
+			k := string(iter.Key())
+			if err := iter.Value(func(v []byte) error {
+				if string(v) != want["k"+k] {
+					t.Errorf("%q: got %q, want %q", k, v, want["k"+k])
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, k)
+		}
+		return got
+	}
+	if got := collect(txn.PrefixIterator([]byte("k")), nil); !reflect.DeepEqual(got, keys) {
+		t.Errorf("forward: got %q, want %q", got, keys)
+	}
+	if got := collect(txn.ReversePrefixIterator([]byte("k")), nil); !reflect.DeepEqual(got, reversed) {
+		t.Errorf("reverse: got %q, want %q", got, reversed)
+	}
+	i := sort.SearchStrings(keys, "100")
+	if got := collect(txn.PrefixIterator([]byte("k")), []byte("100")); !reflect.DeepEqual(got, keys[i:]) {
+		t.Errorf("forward from 100: got %q, want %q", got, keys[i:])
+	}
+	i = sort.Search(len(keys), func(i int) bool { return keys[i] > "100" })
+	if got := collect(txn.ReversePrefixIterator([]byte("k")), []byte("100")); !reflect.DeepEqual(got, reversed[len(keys)-i:]) {
+		t.Errorf("reverse from 100: got %q, want %q", got, reversed[len(keys)-i:])
+	}
+	i = sort.SearchStrings(keys, "100")
+	j := sort.SearchStrings(keys, "150")
+	if got := collect(txn.RangeIterator([]byte("k"), []byte("100"), []byte("150")), nil); !reflect.DeepEqual(got, keys[i:j]) {
+		t.Errorf("range: got %q, want %q", got, keys[i:j])
+	}
+}
+
+// sortedMap is the map-based storage that kv/memory used before it was
+// rebuilt on B-trees, kept as a baseline for benchmarks.
+type sortedMap map[string][]byte
+
+func (m sortedMap) PrefixIterator(prefix []byte) *sortedMapIterator {
+	p := string(prefix)
+	iter := sortedMapIterator{}
+	for k, v := range m {
+		if strings.HasPrefix(k, p) {
+			iter.pairs = append(iter.pairs, sortedMapPair{k[len(p):], v})
+		}
+	}
+	sort.Slice(iter.pairs, func(a, b int) bool {
+		return iter.pairs[a].key < iter.pairs[b].key
+	})
+	return &iter
+}
+
+type sortedMapPair struct {
+	key   string
+	value []byte
+}
+
+type sortedMapIterator struct {
+	pairs []sortedMapPair
+	i     int
+}
+
+func (i *sortedMapIterator) Seek(key []byte) {
+	k := string(key)
+	for i.i = 0; i.i < len(i.pairs) && i.pairs[i.i].key < k; i.i++ {
+	}
+}
+
+func (i *sortedMapIterator) Next()       { i.i++ }
+func (i *sortedMapIterator) Valid() bool { return i.i < len(i.pairs) }
+func (i *sortedMapIterator) Key() []byte { return []byte(i.pairs[i.i].key) }
+
+// benchmarkSizes are the total numbers of keys in benchmark stores, of which
+// one in ten share the prefix being iterated.
+var benchmarkSizes = []int{1000, 10000, 100000}
+
+func benchmarkKey(i int) []byte {
+	return []byte(fmt.Sprintf("%d/%08d", i%10, i))
+}
+
+func newBenchmarkStores(n int) (kv.Txn, sortedMap) {
+	db, m := NewDB(), make(sortedMap)
+	txn := db.NewTxn(true)
+	for i := 0; i < n; i++ {
+		k := benchmarkKey(i)
+		txn.Set(k, k)
+		m[string(k)] = k
+	}
+	if err := txn.Commit(); err != nil {
+		panic(err)
+	}
+	return db.NewTxn(false), m
+}
+
+func BenchmarkPrefixIterator(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		txn, m := newBenchmarkStores(n)
+		prefix := []byte("3/")
+		b.Run(fmt.Sprintf("btree/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				iter := txn.PrefixIterator(prefix)
+				for iter.Seek(nil); iter.Valid(); iter.Next() {
+					iter.Key()
+				}
+				iter.Discard()
+			}
+		})
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				iter := m.PrefixIterator(prefix)
+				for iter.Seek(nil); iter.Valid(); iter.Next() {
+					iter.Key()
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSeek(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		txn, m := newBenchmarkStores(n)
+		prefix := []byte("3/")
+		seeks := make([][]byte, 0, n/10)
+		for i := 3; i < n; i += 10 {
+			seeks = append(seeks, benchmarkKey(i)[len(prefix):])
+		}
+		b.Run(fmt.Sprintf("btree/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			iter := txn.PrefixIterator(prefix)
+			defer iter.Discard()
+			for i := 0; i < b.N; i++ {
+				iter.Seek(seeks[(i*7919)%len(seeks)])
+			}
+		})
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			iter := m.PrefixIterator(prefix)
+			for i := 0; i < b.N; i++ {
+				iter.Seek(seeks[(i*7919)%len(seeks)])
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db := NewDB()
+	b := db.(kv.Batcher).NewBatch()
+	if err := b.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	r := db.NewTxn(false)
+	defer r.Discard()
+	if err := r.Get([]byte("a"), func(bs []byte) error {
+		if bs != nil {
+			t.Errorf("got %q before Flush, want nothing", bs)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r = db.NewTxn(false)
+	defer r.Discard()
+	if err := r.Get([]byte("a"), func(bs []byte) error {
+		if string(bs) != "1" {
+			t.Errorf("got %q after Flush, want %q", bs, "1")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
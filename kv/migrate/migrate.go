@@ -0,0 +1,328 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate upgrades the data stored in a kv.DB from one version of a
+// schema to the next.
+//
+// A Schema is a numbered sequence of steps, each of which migrates data from
+// the previous version of the schema. The version of each schema is stored in
+// the database itself, so that Migrate can apply only the steps that a
+// database has not seen yet.
+//
+// Versions are stored in partition zero under component zero, which kv does
+// not assign to any component type.
+package migrate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	"github.com/google/note-maps/kv"
+)
+
+// Step migrates the data in a transaction from the previous version of a
+// schema.
+type Step struct {
+	// Version is the version of the schema after this step.
+	Version uint64
+
+	// Description briefly describes the step for logging.
+	Description string
+
+	// Apply migrates the data in txn.
+	Apply func(txn kv.Txn) error
+
+	// Batched is true if Apply may commit its writes in chunks, as described
+	// for RegisterBatched.
+	Batched bool
+}
+
+// Schema is a named sequence of migration steps.
+type Schema struct {
+	Name  string
+	steps []Step
+}
+
+// NewSchema returns a new Schema with the given name and no steps.
+//
+// The name identifies the schema's version within a database, so it must not
+// change, and it must be distinct from the names of other schemas stored in
+// the same database.
+func NewSchema(name string) *Schema {
+	return &Schema{Name: name}
+}
+
+// Register adds a step that migrates data to the given version from the
+// previous version, running apply in the same transaction that records the
+// new version.
+//
+// All of the step's writes must fit in a single transaction, so steps that
+// may write a large amount of data, such as rebuilding an index, should be
+// registered with RegisterBatched instead.
+//
+// Steps must be registered in order, and the first step migrates to version
+// one from version zero, which is the version of a database in which no
+// version has been recorded. Register panics if version is not one more than
+// the version of the last step registered.
+func (s *Schema) Register(version uint64, description string, apply func(kv.Txn) error) {
+	if version != s.Version()+1 {
+		panic(fmt.Sprintf("migrate: %s: step %d registered after step %d",
+			s.Name, version, s.Version()))
+	}
+	s.steps = append(s.steps, Step{Version: version, Description: description, Apply: apply})
+}
+
+// RegisterBatched adds a step like Register, except that if the database
+// implements kv.Batcher, apply is given a kv.BatchTxn so that its writes are
+// committed in chunks, and the new version is recorded in a separate
+// transaction once they have all been committed.
+//
+// A step that fails part way through may have committed some of its writes,
+// and it will be applied again by the next call to Migrate, so apply must be
+// idempotent. Rebuilding an index is idempotent; rewriting values in place
+// usually is not.
+func (s *Schema) RegisterBatched(version uint64, description string, apply func(kv.Txn) error) {
+	s.Register(version, description, apply)
+	s.steps[len(s.steps)-1].Batched = true
+}
+
+// Version returns the version of the schema that registered steps migrate
+// to.
+func (s *Schema) Version() uint64 {
+	return uint64(len(s.steps))
+}
+
+// VersionError is returned by Migrate when a database has a newer version of
+// a schema than Migrate knows how to handle.
+type VersionError struct {
+	Schema string
+	Stored uint64
+	Known  uint64
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("migrate: database has version %d of %s, but only versions up to %d are known",
+		e.Stored, e.Schema, e.Known)
+}
+
+func (s *Schema) key() []byte {
+	return kv.ConcatByteSlices(
+		kv.Entity(0).Encode(), kv.Component(0).Encode(), kv.Entity(0).Encode(),
+		[]byte(s.Name))
+}
+
+// StoredVersion returns the version of the schema recorded in txn, or zero if
+// no version has been recorded.
+func (s *Schema) StoredVersion(txn kv.Txn) (uint64, error) {
+	var v uint64
+	err := txn.Get(s.key(), func(bs []byte) error {
+		if len(bs) == 0 {
+			return nil
+		} else if len(bs) != 8 {
+			return fmt.Errorf("migrate: %s: invalid stored version %x", s.Name, bs)
+		}
+		v = binary.BigEndian.Uint64(bs)
+		return nil
+	})
+	return v, err
+}
+
+func (s *Schema) setVersion(txn kv.Txn, v uint64) error {
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], v)
+	return txn.Set(s.key(), bs[:])
+}
+
+// isEmpty returns true if txn holds no keys of any component, ignoring the
+// versions of schemas recorded under component zero.
+func isEmpty(txn kv.Txn) bool {
+	iter := txn.PrefixIterator(nil)
+	defer iter.Discard()
+	reserved := kv.ConcatByteSlices(kv.Entity(0).Encode(), kv.Component(0).Encode())
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		if key := iter.Key(); len(key) >= 8+2 && !bytes.HasPrefix(key, reserved) {
+			return false
+		}
+	}
+	return true
+}
+
+// Migrate applies to db every step that db has not seen yet.
+//
+// Each step registered with Register is applied in its own update
+// transaction, which also records the version of the schema after the step,
+// so that the step is never applied twice even if Migrate fails part way
+// through. Each step registered with RegisterBatched is applied through a
+// kv.BatchTxn if db supports batches, and is applied again if Migrate fails
+// before recording the version after it.
+//
+// A database that has no recorded version and holds no data is taken to be
+// new, and Migrate records the latest version in it without applying any
+// steps.
+//
+// Migrate returns a *VersionError if db has a newer version of the schema than
+// s.
+func (s *Schema) Migrate(db kv.DB) error {
+	_, batches := db.(kv.Batcher)
+	for {
+		var step *Step
+		err := kv.Update(db, func(txn kv.Txn) error {
+			step = nil
+			v, err := s.StoredVersion(txn)
+			if err != nil {
+				return err
+			} else if v > s.Version() {
+				return &VersionError{s.Name, v, s.Version()}
+			} else if v == s.Version() {
+				return nil
+			} else if v == 0 && isEmpty(txn) {
+				return s.setVersion(txn, s.Version())
+			}
+			step = &s.steps[v]
+			if step.Batched && batches {
+				// Applied below, outside of this transaction.
+				return nil
+			}
+			if err = step.Apply(txn); err != nil {
+				return fmt.Errorf("migrate: %s: step %d: %v", s.Name, step.Version, err)
+			}
+			return s.setVersion(txn, step.Version)
+		})
+		if err != nil || step == nil {
+			return err
+		}
+		if step.Batched && batches {
+			if err = s.applyBatched(db, step); err != nil {
+				return err
+			}
+		}
+		log.Printf("migrate: %s: migrated to version %d: %s",
+			s.Name, step.Version, step.Description)
+	}
+}
+
+// applyBatched applies step to db through a kv.BatchTxn, and then records the
+// version after step unless another process has already done so.
+func (s *Schema) applyBatched(db kv.DB, step *Step) error {
+	bt, err := kv.NewBatchTxn(db)
+	if err != nil {
+		return err
+	}
+	if err = step.Apply(bt); err != nil {
+		bt.Discard()
+		return fmt.Errorf("migrate: %s: step %d: %v", s.Name, step.Version, err)
+	}
+	if err = bt.Commit(); err != nil {
+		return fmt.Errorf("migrate: %s: step %d: %v", s.Name, step.Version, err)
+	}
+	return kv.Update(db, func(txn kv.Txn) error {
+		v, err := s.StoredVersion(txn)
+		if err != nil || v != step.Version-1 {
+			return err
+		}
+		return s.setVersion(txn, step.Version)
+	})
+}
+
+// RewriteComponent returns a function, suitable for use as a step, that
+// replaces each value of component c in every partition with the result of
+// calling f with that value.
+//
+// Index entries are not rewritten, so if f changes the values yielded by an
+// indexed component's index methods, the step should also rebuild those
+// indexes.
+func RewriteComponent(c kv.Component, f func(value []byte) ([]byte, error)) func(kv.Txn) error {
+	return func(txn kv.Txn) error {
+		return forEachComponentKey(txn, c, func(key []byte, iter kv.Iterator) error {
+			if len(key) != 8+2+8 || bytes.Equal(key[10:], kv.Entity(0).Encode()) {
+				return nil
+			}
+			var v []byte
+			if err := iter.Value(func(bs []byte) (err error) {
+				v, err = f(bs)
+				return
+			}); err != nil {
+				return err
+			}
+			return txn.Set(kv.ConcatByteSlices(key), v)
+		})
+	}
+}
+
+// RekeyComponent returns a function, suitable for use as a step, that moves
+// every key of component from in every partition, including the keys of its
+// index entries, to component to.
+//
+// Component to should not be in use when the step is applied, since any
+// values it already has may be overwritten.
+func RekeyComponent(from, to kv.Component) func(kv.Txn) error {
+	return func(txn kv.Txn) error {
+		return forEachComponentKey(txn, from, func(key []byte, iter kv.Iterator) error {
+			var v []byte
+			if err := iter.Value(func(bs []byte) error {
+				v = kv.ConcatByteSlices(bs)
+				return nil
+			}); err != nil {
+				return err
+			}
+			key = kv.ConcatByteSlices(key)
+			moved := kv.ConcatByteSlices(key)
+			to.EncodeAt(moved[8:])
+			if err := txn.Set(moved, v); err != nil {
+				return err
+			}
+			return txn.Delete(key)
+		})
+	}
+}
+
+// forEachComponentKey calls f with every key of component c in every
+// partition, along with an iterator positioned at that key, and stops at the
+// first error returned by f.
+func forEachComponentKey(txn kv.Txn, c kv.Component, f func([]byte, kv.Iterator) error) error {
+	iter := txn.PrefixIterator(nil)
+	defer iter.Discard()
+	for iter.Seek(nil); iter.Valid(); {
+		key := iter.Key()
+		if len(key) < 8+2 {
+			// Not a key of any component, like the entity sequence used
+			// by kv/badger.
+			iter.Next()
+			continue
+		}
+		var (
+			p  kv.Entity
+			kc kv.Component
+		)
+		p.Decode(key)
+		kc.Decode(key[8:])
+		switch {
+		case kc < c:
+			iter.Seek(kv.ConcatByteSlices(p.Encode(), c.Encode()))
+		case kc > c:
+			if p+1 == 0 {
+				return nil
+			}
+			iter.Seek(kv.ConcatByteSlices((p + 1).Encode(), c.Encode()))
+		default:
+			if err := f(key, iter); err != nil {
+				return err
+			}
+			iter.Next()
+		}
+	}
+	return nil
+}
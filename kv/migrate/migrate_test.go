@@ -0,0 +1,265 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/kvtest"
+)
+
+func key(p kv.Entity, c kv.Component, rest ...[]byte) []byte {
+	return kv.ConcatByteSlices(append([][]byte{p.Encode(), c.Encode()}, rest...)...)
+}
+
+func set(t *testing.T, db kv.DB, kvs map[string]string) {
+	t.Helper()
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		for k, v := range kvs {
+			if err := txn.Set([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dump returns every key-value pair in db that belongs to a component other
+// than component zero.
+func dump(t *testing.T, db kv.DB) map[string]string {
+	t.Helper()
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	iter := txn.PrefixIterator(nil)
+	defer iter.Discard()
+	m := make(map[string]string)
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		k := iter.Key()
+		if len(k) < 10 || bytes.Equal(k[8:10], kv.Component(0).Encode()) {
+			continue
+		}
+		if err := iter.Value(func(bs []byte) error {
+			m[string(k)] = string(bs)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestRegister(t *testing.T) {
+	s := NewSchema("test")
+	s.Register(1, "first", func(kv.Txn) error { return nil })
+	defer func() {
+		if recover() == nil {
+			t.Error("registering step 3 after step 1 did not panic")
+		}
+	}()
+	s.Register(3, "third", func(kv.Txn) error { return nil })
+}
+
+func TestMigrate(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	set(t, db, map[string]string{string(key(1, 2, kv.Entity(5).Encode())): "a"})
+	var applied []int
+	s := NewSchema("test")
+	for i := 1; i <= 3; i++ {
+		i := i
+		s.Register(uint64(i), "step", func(kv.Txn) error {
+			applied = append(applied, i)
+			return nil
+		})
+	}
+	if err := s.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3 {
+		t.Fatalf("want 3 steps applied, got %v", applied)
+	}
+	s.Register(4, "fails", func(kv.Txn) error { return errors.New("failed") })
+	if err := s.Migrate(db); err == nil {
+		t.Fatal("want an error from a failing step")
+	}
+	txn := db.NewTxn(false)
+	if v, err := s.StoredVersion(txn); err != nil {
+		t.Fatal(err)
+	} else if v != 3 {
+		t.Errorf("want version 3 after a failing step, got %v", v)
+	}
+	txn.Discard()
+
+	// Another schema with fewer steps cannot handle this database.
+	older := NewSchema("test")
+	older.Register(1, "step", func(kv.Txn) error { return nil })
+	if err := older.Migrate(db); err == nil {
+		t.Error("want an error from a database with a newer version")
+	} else if ve, ok := err.(*VersionError); !ok || ve.Stored != 3 || ve.Known != 1 {
+		t.Errorf("want a VersionError, got %v", err)
+	}
+
+	// Schemas with different names have independent versions.
+	other := NewSchema("other")
+	other.Register(1, "step", func(kv.Txn) error { return nil })
+	if err := other.Migrate(db); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigrateBatched(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	set(t, db, map[string]string{string(key(1, 2, kv.Entity(5).Encode())): "a"})
+	s := NewSchema("test")
+	s.Register(1, "step", func(kv.Txn) error { return nil })
+	fail := true
+	var applied int
+	s.RegisterBatched(2, "batched", func(txn kv.Txn) error {
+		applied++
+		if _, ok := txn.(*kv.BatchTxn); !ok {
+			t.Errorf("want a *kv.BatchTxn, got %T", txn)
+		}
+		if err := txn.Set(key(1, 3, kv.Entity(5).Encode()), []byte("b")); err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err := s.Migrate(db); err == nil {
+		t.Fatal("want an error from a failing step")
+	}
+	txn := db.NewTxn(false)
+	if v, err := s.StoredVersion(txn); err != nil {
+		t.Fatal(err)
+	} else if v != 1 {
+		t.Errorf("want version 1 after a failing batched step, got %v", v)
+	}
+	txn.Discard()
+	fail = false
+	if err := s.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if applied != 2 {
+		t.Errorf("want the batched step applied twice, got %v", applied)
+	}
+	txn = db.NewTxn(false)
+	if v, err := s.StoredVersion(txn); err != nil {
+		t.Fatal(err)
+	} else if v != 2 {
+		t.Errorf("want version 2, got %v", v)
+	}
+	txn.Discard()
+	if got := dump(t, db)[string(key(1, 3, kv.Entity(5).Encode()))]; got != "b" {
+		t.Errorf("want the batched step's write committed, got %q", got)
+	}
+}
+
+func TestMigrateNew(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	other := NewSchema("other")
+	other.Register(1, "step", func(kv.Txn) error { return nil })
+	if err := other.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSchema("test")
+	for i := 1; i <= 2; i++ {
+		s.Register(uint64(i), "step", func(kv.Txn) error {
+			t.Error("step applied to a new database")
+			return nil
+		})
+	}
+	if err := s.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	if v, err := s.StoredVersion(txn); err != nil {
+		t.Fatal(err)
+	} else if v != 2 {
+		t.Errorf("want version 2 recorded in a new database, got %v", v)
+	}
+}
+
+func TestRewriteComponent(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	set(t, db, map[string]string{
+		string(key(1, 2, kv.Entity(5).Encode())):                            "a",
+		string(key(1, 3, kv.Entity(5).Encode())):                            "b",
+		string(key(1, 3, kv.Entity(0).Encode(), []byte{0, 1}, []byte("x"))): "i",
+		string(key(2, 3, kv.Entity(6).Encode())):                            "c",
+		string(key(3, 4, kv.Entity(7).Encode())):                            "d",
+	})
+	s := NewSchema("test")
+	s.Register(1, "rewrite", RewriteComponent(3, func(v []byte) ([]byte, error) {
+		return append([]byte("new "), v...), nil
+	}))
+	if err := s.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	got := dump(t, db)
+	for k, v := range map[string]string{
+		string(key(1, 2, kv.Entity(5).Encode())):                            "a",
+		string(key(1, 3, kv.Entity(5).Encode())):                            "new b",
+		string(key(1, 3, kv.Entity(0).Encode(), []byte{0, 1}, []byte("x"))): "i",
+		string(key(2, 3, kv.Entity(6).Encode())):                            "new c",
+		string(key(3, 4, kv.Entity(7).Encode())):                            "d",
+	} {
+		if got[k] != v {
+			t.Errorf("%x: want %q, got %q", k, v, got[k])
+		}
+	}
+}
+
+func TestRekeyComponent(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	index := kv.ConcatByteSlices(kv.Entity(0).Encode(), []byte{0, 1}, []byte("x"))
+	set(t, db, map[string]string{
+		string(key(1, 3, kv.Entity(5).Encode())): "a",
+		string(key(1, 3, index)):                 "i",
+		string(key(1, 4, kv.Entity(5).Encode())): "b",
+		string(key(2, 3, kv.Entity(6).Encode())): "c",
+	})
+	s := NewSchema("test")
+	s.Register(1, "rekey", RekeyComponent(3, 9))
+	if err := s.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		string(key(1, 9, kv.Entity(5).Encode())): "a",
+		string(key(1, 9, index)):                 "i",
+		string(key(1, 4, kv.Entity(5).Encode())): "b",
+		string(key(2, 9, kv.Entity(6).Encode())): "c",
+	}
+	got := dump(t, db)
+	if len(got) != len(want) {
+		t.Errorf("want %d keys, got %d", len(want), len(got))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%x: want %q, got %q", k, v, got[k])
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// The types in this file implement the Encoder and Decoder interfaces with
+// order-preserving encodings: for any two values a and b of the same type, a
+// is less than b if and only if the encoding of a is lexicographically less
+// than the encoding of b. This makes them suitable for use as index values,
+// since index entries are ordered by the bytes of their encoded values.
+
+const signBit = 1 << 63
+
+// Int64 is an alias for int64 that implements the Encoder and Decoder
+// interfaces with an order-preserving encoding.
+type Int64 int64
+
+// Encode encodes i into a new slice of eight bytes.
+func (i Int64) Encode() []byte {
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], uint64(i)^signBit)
+	return bs[:]
+}
+
+// Decode decodes src into i, and returns an error if src is not eight bytes
+// long.
+func (i *Int64) Decode(src []byte) error {
+	if len(src) != 8 {
+		return fmt.Errorf("kv: cannot decode Int64 from %d bytes", len(src))
+	}
+	*i = Int64(binary.BigEndian.Uint64(src) ^ signBit)
+	return nil
+}
+
+// Float64 is an alias for float64 that implements the Encoder and Decoder
+// interfaces with an order-preserving encoding.
+//
+// Negative zero is ordered before positive zero, and NaN values are ordered
+// before negative infinity or after positive infinity depending on their sign
+// bit.
+type Float64 float64
+
+// Encode encodes f into a new slice of eight bytes.
+func (f Float64) Encode() []byte {
+	bits := math.Float64bits(float64(f))
+	if bits&signBit != 0 {
+		bits = ^bits
+	} else {
+		bits |= signBit
+	}
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], bits)
+	return bs[:]
+}
+
+// Decode decodes src into f, and returns an error if src is not eight bytes
+// long.
+func (f *Float64) Decode(src []byte) error {
+	if len(src) != 8 {
+		return fmt.Errorf("kv: cannot decode Float64 from %d bytes", len(src))
+	}
+	bits := binary.BigEndian.Uint64(src)
+	if bits&signBit != 0 {
+		bits &^= signBit
+	} else {
+		bits = ^bits
+	}
+	*f = Float64(math.Float64frombits(bits))
+	return nil
+}
+
+// Time wraps time.Time to implement the Encoder and Decoder interfaces with
+// an order-preserving encoding.
+//
+// The encoding preserves the instant in time to the nanosecond, but not the
+// location or monotonic clock reading: decoded times are in UTC.
+type Time struct{ time.Time }
+
+// Encode encodes t into a new slice of twelve bytes.
+func (t Time) Encode() []byte {
+	var bs [12]byte
+	copy(bs[:], Int64(t.Unix()).Encode())
+	binary.BigEndian.PutUint32(bs[8:], uint32(t.Nanosecond()))
+	return bs[:]
+}
+
+// Decode decodes src into t, and returns an error if src is not twelve bytes
+// long.
+func (t *Time) Decode(src []byte) error {
+	if len(src) != 12 {
+		return fmt.Errorf("kv: cannot decode Time from %d bytes", len(src))
+	}
+	var sec Int64
+	sec.Decode(src[:8])
+	t.Time = time.Unix(int64(sec), int64(binary.BigEndian.Uint32(src[8:]))).UTC()
+	return nil
+}
+
+// Tuple is a composite value that implements the Encoder and Decoder
+// interfaces with an order-preserving encoding, provided that each of its
+// elements does.
+//
+// Tuples are ordered by their first elements, then by their second elements,
+// and so on. A tuple that is a prefix of another is ordered before it, so a
+// shorter tuple can be used as the lower bound of a range of longer tuples
+// that begin with the same elements.
+//
+// To be decoded, each element of a Tuple must also implement Decoder, as a
+// pointer does when it points to a value of any of the types in this package:
+//
+//	var (
+//		language, value kv.String
+//		t = kv.Tuple{&language, &value}
+//	)
+//	err := t.Decode(src)
+type Tuple []Encoder
+
+// Encode encodes each element of t in turn.
+//
+// Each encoded element is escaped, replacing every 0x00 byte with 0x00 0xff,
+// and terminated with 0x00 0x01, so that elements of any length can be
+// concatenated without changing their order.
+func (t Tuple) Encode() []byte {
+	var bs []byte
+	for _, e := range t {
+		bs = appendEscaped(bs, e.Encode())
+	}
+	return bs
+}
+
+// Decode decodes src into the elements of t, and returns an error if src does
+// not encode exactly len(t) elements or if any element of t does not
+// implement Decoder.
+func (t Tuple) Decode(src []byte) error {
+	for i, e := range t {
+		d, ok := e.(Decoder)
+		if !ok {
+			return fmt.Errorf("kv: Tuple element %d of type %T is not a Decoder", i, e)
+		}
+		var (
+			elem []byte
+			err  error
+		)
+		if elem, src, err = cutEscaped(src); err != nil {
+			return fmt.Errorf("kv: Tuple element %d: %v", i, err)
+		} else if err = d.Decode(elem); err != nil {
+			return err
+		}
+	}
+	if len(src) != 0 {
+		return fmt.Errorf("kv: Tuple has more than %d elements", len(t))
+	}
+	return nil
+}
+
+// appendEscaped appends v to dst, replacing every 0x00 byte with 0x00 0xff,
+// and then appends the terminator 0x00 0x01.
+func appendEscaped(dst, v []byte) []byte {
+	for _, b := range v {
+		dst = append(dst, b)
+		if b == 0x00 {
+			dst = append(dst, 0xff)
+		}
+	}
+	return append(dst, 0x00, 0x01)
+}
+
+// cutEscaped reverses appendEscaped for the first escaped value in src,
+// returning that value and the remainder of src.
+func cutEscaped(src []byte) (v, rest []byte, err error) {
+	for {
+		i := bytes.IndexByte(src, 0x00)
+		if i < 0 || i+1 >= len(src) {
+			return nil, nil, fmt.Errorf("escaped value is not terminated")
+		}
+		v = append(v, src[:i+1]...)
+		escape := src[i+1]
+		src = src[i+2:]
+		switch escape {
+		case 0x01:
+			return v[:len(v)-1], src, nil
+		case 0xff:
+		default:
+			return nil, nil, fmt.Errorf("invalid escape 0x%x", escape)
+		}
+	}
+}
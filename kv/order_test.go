@@ -0,0 +1,124 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testOrder checks that each of the values, which are given in ascending
+// order, encodes to a byte slice that is less than that of the next value, and
+// that decoding each encoding into a new value of the same type yields an
+// equal value.
+func testOrder(t *testing.T, values ...Encoder) {
+	t.Helper()
+	var prev []byte
+	for i, v := range values {
+		bs := v.Encode()
+		if i > 0 && bytes.Compare(prev, bs) >= 0 {
+			t.Errorf("%v encodes to %x, not greater than %v at %x",
+				v, bs, values[i-1], prev)
+		}
+		prev = bs
+		d := reflect.New(reflect.TypeOf(v))
+		if err := d.Interface().(Decoder).Decode(bs); err != nil {
+			t.Errorf("%v: %v", v, err)
+		} else if got := d.Elem().Interface(); !reflect.DeepEqual(got, v) {
+			t.Errorf("want %v, got %v", v, got)
+		}
+	}
+}
+
+func TestInt64Order(t *testing.T) {
+	testOrder(t,
+		Int64(math.MinInt64), Int64(-1<<32), Int64(-2), Int64(-1), Int64(0),
+		Int64(1), Int64(2), Int64(1<<32), Int64(math.MaxInt64))
+}
+
+func TestFloat64Order(t *testing.T) {
+	testOrder(t,
+		Float64(math.Inf(-1)), Float64(-math.MaxFloat64), Float64(-1.5),
+		Float64(-math.SmallestNonzeroFloat64), Float64(0),
+		Float64(math.SmallestNonzeroFloat64), Float64(1), Float64(1.5),
+		Float64(math.MaxFloat64), Float64(math.Inf(1)))
+}
+
+func TestTimeOrder(t *testing.T) {
+	base := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	testOrder(t,
+		Time{time.Unix(-1, 0).UTC()}, Time{time.Unix(-1, 999999999).UTC()},
+		Time{time.Unix(0, 0).UTC()}, Time{base}, Time{base.Add(1)},
+		Time{base.Add(time.Second)})
+	if got := (Time{base}).Encode(); !bytes.Equal(got, (Time{base.In(time.FixedZone("X", 3600))}).Encode()) {
+		t.Error("the same instant in different locations encodes differently")
+	}
+}
+
+func TestTupleOrder(t *testing.T) {
+	values := []Tuple{
+		{String(""), String("b")},
+		{String("a")},
+		{String("a"), String("")},
+		{String("a"), String("\x00")},
+		{String("a"), String("\x00\x00")},
+		{String("a"), String("\x01")},
+		{String("a\x00"), String("a")},
+		{String("a\x01")},
+		{String("ab"), String("a")},
+	}
+	var prev []byte
+	for i, v := range values {
+		bs := v.Encode()
+		if i > 0 && bytes.Compare(prev, bs) >= 0 {
+			t.Errorf("%q encodes to %x, not greater than %q at %x",
+				v, bs, values[i-1], prev)
+		}
+		prev = bs
+		got := make(Tuple, len(v))
+		for j := range got {
+			got[j] = new(String)
+		}
+		if err := got.Decode(bs); err != nil {
+			t.Errorf("%q: %v", v, err)
+			continue
+		}
+		for j := range v {
+			if *got[j].(*String) != v[j] {
+				t.Errorf("%q: element %d: got %q", v, j, *got[j].(*String))
+			}
+		}
+	}
+	mixed := Tuple{String("en"), Int64(-3), Float64(2.5)}
+	var (
+		s String
+		i Int64
+		f Float64
+	)
+	if err := (Tuple{&s, &i, &f}).Decode(mixed.Encode()); err != nil {
+		t.Error(err)
+	} else if s != "en" || i != -3 || f != 2.5 {
+		t.Errorf("want %v, got %v %v %v", mixed, s, i, f)
+	}
+	if err := (Tuple{&s, &i}).Decode(mixed.Encode()); err == nil {
+		t.Error("want an error decoding three elements into a Tuple of two")
+	}
+	if err := (Tuple{&s, &i, &f, &s}).Decode(mixed.Encode()); err == nil {
+		t.Error("want an error decoding three elements into a Tuple of four")
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"sort"
+)
+
+// Query describes a set of entities within a partition, such as the entities
+// that have a component value or that are listed under a value in an index,
+// or a combination of other queries.
+//
+// Queries are evaluated lazily by Partitioned.QueryEntities and
+// Partitioned.ForEachQueryEntity, which read entities from the underlying
+// components and indexes in ascending order and in small batches, so that
+// combining queries does not require reading every entity that each of them
+// matches.
+//
+// Code generated by kvschema provides typed constructors for queries of
+// each component and index in a schema.
+type Query interface {
+	// open returns a cursor over the entities in the set, as found in s.
+	open(s Partitioned) queryCursor
+}
+
+// queryCursor reads the entities in a set in ascending order.
+type queryCursor interface {
+	// seek returns the least entity in the set that is greater than or
+	// equal to e, and false if there is no such entity.
+	seek(e Entity) (Entity, bool, error)
+}
+
+// queryBatchSize is the number of entities read at a time by queries that
+// scan keys, so that each scan can use a short-lived iterator: some
+// backends only allow one iterator to be active at a time in a transaction.
+const queryBatchSize = 64
+
+// QueryEntities returns the first n entities matched by q in ascending order,
+// beginning with the first entity greater than or equal to *start.
+//
+// A nil start value will be interpreted as a pointer to zero. When start is
+// not nil, *start is updated such that using it in a subsequent call to
+// QueryEntities would return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Partitioned) QueryEntities(q Query, start *Entity, n int) (es []Entity, err error) {
+	var e Entity
+	if start != nil {
+		e = *start
+	}
+	c := q.open(s)
+	for n <= 0 || len(es) < n {
+		var ok bool
+		if e, ok, err = c.seek(e); err != nil || !ok {
+			break
+		}
+		es = append(es, e)
+		if e == ^Entity(0) {
+			break
+		}
+		e++
+	}
+	if start != nil && len(es) > 0 {
+		*start = es[len(es)-1] + 1
+	}
+	return
+}
+
+// ForEachQueryEntity calls f with each entity matched by q in ascending order,
+// and stops at the first error returned by f.
+func (s Partitioned) ForEachQueryEntity(q Query, f func(Entity) error) error {
+	c := q.open(s)
+	for e := Entity(0); ; e++ {
+		var (
+			ok  bool
+			err error
+		)
+		if e, ok, err = c.seek(e); err != nil || !ok {
+			return err
+		} else if err = f(e); err != nil {
+			return err
+		} else if e == ^Entity(0) {
+			return nil
+		}
+	}
+}
+
+// HasComponent returns a Query matching every entity that has a c value.
+func HasComponent(c Component) Query { return hasComponent(c) }
+
+type hasComponent Component
+
+func (q hasComponent) open(s Partitioned) queryCursor {
+	return &scanCursor{scan: func(start Entity, n int) ([]Entity, error) {
+		return s.AllComponentEntities(Component(q), &start, n)
+	}}
+}
+
+// MatchingComponentIndex returns a Query matching every entity listed under
+// the encoded index value v in the ix index of c values.
+func MatchingComponentIndex(c, ix Component, v []byte) Query {
+	return matchingIndex{c, ix, v}
+}
+
+type matchingIndex struct {
+	c, ix Component
+	v     []byte
+}
+
+func (q matchingIndex) open(s Partitioned) queryCursor {
+	return &sliceCursor{load: func() (EntitySlice, error) {
+		return s.entitiesMatchingIndex(q.c, q.ix, q.v)
+	}}
+}
+
+// sliceCursor implements queryCursor over a sorted EntitySlice that is loaded
+// on the first seek.
+//
+// All the entities listed under a value in an index that is not scalable are
+// stored together, so there is nothing to gain from reading them in batches.
+type sliceCursor struct {
+	load   func() (EntitySlice, error)
+	es     EntitySlice
+	loaded bool
+}
+
+func (c *sliceCursor) seek(e Entity) (Entity, bool, error) {
+	if !c.loaded {
+		var err error
+		if c.es, err = c.load(); err != nil {
+			return 0, false, err
+		}
+		c.loaded = true
+	}
+	if i := c.es.Search(e); i < len(c.es) {
+		return c.es[i], true, nil
+	}
+	return 0, false, nil
+}
+
+// MatchingScalableIndex returns a Query matching every entity listed under
+// the encoded index value v in the scalable ix index of c values.
+func MatchingScalableIndex(c, ix Component, v []byte) Query {
+	return matchingScalableIndex{c, ix, v}
+}
+
+type matchingScalableIndex struct {
+	c, ix Component
+	v     []byte
+}
+
+func (q matchingScalableIndex) open(s Partitioned) queryCursor {
+	prefix := appendEscaped(s.indexPrefix(q.c, q.ix), q.v)
+	return &scanCursor{scan: func(start Entity, n int) (es []Entity, err error) {
+		iter := s.PrefixIterator(prefix)
+		defer iter.Discard()
+		for iter.Seek(start.Encode()); iter.Valid() && len(es) < n; iter.Next() {
+			var e Entity
+			if len(iter.Key()) == 8 {
+				e.Decode(iter.Key())
+				es = append(es, e)
+			}
+		}
+		return es, nil
+	}}
+}
+
+// scanCursor implements queryCursor over a function that returns up to n
+// entities that are greater than or equal to start, keeping the most recent
+// batch to answer seeks that fall within it.
+type scanCursor struct {
+	scan func(start Entity, n int) ([]Entity, error)
+	// buf holds every entity in the set from start through its last
+	// element, or through the end of the set if end is true.
+	buf   []Entity
+	start Entity
+	end   bool
+	valid bool
+}
+
+func (c *scanCursor) seek(e Entity) (Entity, bool, error) {
+	if !c.valid || e < c.start || (!c.end && e > c.buf[len(c.buf)-1]) {
+		buf, err := c.scan(e, queryBatchSize)
+		if err != nil {
+			return 0, false, err
+		}
+		c.buf, c.start, c.end, c.valid = buf, e, len(buf) < queryBatchSize, true
+	}
+	i := sort.Search(len(c.buf), func(i int) bool { return c.buf[i] >= e })
+	if i == len(c.buf) {
+		return 0, false, nil
+	}
+	return c.buf[i], true, nil
+}
+
+// And returns a Query matching the entities that are matched by every one of
+// qs.
+//
+// And is evaluated by leapfrogging: each query is asked only for entities
+// greater than or equal to the greatest entity found so far, so a selective
+// query limits how much of the others is read.
+func And(qs ...Query) Query { return and(qs) }
+
+type and []Query
+
+func (q and) open(s Partitioned) queryCursor {
+	cs := make(andCursor, len(q))
+	for i := range q {
+		cs[i] = q[i].open(s)
+	}
+	return cs
+}
+
+type andCursor []queryCursor
+
+func (cs andCursor) seek(e Entity) (Entity, bool, error) {
+	if len(cs) == 0 {
+		return 0, false, nil
+	}
+	for {
+		agreed := true
+		for _, c := range cs {
+			found, ok, err := c.seek(e)
+			if err != nil || !ok {
+				return 0, false, err
+			} else if found != e {
+				e, agreed = found, false
+			}
+		}
+		if agreed {
+			return e, true, nil
+		}
+	}
+}
+
+// Or returns a Query matching the entities that are matched by any of qs.
+func Or(qs ...Query) Query { return or(qs) }
+
+type or []Query
+
+func (q or) open(s Partitioned) queryCursor {
+	cs := make(orCursor, len(q))
+	for i := range q {
+		cs[i] = q[i].open(s)
+	}
+	return cs
+}
+
+type orCursor []queryCursor
+
+func (cs orCursor) seek(e Entity) (Entity, bool, error) {
+	var (
+		least Entity
+		any   bool
+	)
+	for _, c := range cs {
+		found, ok, err := c.seek(e)
+		if err != nil {
+			return 0, false, err
+		} else if ok && (!any || found < least) {
+			least, any = found, true
+		}
+	}
+	return least, any, nil
+}
+
+// AndNot returns a Query matching the entities that are matched by q but not
+// by not.
+func AndNot(q, not Query) Query { return andNot{q, not} }
+
+type andNot struct{ q, not Query }
+
+func (q andNot) open(s Partitioned) queryCursor {
+	return andNotCursor{q.q.open(s), q.not.open(s)}
+}
+
+type andNotCursor struct{ c, not queryCursor }
+
+func (c andNotCursor) seek(e Entity) (Entity, bool, error) {
+	for {
+		found, ok, err := c.c.seek(e)
+		if err != nil || !ok {
+			return 0, false, err
+		}
+		excluded, ok, err := c.not.seek(found)
+		if err != nil {
+			return 0, false, err
+		} else if !ok || excluded != found {
+			return found, true, nil
+		} else if found == ^Entity(0) {
+			return 0, false, nil
+		}
+		e = found + 1
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import "fmt"
+
+// A reference is a component value's pointer to another entity, such as the
+// parent of a child. The entities referring to each entity are listed in a
+// scalable index, the reverse index of the reference, so that they can be
+// found when the referenced entity is deleted.
+
+// ReferencedEntities returns the entities that a reference holding e refers
+// to, for use as the index values of its reverse index: none if e is zero, and
+// otherwise just e.
+func ReferencedEntities(e Entity) []Entity {
+	if e == 0 {
+		return nil
+	}
+	return []Entity{e}
+}
+
+// ReferenceError is returned when deleting an entity is restricted because
+// another entity refers to it.
+type ReferenceError struct {
+	// Component and Reference identify the reverse index of the reference.
+	Component, Reference Component
+	// Entity is the entity that could not be deleted.
+	Entity Entity
+	// Referrer is an entity with a c value that refers to Entity.
+	Referrer Entity
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("kv: reference %d of component %d of entity %d restricts deleting entity %d",
+		e.Reference, e.Component, e.Referrer, e.Entity)
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A scalable index stores each pair of index value and entity under its own
+// key, rather than storing all the entities listed under an index value in a
+// single EntitySlice. Adding an entity to a scalable index or removing one
+// from it is a single write no matter how many other entities share the same
+// index value, at the cost of a larger key for every entry.
+//
+// The key of each entry in a scalable index is the usual index key prefix,
+// followed by the index value escaped as an element of a Tuple, followed by
+// the entity. Escaping keeps entries ordered by index value and then by
+// entity, and lets the index value be recovered from the key.
+
+// scalableIndexValue is the value stored for every entry in a scalable index.
+//
+// It is not empty so that Get can distinguish an entry from a missing key.
+var scalableIndexValue = []byte{1}
+
+// indexPrefix returns the prefix of every key in the ix index of c values.
+func (s Partitioned) indexPrefix(c, ix Component) Prefix {
+	key := make(Prefix, 8+2+8+2)
+	s.Partition.EncodeAt(key)
+	c.EncodeAt(key[8:])
+	Entity(0).EncodeAt(key[10:])
+	ix.EncodeAt(key[18:])
+	return key
+}
+
+// scalableIndexKey returns the key of the entry listing e under the encoded
+// index value iv in the scalable index with the given key prefix.
+func scalableIndexKey(index Prefix, iv []byte, e Entity) []byte {
+	key := appendEscaped(ConcatByteSlices(index), iv)
+	return append(key, e.Encode()...)
+}
+
+// splitScalableIndexKey returns the encoded index value and entity from the
+// key of an entry in a scalable index, relative to the index's key prefix.
+func splitScalableIndexKey(key []byte) (iv []byte, e Entity, err error) {
+	var rest []byte
+	if iv, rest, err = cutEscaped(key); err != nil {
+		return nil, 0, fmt.Errorf("kv: invalid scalable index key %x: %v", key, err)
+	} else if len(rest) != 8 {
+		return nil, 0, fmt.Errorf("kv: invalid scalable index key %x", key)
+	}
+	return iv, e, e.Decode(rest)
+}
+
+// InsertScalableIndexEntry lists e under the encoded index value v in the
+// scalable ix index of c values.
+func (s Partitioned) InsertScalableIndexEntry(c, ix Component, v []byte, e Entity) error {
+	return s.insertIndexEntry(s.indexPrefix(c, ix), true, v, e)
+}
+
+// RemoveScalableIndexEntry removes e from the entities listed under the
+// encoded index value v in the scalable ix index of c values.
+func (s Partitioned) RemoveScalableIndexEntry(c, ix Component, v []byte, e Entity) error {
+	return s.Delete(scalableIndexKey(s.indexPrefix(c, ix), v, e))
+}
+
+// EntitiesMatchingScalableIndex returns the entities listed under the encoded
+// index value v in the scalable ix index of c values.
+//
+// The returned EntitySlice is already sorted.
+func (s Partitioned) EntitiesMatchingScalableIndex(c, ix Component, v []byte) (EntitySlice, error) {
+	iter := s.PrefixIterator(appendEscaped(s.indexPrefix(c, ix), v))
+	defer iter.Discard()
+	var es EntitySlice
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		var e Entity
+		if len(iter.Key()) != 8 {
+			return es, fmt.Errorf("kv: invalid scalable index key suffix %x", iter.Key())
+		}
+		e.Decode(iter.Key())
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+// EntitiesByScalableIndex is like EntitiesByComponentIndex for a scalable
+// index.
+//
+// A cursor used with EntitiesByScalableIndex must only be used with the same
+// scalable index.
+func (s Partitioned) EntitiesByScalableIndex(c, ix Component, cursor *IndexCursor, n int) (es []Entity, err error) {
+	iter := s.PrefixIterator(s.indexPrefix(c, ix))
+	defer iter.Discard()
+	return entitiesByScalableIndex(iter, cursor, n)
+}
+
+// EntitiesByScalableIndexRange is like EntitiesByComponentIndexRange for a
+// scalable index.
+func (s Partitioned) EntitiesByScalableIndexRange(c, ix Component, lower, upper []byte, cursor *IndexCursor, n int) (es []Entity, err error) {
+	if lower != nil {
+		lower = appendEscaped(nil, lower)
+	}
+	if upper != nil {
+		upper = appendEscaped(nil, upper)
+	}
+	iter := s.RangeIterator(s.indexPrefix(c, ix), lower, upper)
+	defer iter.Discard()
+	return entitiesByScalableIndex(iter, cursor, n)
+}
+
+// EntitiesByScalableIndexReverse is like EntitiesByComponentIndexReverse for
+// a scalable index.
+//
+// A cursor used with EntitiesByScalableIndexReverse must not be used with
+// EntitiesByScalableIndex, or vice versa.
+func (s Partitioned) EntitiesByScalableIndexReverse(c, ix Component, cursor *IndexCursor, n int) (es []Entity, err error) {
+	iter := s.ReversePrefixIterator(s.indexPrefix(c, ix))
+	defer iter.Discard()
+	return entitiesByScalableIndex(iter, cursor, n)
+}
+
+// entitiesByScalableIndex implements reading a page of entities from an
+// iterator over a scalable index, in whichever direction the iterator goes.
+//
+// The cursor records the key of the last entry read, with an Offset of one to
+// show that the entry at that key has already been read.
+func entitiesByScalableIndex(iter Iterator, cursor *IndexCursor, n int) (es []Entity, err error) {
+	for iter.Seek(cursor.Key); iter.Valid(); iter.Next() {
+		if cursor.Offset > 0 && bytes.Equal(cursor.Key, iter.Key()) {
+			continue
+		}
+		if n > 0 && len(es) >= n {
+			return
+		}
+		var e Entity
+		if _, e, err = splitScalableIndexKey(iter.Key()); err != nil {
+			return
+		}
+		es = append(es, e)
+		cursor.Key = append(cursor.Key[:0], iter.Key()...)
+		cursor.Offset = 1
+	}
+	return
+}
+
+// CheckScalableIndex is like CheckComponentIndex for a scalable index.
+func (s Partitioned) CheckScalableIndex(c, ix Component, values func([]byte) ([][]byte, error), f func(*IndexError) error) error {
+	return s.checkIndex(c, ix, true, values, f)
+}
+
+// RebuildScalableIndex is like RebuildComponentIndex for a scalable index.
+//
+// Since both kinds of index are stored under the same key prefix, a
+// component index can be converted into a scalable index by rebuilding it.
+func (s Partitioned) RebuildScalableIndex(c, ix Component, values func([]byte) ([][]byte, error)) error {
+	return s.rebuildIndex(c, ix, true, values)
+}
@@ -0,0 +1,444 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlite provides an implementation of kv.DB that stores all
+// key-value pairs in a single table of a SQLite database file, so that the
+// database can also be opened by external tools:
+//
+//	CREATE TABLE kv (key BLOB PRIMARY KEY, value BLOB NOT NULL)
+//
+// SQLite compares BLOB values with memcmp, so the table is ordered just like
+// any other kv keyspace.
+//
+// The database is opened in WAL mode, which gives each transaction a snapshot
+// of the database as it was when the transaction was created. SQLite allows
+// only one update transaction at a time, so NewTxn(true) blocks until any
+// other update transaction has been committed or discarded, and Commit never
+// returns kv.ErrConflict.
+//
+// Entities are allocated from a row of a second table, which every process
+// that opens the database shares:
+//
+//	CREATE TABLE sequence (name TEXT PRIMARY KEY, value INTEGER NOT NULL)
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/note-maps/kv"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS kv (
+	key BLOB PRIMARY KEY,
+	value BLOB NOT NULL
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS sequence (
+	name TEXT PRIMARY KEY,
+	value INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO sequence (name, value) VALUES ('entity', 0);
+`
+
+// batchSize is the number of rows an iterator reads with each query.
+const batchSize = 64
+
+// allocQuery allocates an entity by incrementing the sequence.
+const allocQuery = `UPDATE sequence SET value = value + 1 WHERE name = 'entity' RETURNING value`
+
+// DB is a SQLite database that implements kv.DB.
+type DB struct {
+	// rw is limited to one connection, on which every transaction begins
+	// with BEGIN IMMEDIATE, and ro is used for read-only transactions.
+	rw, ro *sql.DB
+}
+
+// Open creates or opens the SQLite database file at path.
+func Open(path string) (*DB, error) {
+	dsn := func(txlock string) string {
+		q := url.Values{}
+		q.Set("_busy_timeout", "10000")
+		q.Set("_journal_mode", "WAL")
+		q.Set("_txlock", txlock)
+		return "file:" + path + "?" + q.Encode()
+	}
+	rw, err := sql.Open("sqlite3", dsn("immediate"))
+	if err != nil {
+		return nil, err
+	}
+	rw.SetMaxOpenConns(1)
+	if _, err = rw.Exec(schema); err != nil {
+		rw.Close()
+		return nil, err
+	}
+	ro, err := sql.Open("sqlite3", dsn("deferred"))
+	if err != nil {
+		rw.Close()
+		return nil, err
+	}
+	return &DB{rw: rw, ro: ro}, nil
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	if db == nil {
+		return nil
+	}
+	err := db.ro.Close()
+	if cerr := db.rw.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// NewTxn creates a new kv.TxnCommitDiscarder.
+//
+// If update is true, NewTxn blocks until no other update transaction is open.
+func (db *DB) NewTxn(update bool) kv.TxnCommitDiscarder {
+	pool := db.ro
+	if update {
+		pool = db.rw
+	}
+	tx, err := pool.Begin()
+	if err != nil {
+		return errTxn{err}
+	}
+	if !update {
+		// A deferred transaction does not take its snapshot until its first
+		// read, so read something now.
+		var n uint64
+		err = tx.QueryRow(`SELECT value FROM sequence WHERE name = 'entity'`).
+			Scan(&n)
+		if err != nil {
+			tx.Rollback()
+			return errTxn{err}
+		}
+	}
+	return &txn{db: db, tx: tx, update: update}
+}
+
+// alloc allocates an entity through q, which is either an update
+// transaction or db.rw.
+func alloc(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}) (kv.Entity, error) {
+	var e uint64
+	err := q.QueryRow(allocQuery).Scan(&e)
+	return kv.Entity(e), err
+}
+
+type txn struct {
+	db     *DB
+	tx     *sql.Tx
+	update bool
+
+	// err is the first error encountered by an iterator, which cannot
+	// return it, so that Commit can return it instead.
+	err error
+}
+
+// Alloc allocates an entity from the sequence table.
+//
+// An update transaction allocates within itself, so that its allocations
+// are committed or discarded along with its writes. A read-only transaction
+// cannot write, so it allocates with a separate statement that commits
+// immediately, and that waits for any open update transaction to end.
+func (s *txn) Alloc() (kv.Entity, error) {
+	if s.update {
+		return alloc(s.tx)
+	}
+	return alloc(s.db.rw)
+}
+
+func (s *txn) Set(key, value []byte) error {
+	if !s.update {
+		return errReadOnly
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := s.tx.Exec(
+		`INSERT OR REPLACE INTO kv (key, value) VALUES (?, ?)`, key, value)
+	return err
+}
+
+func (s *txn) Delete(key []byte) error {
+	if !s.update {
+		return errReadOnly
+	}
+	_, err := s.tx.Exec(`DELETE FROM kv WHERE key = ?`, key)
+	return err
+}
+
+func (s *txn) Get(key []byte, f func([]byte) error) error {
+	var value []byte
+	err := s.tx.QueryRow(`SELECT value FROM kv WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return f(nil)
+	} else if err != nil {
+		return err
+	}
+	return f(value)
+}
+
+func (s *txn) PrefixIterator(prefix []byte) kv.Iterator {
+	return s.newIterator(prefix, prefix, kv.PrefixEnd(prefix), false)
+}
+
+func (s *txn) ReversePrefixIterator(prefix []byte) kv.Iterator {
+	return s.newIterator(prefix, prefix, kv.PrefixEnd(prefix), true)
+}
+
+func (s *txn) RangeIterator(prefix, lower, upper []byte) kv.Iterator {
+	hi := kv.PrefixEnd(prefix)
+	if upper != nil {
+		hi = kv.ConcatByteSlices(prefix, upper)
+	}
+	return s.newIterator(prefix, kv.ConcatByteSlices(prefix, lower), hi, false)
+}
+
+func (s *txn) newIterator(prefix, lo, hi []byte, reverse bool) *iterator {
+	return &iterator{
+		txn:     s,
+		prefix:  prefix,
+		lo:      lo,
+		hi:      hi,
+		reverse: reverse,
+	}
+}
+
+// Commit commits an update transaction, or simply ends a read-only
+// transaction.
+//
+// If an iterator has failed to read from the database, Commit discards the
+// transaction and returns the error, since the iteration may have ended
+// early.
+func (s *txn) Commit() error {
+	if s.err != nil {
+		s.tx.Rollback()
+		return s.err
+	}
+	if !s.update {
+		return s.tx.Rollback()
+	}
+	return s.tx.Commit()
+}
+
+func (s *txn) Discard() {
+	// Rollback returns an error if the transaction has already been
+	// committed, and that is fine.
+	s.tx.Rollback()
+}
+
+var errReadOnly = errors.New("sqlite: cannot update a read-only transaction")
+
+// errTxn is returned by NewTxn when a transaction cannot begin. It returns
+// the same error from every method.
+type errTxn struct{ err error }
+
+func (s errTxn) Alloc() (kv.Entity, error)                             { return 0, s.err }
+func (s errTxn) Set(key, value []byte) error                           { return s.err }
+func (s errTxn) Delete(key []byte) error                               { return s.err }
+func (s errTxn) Get(key []byte, f func([]byte) error) error            { return s.err }
+func (s errTxn) PrefixIterator(prefix []byte) kv.Iterator              { return &iterator{} }
+func (s errTxn) ReversePrefixIterator(prefix []byte) kv.Iterator       { return &iterator{} }
+func (s errTxn) RangeIterator(prefix, lower, upper []byte) kv.Iterator { return &iterator{} }
+func (s errTxn) Commit() error                                         { return s.err }
+func (s errTxn) Discard()                                              {}
+
+type pair struct{ key, value []byte }
+
+// iterator implements kv.Iterator over keys within [lo, hi), reading them
+// with ordered range SELECTs of up to batchSize rows at a time so that no
+// statement is left open while the transaction is written to.
+//
+// If a SELECT fails, the iterator becomes invalid, and its error is returned
+// by Value and by the Commit of its transaction.
+type iterator struct {
+	txn     *txn
+	prefix  []byte
+	lo, hi  []byte
+	reverse bool
+
+	pairs []pair
+	i     int
+	more  bool
+	err   error
+}
+
+// fill replaces the current batch with rows that follow from, which is
+// included only if exclusive is false. In reverse, a nil from means the end
+// of the range.
+func (i *iterator) fill(from []byte, exclusive bool) {
+	i.pairs, i.i, i.more = i.pairs[:0], 0, false
+	if i.txn == nil || i.err != nil {
+		return
+	}
+	var (
+		conds = []string{"1"}
+		args  []interface{}
+		order = "ASC"
+	)
+	if len(i.lo) > 0 {
+		conds = append(conds, "key >= ?")
+		args = append(args, i.lo)
+	}
+	if i.hi != nil {
+		conds = append(conds, "key < ?")
+		args = append(args, i.hi)
+	}
+	if from != nil {
+		op := ">="
+		switch {
+		case i.reverse && exclusive:
+			op = "<"
+		case i.reverse:
+			op = "<="
+		case exclusive:
+			op = ">"
+		}
+		conds = append(conds, "key "+op+" ?")
+		args = append(args, from)
+	}
+	if i.reverse {
+		order = "DESC"
+	}
+	rows, err := i.txn.tx.Query(fmt.Sprintf(
+		"SELECT key, value FROM kv WHERE %s ORDER BY key %s LIMIT %d",
+		strings.Join(conds, " AND "), order, batchSize), args...)
+	if err != nil {
+		i.fail(err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p pair
+		if err := rows.Scan(&p.key, &p.value); err != nil {
+			i.fail(err)
+			return
+		}
+		i.pairs = append(i.pairs, p)
+	}
+	if err := rows.Err(); err != nil {
+		i.fail(err)
+		return
+	}
+	i.more = len(i.pairs) == batchSize
+}
+
+// fail makes the iterator invalid, and records err so that Value and Commit
+// return it.
+func (i *iterator) fail(err error) {
+	i.pairs, i.i, i.more = i.pairs[:0], 0, false
+	i.err = err
+	if i.txn.err == nil {
+		i.txn.err = i.err
+	}
+}
+
+func (i *iterator) Seek(key []byte) {
+	switch {
+	case i.reverse && key == nil:
+		i.fill(nil, false)
+	case !i.reverse && bytes.Compare(key, i.lo[len(i.prefix):]) < 0:
+		i.fill(i.lo, false)
+	default:
+		i.fill(kv.ConcatByteSlices(i.prefix, key), false)
+	}
+}
+
+func (i *iterator) Next() {
+	i.i++
+	if i.i == len(i.pairs) && i.more {
+		i.fill(i.pairs[len(i.pairs)-1].key, true)
+	}
+}
+
+func (i *iterator) Valid() bool { return i.i < len(i.pairs) }
+
+func (i *iterator) Key() []byte { return i.pairs[i.i].key[len(i.prefix):] }
+
+func (i *iterator) Value(f func([]byte) error) error {
+	if i.err != nil {
+		return i.err
+	}
+	return f(i.pairs[i.i].value)
+}
+
+func (i *iterator) Discard() {}
+
+// NewBatch returns a kv.Batch that buffers writes in memory and applies them
+// in a single update transaction when flushed. SQLite does not limit the size
+// of a transaction.
+func (db *DB) NewBatch() kv.Batch {
+	return &batch{db: db}
+}
+
+type batch struct {
+	db     *DB
+	writes []pair
+}
+
+func (b *batch) Set(key, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	b.writes = append(b.writes, pair{key, value})
+	return nil
+}
+
+// Delete records a deletion as a pair with a nil value.
+func (b *batch) Delete(key []byte) error {
+	b.writes = append(b.writes, pair{key, nil})
+	return nil
+}
+
+func (b *batch) Flush() error {
+	writes := b.writes
+	b.writes = nil
+	tx, err := b.db.rw.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	set, err := tx.Prepare(`INSERT OR REPLACE INTO kv (key, value) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer set.Close()
+	del, err := tx.Prepare(`DELETE FROM kv WHERE key = ?`)
+	if err != nil {
+		return err
+	}
+	defer del.Close()
+	for _, w := range writes {
+		if w.value == nil {
+			_, err = del.Exec(w.key)
+		} else {
+			_, err = set.Exec(w.key, w.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *batch) Cancel() { b.writes = nil }
@@ -0,0 +1,287 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/note-maps/kv"
+)
+
+func openTemp(t *testing.T) (*DB, func()) {
+	dir, err := ioutil.TempDir("", "kv-sqlite-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(filepath.Join(dir, "db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNew(t *testing.T) {
+	db, cleanup := openTemp(t)
+	defer cleanup()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	want := "value"
+	if err := txn.Set([]byte("key"), []byte(want)); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	err := txn.Get([]byte("key"), func(bs []byte) error {
+		got = string(bs)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if want != got {
+		t.Errorf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestAlloc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kv-sqlite-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db")
+	done := make(map[kv.Entity]bool)
+	alloc := func(txn kv.Txn) {
+		e, err := txn.Alloc()
+		if err != nil {
+			t.Fatal(err)
+		} else if e == 0 || done[e] {
+			t.Fatal("bad entity", e)
+		}
+		done[e] = true
+	}
+	for reopen := 0; reopen < 3; reopen++ {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := db.NewTxn(false)
+		for i := 0; i < 200; i++ {
+			alloc(r)
+		}
+		r.Discard()
+		w := db.NewTxn(true)
+		for i := 0; i < 10; i++ {
+			alloc(w)
+		}
+		if err := w.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAllocShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kv-sqlite-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db")
+	// Each DB stands in for a separate process that has opened the file.
+	var dbs []*DB
+	for i := 0; i < 2; i++ {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		dbs = append(dbs, db)
+	}
+	done := make(map[kv.Entity]bool)
+	for i := 0; i < 10; i++ {
+		for _, db := range dbs {
+			for _, update := range []bool{false, true} {
+				txn := db.NewTxn(update)
+				e, err := txn.Alloc()
+				if err != nil {
+					t.Fatal(err)
+				} else if e == 0 || done[e] {
+					t.Fatal("bad entity", e)
+				}
+				done[e] = true
+				if err := txn.Commit(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	db, cleanup := openTemp(t)
+	defer cleanup()
+	r := db.NewTxn(false)
+	defer r.Discard()
+	w := db.NewTxn(true)
+	if err := w.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get([]byte("a"), func(bs []byte) error {
+		if bs != nil {
+			t.Errorf("got %q in older snapshot, want nothing", bs)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set([]byte("b"), []byte("2")); err == nil {
+		t.Error("expected error from Set in read-only transaction")
+	}
+}
+
+func TestDeleteWhileIterating(t *testing.T) {
+	db, cleanup := openTemp(t)
+	defer cleanup()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	var want []string
+	for i := 0; i < 1000; i++ {
+		k := string([]byte{'a', byte(i >> 8), byte(i)})
+		want = append(want, k[1:])
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	iter := txn.PrefixIterator([]byte("a"))
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+		if err := txn.Delete(kv.ConcatByteSlices([]byte("a"), iter.Key())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	iter.Discard()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v keys, got %v", len(want), len(got))
+	}
+	iter = txn.PrefixIterator([]byte("a"))
+	defer iter.Discard()
+	if iter.Seek(nil); iter.Valid() {
+		t.Errorf("found %q after deleting everything", iter.Key())
+	}
+}
+
+func TestIteratorError(t *testing.T) {
+	db, cleanup := openTemp(t)
+	defer cleanup()
+	s := db.NewTxn(true)
+	defer s.Discard()
+	if err := s.Set([]byte("a"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	// Make every query of the transaction fail.
+	s.(*txn).tx.Rollback()
+	iter := s.PrefixIterator(nil)
+	defer iter.Discard()
+	if iter.Seek(nil); iter.Valid() {
+		t.Errorf("found %q after a failed query", iter.Key())
+	}
+	if err := iter.Value(func([]byte) error { return nil }); err == nil {
+		t.Error("Value did not return the error of the failed query")
+	}
+	if err := s.Commit(); err == nil {
+		t.Error("Commit did not return the error of the failed query")
+	}
+}
+
+func TestReverseIterator(t *testing.T) {
+	db, cleanup := openTemp(t)
+	defer cleanup()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	for _, k := range []string{"a\xff", "a0", "a1", "a2", "b", "b0"} {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		Prefix string
+		Seek   []byte
+		Want   []string
+	}{
+		{"a", nil, []string{"\xff", "2", "1", "0"}},
+		{"a", []byte("1"), []string{"1", "0"}},
+		{"a", []byte("10"), []string{"1", "0"}},
+		{"a\xff", nil, []string{""}},
+		{"b", nil, []string{"0", ""}},
+		{"", nil, []string{"b0", "b", "a\xff", "a2", "a1", "a0"}},
+	} {
+		var got []string
+		iter := txn.ReversePrefixIterator([]byte(test.Prefix))
+		for iter.Seek(test.Seek); iter.Valid(); iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		iter.Discard()
+		if !reflect.DeepEqual(test.Want, got) {
+			t.Errorf("%q: Seek(%q): want %q, got %q",
+				test.Prefix, test.Seek, test.Want, got)
+		}
+	}
+}
+
+func TestRangeIterator(t *testing.T) {
+	db, cleanup := openTemp(t)
+	defer cleanup()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	for _, k := range []string{"a0", "a1", "a2", "a3", "b0"} {
+		if err := txn.Set([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		Lower, Upper []byte
+		Want         []string
+	}{
+		{nil, nil, []string{"0", "1", "2", "3"}},
+		{[]byte("1"), []byte("3"), []string{"1", "2"}},
+		{[]byte("10"), nil, []string{"2", "3"}},
+		{nil, []byte("1"), []string{"0"}},
+		{[]byte("2"), []byte("2"), nil},
+	} {
+		var got []string
+		iter := txn.RangeIterator([]byte("a"), test.Lower, test.Upper)
+		for iter.Seek(nil); iter.Valid(); iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		iter.Discard()
+		if !reflect.DeepEqual(test.Want, got) {
+			t.Errorf("[%q,%q): want %q, got %q",
+				test.Lower, test.Upper, test.Want, got)
+		}
+	}
+}
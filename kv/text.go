@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// A text index is a scalable index that lists each entity under the words
+// found in its String index values, rather than under the values themselves,
+// so that entities can be found by searching for some of those words or for
+// prefixes of them.
+
+// Tokenize splits s into the words that a text index would list it under.
+//
+// Words are runs of letters and digits. Each word is folded into a canonical
+// form so that searches match regardless of case or accents: it is decomposed
+// into Unicode normalization form NFKD, stripped of combining marks, and
+// converted to lower case.
+func Tokenize(s string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.FieldsFunc(b.String(), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// TextTerms returns the distinct words found in ss, in ascending order, for
+// use as the index values of a text index.
+func TextTerms(ss []String) []String {
+	var terms []String
+	seen := make(map[string]bool)
+	for _, s := range ss {
+		for _, w := range Tokenize(string(s)) {
+			if !seen[w] {
+				seen[w] = true
+				terms = append(terms, String(w))
+			}
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i] < terms[j] })
+	return terms
+}
+
+// TextMatch is an entity found by searching a text index, with a score that
+// is greater for better matches.
+type TextMatch struct {
+	Entity Entity
+	Score  int
+}
+
+// SearchTextIndex returns up to n of the entities listed in the text ix index
+// of c values that match any of the words in query, best matches first.
+//
+// Each word in query matches the indexed words that begin with it. An entity
+// scores two points for each word in query that it matches exactly and one
+// point for each word that it only matches as a prefix. Entities with equal
+// scores are returned in ascending order.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Partitioned) SearchTextIndex(c, ix Component, query string, n int) ([]TextMatch, error) {
+	index := s.indexPrefix(c, ix)
+	scores := make(map[Entity]int)
+	seen := make(map[string]bool)
+	for _, w := range Tokenize(query) {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		best := make(map[Entity]int)
+		iter := s.PrefixIterator(ConcatByteSlices(index, []byte(w)))
+		for iter.Seek(nil); iter.Valid(); iter.Next() {
+			// The rest of the key is the rest of the indexed word, which
+			// is empty for an exact match, followed by the 0x00 0x01
+			// terminator and the entity.
+			key := iter.Key()
+			if len(key) < 10 {
+				continue
+			}
+			var e Entity
+			e.Decode(key[len(key)-8:])
+			score := 1
+			if len(key) == 10 {
+				score = 2
+			}
+			if score > best[e] {
+				best[e] = score
+			}
+		}
+		iter.Discard()
+		for e, score := range best {
+			scores[e] += score
+		}
+	}
+	ms := make([]TextMatch, 0, len(scores))
+	for e, score := range scores {
+		ms = append(ms, TextMatch{e, score})
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Score != ms[j].Score {
+			return ms[i].Score > ms[j].Score
+		}
+		return ms[i].Entity < ms[j].Entity
+	})
+	if n > 0 && len(ms) > n {
+		ms = ms[:n]
+	}
+	return ms, nil
+}
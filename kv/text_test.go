@@ -0,0 +1,48 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, test := range []struct {
+		In   string
+		Want []string
+	}{
+		{"", []string{}},
+		{"  ", []string{}},
+		{"Hello, World!", []string{"hello", "world"}},
+		{"Crème Brûlée", []string{"creme", "brulee"}},
+		{"ＦＵＬＬ width", []string{"full", "width"}},
+		{"route-66 x2", []string{"route", "66", "x2"}},
+		{"Ελληνικά", []string{"ελληνικα"}},
+	} {
+		got := Tokenize(test.In)
+		if !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("Tokenize(%q): got %q, want %q", test.In, got, test.Want)
+		}
+	}
+}
+
+func TestTextTerms(t *testing.T) {
+	got := TextTerms([]String{"the Cat sat", "on the mat"})
+	want := []String{"cat", "mat", "on", "sat", "the"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrWatchUnsupported is returned by Watch when a DB does not
+	// implement Watcher.
+	ErrWatchUnsupported = errors.New("kv: DB does not support watching")
+
+	// ErrSubscriberBehind ends a Subscription when more than
+	// MaxPendingCommits commits are waiting to be received from it.
+	ErrSubscriberBehind = errors.New("kv: subscriber fell too far behind")
+)
+
+// MaxPendingCommits is the number of commits that a Subscription holds for
+// its subscriber before it ends with ErrSubscriberBehind.
+const MaxPendingCommits = 1 << 12
+
+// Change describes a write to a key made by a committed transaction.
+type Change struct {
+	Key []byte
+	// Value is the value written to Key, or nil if Key was deleted.
+	Value []byte
+	// Deleted is true if Key was deleted, whether or not it had a value.
+	Deleted bool
+}
+
+// Subscription reports the changes made by committed transactions to the keys
+// matching a prefix.
+//
+// Commits never wait for subscribers. Instead, each Subscription holds the
+// changes that its subscriber has not yet received, and ends with
+// ErrSubscriberBehind if a subscriber falls more than MaxPendingCommits
+// commits behind.
+type Subscription interface {
+	// Changes returns a channel that receives the changes made by each
+	// commit, in the order in which they were committed, with the changes
+	// of each commit sorted by key. Commits that do not change any matching
+	// key are not reported.
+	//
+	// The channel is closed when the subscription ends.
+	Changes() <-chan []Change
+
+	// Close ends the subscription. Changes that have not yet been received
+	// may be dropped.
+	Close()
+
+	// Err returns the error that ended the subscription, if it ended for
+	// any reason other than a call to Close.
+	Err() error
+}
+
+// Watcher is implemented by DBs that can report the changes made by committed
+// transactions.
+type Watcher interface {
+	// Watch returns a new Subscription to the changes made to keys that
+	// begin with prefix. Every commit that completes after Watch returns
+	// is reported.
+	Watch(prefix []byte) (Subscription, error)
+}
+
+// Feed implements Subscription for implementations of Watcher, which call
+// Publish for each commit without waiting for the subscriber to receive it.
+type Feed struct {
+	stop func()
+
+	mutex sync.Mutex
+	queue [][]Change
+	ended bool
+	err   error
+
+	// wake receives a value whenever the queue may have become non-empty.
+	wake chan struct{}
+	// done is closed when the feed ends.
+	done    chan struct{}
+	changes chan []Change
+}
+
+// NewFeed returns a new Feed, which calls stop once it has ended for any
+// reason, so that publishers can release their resources. Stop is called
+// from the feed's own goroutine, so it may wait for publishers.
+func NewFeed(stop func()) *Feed {
+	f := &Feed{
+		stop:    stop,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		changes: make(chan []Change),
+	}
+	go f.run()
+	return f
+}
+
+// Publish queues the changes made by a commit, which must be sorted by key,
+// unless there are none or the feed has ended.
+//
+// If more than MaxPendingCommits commits are then waiting to be received, the
+// feed ends with ErrSubscriberBehind.
+func (f *Feed) Publish(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	f.mutex.Lock()
+	if f.ended {
+		f.mutex.Unlock()
+		return
+	}
+	f.queue = append(f.queue, changes)
+	behind := len(f.queue) > MaxPendingCommits
+	f.mutex.Unlock()
+	if behind {
+		f.End(ErrSubscriberBehind)
+		return
+	}
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// End ends the feed, recording err as the reason unless it has already
+// ended. Changes that have not yet been received are dropped.
+func (f *Feed) End(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.ended {
+		return
+	}
+	f.ended, f.err, f.queue = true, err, nil
+	close(f.done)
+}
+
+// run delivers queued changes until the feed ends.
+func (f *Feed) run() {
+	defer f.stop()
+	defer close(f.changes)
+	for {
+		f.mutex.Lock()
+		var next []Change
+		if len(f.queue) > 0 {
+			next, f.queue[0], f.queue = f.queue[0], nil, f.queue[1:]
+		}
+		f.mutex.Unlock()
+		if next == nil {
+			select {
+			case <-f.wake:
+				continue
+			case <-f.done:
+				return
+			}
+		}
+		select {
+		case f.changes <- next:
+		case <-f.done:
+			return
+		}
+	}
+}
+
+func (f *Feed) Changes() <-chan []Change { return f.changes }
+
+func (f *Feed) Close() { f.End(nil) }
+
+func (f *Feed) Err() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.err
+}
+
+// Watch returns a new Subscription to the changes made to keys in db that
+// begin with prefix, or ErrWatchUnsupported if db does not implement Watcher.
+//
+// To watch a partition, use the encoding of the partition's entity as the
+// prefix.
+func Watch(db DB, prefix []byte) (Subscription, error) {
+	w, ok := db.(Watcher)
+	if !ok {
+		return nil, ErrWatchUnsupported
+	}
+	return w.Watch(prefix)
+}
+
+// SplitComponentKey splits the key of a component value into the partition,
+// component, and entity it belongs to.
+//
+// SplitComponentKey returns false for any other key, including the keys of
+// index entries.
+func SplitComponentKey(key []byte) (p Entity, c Component, e Entity, ok bool) {
+	if len(key) != 8+2+8 {
+		return 0, 0, 0, false
+	}
+	p.Decode(key[:8])
+	c.Decode(key[8:10])
+	e.Decode(key[10:])
+	return p, c, e, e != 0
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"testing"
+	"time"
+)
+
+// wait waits for c to be closed, and fails the test if it takes too long.
+func wait(t *testing.T, c <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestFeed(t *testing.T) {
+	stopped := make(chan struct{})
+	f := NewFeed(func() { close(stopped) })
+	for i := byte(0); i < 3; i++ {
+		f.Publish([]Change{{Key: []byte{i}}})
+	}
+	f.Publish(nil)
+	for i := byte(0); i < 3; i++ {
+		if changes := <-f.Changes(); len(changes) != 1 || changes[0].Key[0] != i {
+			t.Fatalf("want changes to key %d, got %v", i, changes)
+		}
+	}
+	f.Close()
+	wait(t, stopped)
+	if changes, ok := <-f.Changes(); ok {
+		t.Errorf("want no changes after Close, got %v", changes)
+	}
+	if err := f.Err(); err != nil {
+		t.Errorf("want no error after Close, got %v", err)
+	}
+}
+
+func TestFeedBehind(t *testing.T) {
+	stopped := make(chan struct{})
+	f := NewFeed(func() { close(stopped) })
+	// One more commit than the limit may already be waiting to be sent.
+	for i := 0; i < MaxPendingCommits+2; i++ {
+		f.Publish([]Change{{Key: []byte{byte(i)}}})
+	}
+	wait(t, stopped)
+	for range f.Changes() {
+	}
+	if err := f.Err(); err != ErrSubscriberBehind {
+		t.Errorf("want %v, got %v", ErrSubscriberBehind, err)
+	}
+}
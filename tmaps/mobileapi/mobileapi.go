@@ -29,6 +29,7 @@ import (
 	"github.com/google/note-maps/kv/badger"
 	"github.com/google/note-maps/tmaps/pb"
 	"github.com/google/note-maps/tmaps/pbapi"
+	"github.com/google/note-maps/tmaps/tmdb/models"
 )
 
 func SetPath(p string) {
@@ -130,6 +131,14 @@ func gateway() (*pbapi.Gateway, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		// Upgrade data written by earlier versions before serving any
+		// requests.
+		if err = models.Migrations.Migrate(db); err != nil {
+			db.Close()
+			db = nil
+			return nil, err
+		}
 	}
 
 	return pbapi.NewGateway(db), nil
@@ -114,7 +114,17 @@ func (g Gateway) Query(q *pb.QueryRequest) (*pb.QueryResponse, error) {
 		var found pb.SearchResponse
 		for _, topicMapId := range search.TopicMapIds {
 			ms.Partition = kv.Entity(topicMapId)
-			es, err := ms.AllTopicNamesEntities(nil, 0)
+			var (
+				es  []kv.Entity
+				err error
+			)
+			if search.Tmql == "" {
+				es, err = ms.AllTopicNamesEntities(nil, 0)
+			} else {
+				// Until TMQL is supported, the query is searched for
+				// as words in the names and occurrences of topics.
+				es, err = ms.SearchTopics(search.Tmql, 0)
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -138,8 +148,21 @@ func (g Gateway) Mutate(m *pb.MutationRequest) (*pb.MutationResponse, error) {
 	if err := isWellFormedMutationRequest(m); err != nil {
 		return nil, err
 	}
-	txn := g.db.NewTxn(true)
-	defer txn.Discard()
+	var response *pb.MutationResponse
+	err := kv.Update(g.db, func(txn kv.Txn) error {
+		var err error
+		response, err = mutate(txn, m)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// mutate applies m in txn, which may be called more than once for the same
+// request if the transaction conflicts with another.
+func mutate(txn kv.Txn, m *pb.MutationRequest) (*pb.MutationResponse, error) {
 	ms := models.New(txn)
 	var response pb.MutationResponse
 	for _, deletion := range m.DeletionRequests {
@@ -157,15 +180,24 @@ func (g Gateway) Mutate(m *pb.MutationRequest) (*pb.MutationResponse, error) {
 				return nil, err
 			}
 			log.Println("deleted topic map", deletion.Id)
-		case pb.ItemType_TopicItem:
-			// TODO: actually delete
-			return nil, fmt.Errorf("not yet implemented: cannot delete topic")
-		case pb.ItemType_NameItem:
-			// TODO: actually delete
-			return nil, fmt.Errorf("not yet implemented: cannot delete name")
-		case pb.ItemType_OccurrenceItem:
-			// TODO: actually delete
-			return nil, fmt.Errorf("not yet implemented: cannot delete occurrence")
+		case pb.ItemType_TopicItem, pb.ItemType_NameItem, pb.ItemType_OccurrenceItem:
+			if deletion.TopicMapId == 0 || deletion.Id == 0 {
+				return nil, fmt.Errorf("too many zeros in request")
+			}
+			var err error
+			switch e := kv.Entity(deletion.Id); deletion.ItemType {
+			case pb.ItemType_TopicItem:
+				// Names and occurrences are deleted along with
+				// their topics.
+				err = ms.RemoveTopic(e)
+			case pb.ItemType_NameItem:
+				err = ms.RemoveName(e)
+			case pb.ItemType_OccurrenceItem:
+				err = ms.RemoveOccurrence(e)
+			}
+			if err != nil {
+				return nil, err
+			}
 		default:
 			return nil, fmt.Errorf("unsupported deletion item type: %v", deletion.ItemType)
 		}
@@ -206,6 +238,8 @@ func (g Gateway) Mutate(m *pb.MutationRequest) (*pb.MutationResponse, error) {
 				TopicMapId: created.TopicMapId,
 				Id:         created.Id,
 			}
+			// Empty lists are stored so that the topic is listed
+			// even before it has any names or occurrences.
 			if err := ms.SetTopicNames(e, nil); err != nil {
 				return nil, err
 			} else if err := ms.SetTopicOccurrences(e, nil); err != nil {
@@ -221,18 +255,9 @@ func (g Gateway) Mutate(m *pb.MutationRequest) (*pb.MutationResponse, error) {
 				Id:         created.Id,
 				ParentId:   uint64(creation.Parent),
 			}
-			pe := kv.Entity(creation.Parent)
-			if pns, err := ms.GetTopicNames(pe); err != nil {
-				return nil, err
-			} else {
-				pns = append(pns, e)
-				if err = ms.SetTopicNames(pe, pns); err != nil {
-					return nil, err
-				}
-			}
 			info := &models.Name{}
-			info.Topic = creation.Parent
-			if err := ms.SetName(e, info); err != nil {
+			info.SetRefTopic(kv.Entity(creation.Parent))
+			if err := ms.AddName(e, info); err != nil {
 				return nil, err
 			}
 			created.Item = &pb.Item{Specific: &pb.Item_Name{&n}}
@@ -240,20 +265,14 @@ func (g Gateway) Mutate(m *pb.MutationRequest) (*pb.MutationResponse, error) {
 			if creation.TopicMapId == 0 || creation.Parent == 0 {
 				return nil, fmt.Errorf("too many zeros in request")
 			}
-			pe := kv.Entity(creation.Parent)
 			o := pb.Occurrence{
 				TopicMapId: created.TopicMapId,
 				Id:         created.Id,
 				ParentId:   uint64(creation.Parent),
 			}
-			if pos, err := ms.GetTopicOccurrences(pe); err != nil {
-				return nil, err
-			} else if err = ms.SetTopicOccurrences(pe, append(pos, e)); err != nil {
-				return nil, err
-			}
 			info := &models.Occurrence{}
-			info.Topic = creation.Parent
-			if err := ms.SetOccurrence(e, info); err != nil {
+			info.SetRefTopic(kv.Entity(creation.Parent))
+			if err := ms.AddOccurrence(e, info); err != nil {
 				return nil, err
 			}
 			created.Item = &pb.Item{Specific: &pb.Item_Occurrence{&o}}
@@ -325,7 +344,7 @@ func (g Gateway) Mutate(m *pb.MutationRequest) (*pb.MutationResponse, error) {
 	if err := isWellFormedMutationResponse(&response); err != nil {
 		return nil, err
 	}
-	return &response, txn.Commit()
+	return &response, nil
 }
 
 func deleteTopicMap(ms models.Txn, topicMapId uint64) error {
@@ -15,9 +15,14 @@
 package pbapi
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"reflect"
 	"testing"
 
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/cache"
 	"github.com/google/note-maps/kv/kvtest"
 	"github.com/google/note-maps/tmaps/pb"
 )
@@ -110,3 +115,250 @@ func TestName(t *testing.T) {
 	}
 	t.Log(queryResults)
 }
+
+func TestSearch(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	testSearch(t, db)
+}
+
+// TestSearchEncrypted checks that searching works just the same when keys
+// and values are encrypted, and that nothing searched for is stored in the
+// clear.
+func TestSearchEncrypted(t *testing.T) {
+	raw := kvtest.NewBackendDB(t, kvtest.Backend())
+	db := kvtest.NewCryptDB(t, raw, true)
+	defer db.Close()
+	testSearch(t, db)
+	txn := raw.NewTxn(false)
+	defer txn.Discard()
+	iter := txn.PrefixIterator(nil)
+	defer iter.Discard()
+	for iter.Seek(nil); iter.Valid(); iter.Next() {
+		if err := iter.Value(func(bs []byte) error {
+			for _, bs := range [][]byte{iter.Key(), bs} {
+				if bytes.Contains(bytes.ToLower(bs), []byte("whale")) {
+					t.Errorf("stored in the clear: %q", bs)
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testSearch(t *testing.T, db kv.DB) {
+	g := NewGateway(db)
+	response, err := g.Mutate(&pb.MutationRequest{
+		CreationRequests: []*pb.CreationRequest{
+			{ItemType: pb.ItemType_TopicMapItem},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := response.CreationResponses[0].Item.Specific.(*pb.Item_TopicMap).TopicMap.Id
+	values := []string{"Blue Whale", "Blue jay", "Humpback Whale"}
+	var creations []*pb.CreationRequest
+	for range values {
+		creations = append(creations, &pb.CreationRequest{TopicMapId: tm, ItemType: pb.ItemType_TopicItem})
+	}
+	if response, err = g.Mutate(&pb.MutationRequest{CreationRequests: creations}); err != nil {
+		t.Fatal(err)
+	}
+	topics := make([]uint64, len(values))
+	creations = nil
+	for i, c := range response.CreationResponses {
+		topics[i] = c.Id
+		creations = append(creations, &pb.CreationRequest{TopicMapId: tm, Parent: c.Id, ItemType: pb.ItemType_NameItem})
+	}
+	if response, err = g.Mutate(&pb.MutationRequest{CreationRequests: creations}); err != nil {
+		t.Fatal(err)
+	}
+	var updates []*pb.UpdateValueRequest
+	for i, c := range response.CreationResponses {
+		updates = append(updates, &pb.UpdateValueRequest{TopicMapId: tm, Id: c.Id, ItemType: pb.ItemType_NameItem, Value: values[i]})
+	}
+	if _, err = g.Mutate(&pb.MutationRequest{UpdateValueRequests: updates}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		Query string
+		Want  []uint64
+	}{
+		{"whale", []uint64{topics[0], topics[2]}},
+		{"blue whale", []uint64{topics[0], topics[1], topics[2]}},
+		{"JAY", []uint64{topics[1]}},
+		{"hump", []uint64{topics[2]}},
+		{"orca", nil},
+	} {
+		found, err := g.Query(&pb.QueryRequest{
+			SearchRequests: []*pb.SearchRequest{{TopicMapIds: []uint64{tm}, Tmql: test.Query}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint64
+		for _, item := range found.SearchResponses[0].Items {
+			got = append(got, item.Specific.(*pb.Item_Topic).Topic.Id)
+		}
+		if !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("%q: got %v, want %v", test.Query, got, test.Want)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	g := NewGateway(db)
+	create := func(requests ...*pb.CreationRequest) []*pb.UpdateResponse {
+		response, err := g.Mutate(&pb.MutationRequest{CreationRequests: requests})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.CreationResponses
+	}
+	load := func(tm, id uint64, itemType pb.ItemType) *pb.Item {
+		response, err := g.Query(&pb.QueryRequest{
+			LoadRequests: []*pb.LoadRequest{{TopicMapId: tm, Id: id, ItemType: itemType}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.LoadResponses[0].Item
+	}
+	tm := create(&pb.CreationRequest{ItemType: pb.ItemType_TopicMapItem})[0].Id
+	topic := create(&pb.CreationRequest{TopicMapId: tm, ItemType: pb.ItemType_TopicItem})[0].Id
+	children := create(
+		&pb.CreationRequest{TopicMapId: tm, Parent: topic, ItemType: pb.ItemType_NameItem},
+		&pb.CreationRequest{TopicMapId: tm, Parent: topic, ItemType: pb.ItemType_OccurrenceItem},
+	)
+	name, occurrence := children[0].Id, children[1].Id
+	for _, parent := range []uint64{name, topic + 1000} {
+		for _, itemType := range []pb.ItemType{pb.ItemType_NameItem, pb.ItemType_OccurrenceItem} {
+			if _, err := g.Mutate(&pb.MutationRequest{CreationRequests: []*pb.CreationRequest{
+				{TopicMapId: tm, Parent: parent, ItemType: itemType},
+			}}); err == nil {
+				t.Errorf("created a %v with parent %v, which is not a topic", itemType, parent)
+			}
+		}
+	}
+	got := load(tm, topic, pb.ItemType_TopicItem).Specific.(*pb.Item_Topic).Topic
+	if !reflect.DeepEqual(got.NameIds, []uint64{name}) ||
+		!reflect.DeepEqual(got.OccurrenceIds, []uint64{occurrence}) {
+		t.Fatalf("got names %v and occurrences %v, want [%v] and [%v]",
+			got.NameIds, got.OccurrenceIds, name, occurrence)
+	}
+	for _, wrong := range []*pb.DeletionRequest{
+		{TopicMapId: tm, Id: topic, ItemType: pb.ItemType_NameItem},
+		{TopicMapId: tm, Id: topic, ItemType: pb.ItemType_OccurrenceItem},
+		{TopicMapId: tm, Id: name, ItemType: pb.ItemType_TopicItem},
+		{TopicMapId: tm, Id: name, ItemType: pb.ItemType_OccurrenceItem},
+	} {
+		if _, err := g.Mutate(&pb.MutationRequest{DeletionRequests: []*pb.DeletionRequest{wrong}}); err == nil {
+			t.Errorf("deleted %v %v", wrong.ItemType, wrong.Id)
+		}
+	}
+	got = load(tm, topic, pb.ItemType_TopicItem).Specific.(*pb.Item_Topic).Topic
+	if !reflect.DeepEqual(got.NameIds, []uint64{name}) {
+		t.Fatalf("got names %v after failed deletions, want [%v]", got.NameIds, name)
+	}
+	if _, err := g.Mutate(&pb.MutationRequest{DeletionRequests: []*pb.DeletionRequest{
+		{TopicMapId: tm, Id: name, ItemType: pb.ItemType_NameItem},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	got = load(tm, topic, pb.ItemType_TopicItem).Specific.(*pb.Item_Topic).Topic
+	if len(got.NameIds) != 0 || !reflect.DeepEqual(got.OccurrenceIds, []uint64{occurrence}) {
+		t.Errorf("got names %v and occurrences %v, want [] and [%v]",
+			got.NameIds, got.OccurrenceIds, occurrence)
+	}
+	if _, err := g.Mutate(&pb.MutationRequest{DeletionRequests: []*pb.DeletionRequest{
+		{TopicMapId: tm, Id: topic, ItemType: pb.ItemType_TopicItem},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Query(&pb.QueryRequest{
+		LoadRequests: []*pb.LoadRequest{{TopicMapId: tm, Id: occurrence, ItemType: pb.ItemType_OccurrenceItem}},
+	}); err == nil {
+		t.Error("want the occurrence to be deleted with its topic")
+	}
+}
+
+// BenchmarkQuery compares queries of a map of 10,000 topics, each with a name
+// and an occurrence, with and without a cache of decoded values. Only
+// component values are cached: the index scans that find the names and
+// occurrences of each topic read the underlying DB every time.
+func BenchmarkQuery(b *testing.B) {
+	db := kvtest.NewDB(b)
+	defer db.Close()
+	tm := createTopics(b, NewGateway(db), 10000)
+	for _, bench := range []struct {
+		Name string
+		DB   kv.DB
+	}{
+		{"uncached", db},
+		{"cached", cache.New(db, cache.Options{})},
+	} {
+		g := NewGateway(bench.DB)
+		for _, query := range []string{"", "77"} {
+			b.Run(fmt.Sprintf("%s/Tmql=%q", bench.Name, query), func(b *testing.B) {
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := g.Query(&pb.QueryRequest{
+						SearchRequests: []*pb.SearchRequest{{TopicMapIds: []uint64{tm}, Tmql: query}},
+					}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// createTopics creates a topic map with n topics, each with a name and an
+// occurrence, and returns the ID of the topic map.
+func createTopics(b *testing.B, g *Gateway, n int) uint64 {
+	mutate := func(m *pb.MutationRequest) *pb.MutationResponse {
+		response, err := g.Mutate(m)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return response
+	}
+	tm := mutate(&pb.MutationRequest{
+		CreationRequests: []*pb.CreationRequest{{ItemType: pb.ItemType_TopicMapItem}},
+	}).CreationResponses[0].Id
+	const chunk = 1000
+	for first := 0; first < n; first += chunk {
+		count := chunk
+		if n-first < count {
+			count = n - first
+		}
+		var creations []*pb.CreationRequest
+		for i := 0; i < count; i++ {
+			creations = append(creations, &pb.CreationRequest{TopicMapId: tm, ItemType: pb.ItemType_TopicItem})
+		}
+		topics := mutate(&pb.MutationRequest{CreationRequests: creations}).CreationResponses
+		creations = nil
+		for _, topic := range topics {
+			creations = append(creations,
+				&pb.CreationRequest{TopicMapId: tm, Parent: topic.Id, ItemType: pb.ItemType_NameItem},
+				&pb.CreationRequest{TopicMapId: tm, Parent: topic.Id, ItemType: pb.ItemType_OccurrenceItem})
+		}
+		var updates []*pb.UpdateValueRequest
+		for i, c := range mutate(&pb.MutationRequest{CreationRequests: creations}).CreationResponses {
+			updates = append(updates, &pb.UpdateValueRequest{
+				TopicMapId: tm,
+				Id:         c.Id,
+				ItemType:   creations[i].ItemType,
+				Value:      fmt.Sprintf("Topic %d", first+i/2),
+			})
+		}
+		mutate(&pb.MutationRequest{UpdateValueRequests: updates})
+	}
+	return tm
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command tmdbindex checks the indexes in a Badger database of topic maps,
+// and optionally rebuilds those that are inconsistent.
+//
+// For each topic map, tmdbindex reports dangling index entries, which list an
+// entity whose component value does not yield the indexed value, and missing
+// index entries, which fail to list an entity whose component value does
+// yield the indexed value. It exits with status 1 if it finds any
+// inconsistency that it has not repaired.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/badger"
+	"github.com/google/note-maps/tmaps/tmdb/models"
+)
+
+var (
+	rebuild = flag.Bool("rebuild", false, "rebuild inconsistent indexes")
+)
+
+// components lists the methods generated for each component of the models
+// schema that has indexes.
+var components = []struct {
+	name    string
+	verify  func(models.Txn) ([]*kv.IndexError, error)
+	rebuild func(models.Txn) error
+}{
+	{"TopicMapInfo", models.Txn.VerifyTopicMapInfoIndexes, models.Txn.RebuildTopicMapInfoIndexes},
+	{"IIs", models.Txn.VerifyIIsIndexes, models.Txn.RebuildIIsIndexes},
+	{"SIs", models.Txn.VerifySIsIndexes, models.Txn.RebuildSIsIndexes},
+	{"SLs", models.Txn.VerifySLsIndexes, models.Txn.RebuildSLsIndexes},
+	{"Name", models.Txn.VerifyNameIndexes, models.Txn.RebuildNameIndexes},
+	{"Occurrence", models.Txn.VerifyOccurrenceIndexes, models.Txn.RebuildOccurrenceIndexes},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of tmdbindex:\n")
+	fmt.Fprintf(os.Stderr, "\ttmdbindex [flags] directory\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	db, err := badger.Open(badger.DefaultOptions(flag.Arg(0)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	partitions, err := topicMaps(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	inconsistent := false
+	for _, p := range partitions {
+		for _, c := range components {
+			ok, err := check(db, p, c.name, c.verify)
+			if err != nil {
+				log.Fatal(err)
+			} else if ok {
+				continue
+			} else if !*rebuild {
+				inconsistent = true
+				continue
+			}
+			if err = kv.Update(db, func(txn kv.Txn) error {
+				ms := models.New(txn)
+				ms.Partition = p
+				return c.rebuild(ms)
+			}); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("topic map %d: rebuilt %s indexes\n", p, c.name)
+		}
+	}
+	if inconsistent {
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+// topicMaps returns the partitions of all topic maps in db, along with
+// partition zero, where topic maps themselves are recorded.
+func topicMaps(db kv.DB) ([]kv.Entity, error) {
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	es, err := models.New(txn).AllTopicMapInfoEntities(nil, 0)
+	return append([]kv.Entity{0}, es...), err
+}
+
+// check reports the inconsistencies found by verify in partition p of db,
+// and returns true if there are none.
+func check(db kv.DB, p kv.Entity, name string, verify func(models.Txn) ([]*kv.IndexError, error)) (bool, error) {
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	ms := models.New(txn)
+	ms.Partition = p
+	ies, err := verify(ms)
+	for _, ie := range ies {
+		kind := "dangling"
+		if ie.Missing {
+			kind = "missing"
+		}
+		fmt.Printf("topic map %d: %s %s index entry: %v\n", p, kind, name, ie)
+	}
+	return len(ies) == 0, err
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/kvtest"
+	"github.com/google/note-maps/tmaps/tmdb/models"
+)
+
+func TestCheck(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	const p = kv.Entity(7)
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		ms := models.New(txn)
+		ms.Partition = p
+		if err := ms.SetIIs(1, models.IIs{"a", "b"}); err != nil {
+			return err
+		}
+		// Remove entity 1 from the index entry for "b".
+		key := kv.ConcatByteSlices(p.Encode(), models.IIsPrefix.Encode(),
+			kv.Entity(0).Encode(), models.LiteralPrefix.Encode(), []byte("b"))
+		return txn.Set(key, kv.EntitySlice{}.Encode())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range components {
+		ok, err := check(db, p, c.name, c.verify)
+		if err != nil {
+			t.Fatal(err)
+		} else if ok != (c.name != "IIs") {
+			t.Errorf("%s: got ok=%v", c.name, ok)
+		}
+	}
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		ms := models.New(txn)
+		ms.Partition = p
+		return ms.RebuildIIsLiteralIndex()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := check(db, p, "IIs", models.Txn.VerifyIIsIndexes); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("IIs indexes are still inconsistent after rebuilding")
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	const p = kv.Entity(7)
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		ms := models.New(txn)
+		ms.Partition = p
+		var name models.Name
+		name.Value = "Two words"
+		name.Topic = 1
+		if err := ms.SetName(2, &name); err != nil {
+			return err
+		}
+		// Delete every index entry of every Name.
+		prefix := kv.ConcatByteSlices(p.Encode(), models.NamePrefix.Encode(), kv.Entity(0).Encode())
+		iter := txn.PrefixIterator(prefix)
+		defer iter.Discard()
+		var keys [][]byte
+		for iter.Seek(nil); iter.Valid(); iter.Next() {
+			keys = append(keys, kv.ConcatByteSlices(prefix, iter.Key()))
+		}
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range components {
+		if c.name != "Name" {
+			continue
+		}
+		if ok, err := check(db, p, c.name, c.verify); err != nil {
+			t.Fatal(err)
+		} else if ok {
+			t.Fatal("Name indexes are consistent after deleting them")
+		}
+		if err := kv.Update(db, func(txn kv.Txn) error {
+			ms := models.New(txn)
+			ms.Partition = p
+			return c.rebuild(ms)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := check(db, p, c.name, c.verify); err != nil {
+			t.Fatal(err)
+		} else if !ok {
+			t.Error("Name indexes are still inconsistent after rebuilding")
+		}
+	}
+}
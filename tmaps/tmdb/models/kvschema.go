@@ -19,11 +19,15 @@ func (s Txn) SetIIs(e kv.Entity, v IIs) error {
 	s.Partition.EncodeAt(key)
 	IIsPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
+	bs, err := kv.EncodeFormatted(v.ValueFormat(), v)
+	if err != nil {
+		return err
+	}
 	var old IIs
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
-	if err := s.Set(key, v.Encode()); err != nil {
+	if err := s.Set(key, bs); err != nil {
 		return err
 	}
 	// A prefix buffer for all index keys.
@@ -67,7 +71,7 @@ func (s Txn) DeleteIIs(e kv.Entity) error {
 	IIsPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
 	var old IIs
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
 	if err := s.Delete(key); err != nil {
@@ -117,14 +121,22 @@ func (s Txn) GetIIsSlice(es []kv.Entity) ([]IIs, error) {
 	IIsPrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeIIs)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(IIs)
 	}
 	return result, nil
 }
 
+// decodeIIs decodes a IIs, for use with kv.GetDecoded.
+func decodeIIs(bs []byte) (interface{}, error) {
+	var v IIs
+	err := kv.DecodeFormatted(bs, &v)
+	return v, err
+}
+
 // AllIIsEntities returns the first n entities that have a IIs, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -136,6 +148,27 @@ func (s Txn) AllIIsEntities(start *kv.Entity, n int) (es []kv.Entity, err error)
 	return s.AllComponentEntities(IIsPrefix, start, n)
 }
 
+// AllIIsEntitiesReverse returns the first n entities that have a
+// IIs in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllIIsEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllIIsEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(IIsPrefix, start, n)
+}
+
+// HasIIs returns a query matching every entity that has a IIs,
+// for use with QueryEntities.
+func (s Txn) HasIIs() kv.Query {
+	return kv.HasComponent(IIsPrefix)
+}
+
 // EntitiesMatchingIIsLiteral returns entities with IIs values that return a matching kv.String from their IndexLiteral method.
 //
 // The returned EntitySlice is already sorted.
@@ -150,6 +183,13 @@ func (s Txn) EntitiesMatchingIIsLiteral(v kv.String) (kv.EntitySlice, error) {
 	return es, s.Get(key, es.Decode)
 }
 
+// MatchingIIsLiteral returns a query matching the entities
+// that EntitiesMatchingIIsLiteral would return, for use with
+// QueryEntities.
+func (s Txn) MatchingIIsLiteral(v kv.String) kv.Query {
+	return kv.MatchingComponentIndex(IIsPrefix, LiteralPrefix, v.Encode())
+}
+
 // EntitiesByIIsLiteral returns entities with
 // IIs values ordered by the kv.String values from their
 // IndexLiteral method.
@@ -162,48 +202,154 @@ func (s Txn) EntitiesByIIsLiteral(cursor *kv.IndexCursor, n int) (es []kv.Entity
 	return s.EntitiesByComponentIndex(IIsPrefix, LiteralPrefix, cursor, n)
 }
 
+// EntitiesByIIsLiteralRange returns entities with
+// IIs values ordered by the kv.String values from their
+// IndexLiteral method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesByIIsLiteralRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesByIIsLiteralRange(lower, upper *kv.String, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByComponentIndexRange(IIsPrefix, LiteralPrefix, lo, hi, cursor, n)
+}
+
+// EntitiesByIIsLiteralReverse returns entities with
+// IIs values in reverse order by the kv.String values from
+// their IndexLiteral method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesByIIsLiteralReverse would return next n entities.
+func (s Txn) EntitiesByIIsLiteralReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByComponentIndexReverse(IIsPrefix, LiteralPrefix, cursor, n)
+}
+
+// VerifyIIsIndexes checks that every index of IIs values is
+// consistent with the IIs values themselves, and returns every
+// inconsistency it finds.
+func (s Txn) VerifyIIsIndexes() ([]*kv.IndexError, error) {
+	var ies []*kv.IndexError
+	report := func(ie *kv.IndexError) error {
+		ies = append(ies, ie)
+		return nil
+	}
+	if err := s.CheckComponentIndex(IIsPrefix, LiteralPrefix, indexIIsLiteral, report); err != nil {
+		return ies, err
+	}
+	return ies, nil
+}
+
+// RebuildIIsIndexes rebuilds every index of IIs values, so that
+// VerifyIIsIndexes finds no inconsistencies.
+func (s Txn) RebuildIIsIndexes() error {
+	if err := s.RebuildIIsLiteralIndex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebuildIIsLiteralIndex rebuilds the index of
+// IIs values by the kv.String values from their
+// IndexLiteral method.
+func (s Txn) RebuildIIsLiteralIndex() error {
+	return s.RebuildComponentIndex(IIsPrefix, LiteralPrefix, indexIIsLiteral)
+}
+
+// indexIIsLiteral decodes a IIs and returns
+// the encoded kv.String values from its IndexLiteral method.
+func indexIIsLiteral(bs []byte) ([][]byte, error) {
+	var v IIs
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := v.IndexLiteral()
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
 // SetName sets the Name associated with e to v.
 //
 // Corresponding indexes are updated.
+//
+// If v refers to a different entity than before through its RefCascadeTopic
+// method, e is removed from the TopicNames of the entity it referred to and
+// added to the end of the TopicNames of the entity it refers to now.
 func (s Txn) SetName(e kv.Entity, v *Name) error {
 	key := make(kv.Prefix, 8+2+8)
 	s.Partition.EncodeAt(key)
 	NamePrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
+	bs, err := kv.EncodeFormatted(v.ValueFormat(), v)
+	if err != nil {
+		return err
+	}
 	var old Name
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
-	if err := s.Set(key, v.Encode()); err != nil {
+	if err := s.Set(key, bs); err != nil {
 		return err
 	}
-	// A prefix buffer for all index keys.
-	prefix := kv.ConcatByteSlices(key, kv.Component(0).Encode())
-	kv.Entity(0).EncodeAt(prefix[10:])
-	var es kv.EntitySlice
 
 	// Update Value index
-	ValuePrefix.EncodeAt(prefix[18:])
-	for _, iv := range old.IndexValue() {
-		k := kv.ConcatByteSlices(prefix, iv.Encode())
-		if err := s.Get(k, es.Decode); err != nil {
+	for _, iv := range old.IndexScalableValue() {
+		if err := s.RemoveScalableIndexEntry(NamePrefix, ValuePrefix, iv.Encode(), e); err != nil {
 			return err
 		}
-		if es.Remove(e) {
-			if err := s.Set(k, es.Encode()); err != nil {
-				return err
-			}
+	}
+	for _, iv := range v.IndexScalableValue() {
+		if err := s.InsertScalableIndexEntry(NamePrefix, ValuePrefix, iv.Encode(), e); err != nil {
+			return err
 		}
 	}
-	for _, iv := range v.IndexValue() {
-		k := kv.ConcatByteSlices(prefix, iv.Encode())
-		if err := s.Get(k, es.Decode); err != nil {
+
+	// Update Words index
+	for _, iv := range kv.TextTerms(old.IndexTextWords()) {
+		if err := s.RemoveScalableIndexEntry(NamePrefix, WordsPrefix, iv.Encode(), e); err != nil {
 			return err
 		}
-		if es.Insert(e) {
-			if err := s.Set(k, es.Encode()); err != nil {
-				return err
-			}
+	}
+	for _, iv := range kv.TextTerms(v.IndexTextWords()) {
+		if err := s.InsertScalableIndexEntry(NamePrefix, WordsPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+
+	// Update Topic index
+	for _, iv := range kv.ReferencedEntities(old.RefCascadeTopic()) {
+		if err := s.RemoveScalableIndexEntry(NamePrefix, TopicPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+	for _, iv := range kv.ReferencedEntities(v.RefCascadeTopic()) {
+		if err := s.InsertScalableIndexEntry(NamePrefix, TopicPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+
+	// Update TopicNames lists
+	if ref, oldRef := v.RefCascadeTopic(), old.RefCascadeTopic(); ref != oldRef {
+		if err := s.removeTopicNamesEntry(oldRef, e); err != nil {
+			return err
+		}
+		if err := s.addTopicNamesEntry(ref, e); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -212,35 +358,47 @@ func (s Txn) SetName(e kv.Entity, v *Name) error {
 // DeleteName deletes the Name associated with e.
 //
 // Corresponding indexes are updated.
+//
+// e is removed from the TopicNames of the entity that the deleted value
+// referred to through its RefCascadeTopic method.
 func (s Txn) DeleteName(e kv.Entity) error {
 	key := make(kv.Prefix, 8+2+8)
 	s.Partition.EncodeAt(key)
 	NamePrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
 	var old Name
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
 	if err := s.Delete(key); err != nil {
 		return err
 	}
-	prefix := kv.ConcatByteSlices(key, kv.Component(0).Encode())
-	kv.Entity(0).EncodeAt(prefix[10:])
-	var es kv.EntitySlice
 
 	// Update Value index
-	ValuePrefix.EncodeAt(prefix[18:])
-	for _, iv := range old.IndexValue() {
-		k := kv.ConcatByteSlices(prefix, iv.Encode())
-		if err := s.Get(k, es.Decode); err != nil {
+	for _, iv := range old.IndexScalableValue() {
+		if err := s.RemoveScalableIndexEntry(NamePrefix, ValuePrefix, iv.Encode(), e); err != nil {
 			return err
 		}
-		if es.Remove(e) {
-			if err := s.Set(k, es.Encode()); err != nil {
-				return err
-			}
+	}
+
+	// Update Words index
+	for _, iv := range kv.TextTerms(old.IndexTextWords()) {
+		if err := s.RemoveScalableIndexEntry(NamePrefix, WordsPrefix, iv.Encode(), e); err != nil {
+			return err
 		}
 	}
+
+	// Update Topic index
+	for _, iv := range kv.ReferencedEntities(old.RefCascadeTopic()) {
+		if err := s.RemoveScalableIndexEntry(NamePrefix, TopicPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+
+	// Update TopicNames lists
+	if err := s.removeTopicNamesEntry(old.RefCascadeTopic(), e); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -268,14 +426,22 @@ func (s Txn) GetNameSlice(es []kv.Entity) ([]Name, error) {
 	NamePrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeName)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(Name)
 	}
 	return result, nil
 }
 
+// decodeName decodes a Name, for use with kv.GetDecoded.
+func decodeName(bs []byte) (interface{}, error) {
+	var v Name
+	err := kv.DecodeFormatted(bs, &v)
+	return v, err
+}
+
 // AllNameEntities returns the first n entities that have a Name, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -287,74 +453,381 @@ func (s Txn) AllNameEntities(start *kv.Entity, n int) (es []kv.Entity, err error
 	return s.AllComponentEntities(NamePrefix, start, n)
 }
 
-// EntitiesMatchingNameValue returns entities with Name values that return a matching kv.String from their IndexValue method.
+// AllNameEntitiesReverse returns the first n entities that have a
+// Name in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllNameEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllNameEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(NamePrefix, start, n)
+}
+
+// HasName returns a query matching every entity that has a Name,
+// for use with QueryEntities.
+func (s Txn) HasName() kv.Query {
+	return kv.HasComponent(NamePrefix)
+}
+
+// EntitiesMatchingNameValue returns entities with Name values that return a matching kv.String from their IndexScalableValue method.
 //
 // The returned EntitySlice is already sorted.
 func (s Txn) EntitiesMatchingNameValue(v kv.String) (kv.EntitySlice, error) {
-	key := make(kv.Prefix, 8+2+8+2)
-	s.Partition.EncodeAt(key)
-	NamePrefix.EncodeAt(key[8:])
-	kv.Entity(0).EncodeAt(key[10:])
-	ValuePrefix.EncodeAt(key[18:])
-	key = append(key, v.Encode()...)
-	var es kv.EntitySlice
-	return es, s.Get(key, es.Decode)
+	return s.EntitiesMatchingScalableIndex(NamePrefix, ValuePrefix, v.Encode())
+}
+
+// MatchingNameValue returns a query matching the entities
+// that EntitiesMatchingNameValue would return, for use with
+// QueryEntities.
+func (s Txn) MatchingNameValue(v kv.String) kv.Query {
+	return kv.MatchingScalableIndex(NamePrefix, ValuePrefix, v.Encode())
 }
 
 // EntitiesByNameValue returns entities with
 // Name values ordered by the kv.String values from their
-// IndexValue method.
+// IndexScalableValue method.
 //
 // Reading begins at cursor, and ends when the length of the returned Entity
 // slice is less than n. When reading is not complete, cursor is updated such
 // that using it in a subequent call to ByValue would return next n
 // entities.
 func (s Txn) EntitiesByNameValue(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
-	return s.EntitiesByComponentIndex(NamePrefix, ValuePrefix, cursor, n)
+	return s.EntitiesByScalableIndex(NamePrefix, ValuePrefix, cursor, n)
+}
+
+// EntitiesByNameValueRange returns entities with
+// Name values ordered by the kv.String values from their
+// IndexScalableValue method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesByNameValueRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesByNameValueRange(lower, upper *kv.String, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByScalableIndexRange(NamePrefix, ValuePrefix, lo, hi, cursor, n)
+}
+
+// EntitiesByNameValueReverse returns entities with
+// Name values in reverse order by the kv.String values from
+// their IndexScalableValue method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesByNameValueReverse would return next n entities.
+func (s Txn) EntitiesByNameValueReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByScalableIndexReverse(NamePrefix, ValuePrefix, cursor, n)
+}
+
+// SearchNameWords returns up to n entities with
+// Name values whose IndexTextWords method returns words that
+// match the words in query, best matches first.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) SearchNameWords(query string, n int) ([]kv.TextMatch, error) {
+	return s.SearchTextIndex(NamePrefix, WordsPrefix, query, n)
+}
+
+// EntitiesMatchingNameTopic returns entities with Name values that return a matching kv.Entity from their RefCascadeTopic method.
+//
+// The returned EntitySlice is already sorted.
+func (s Txn) EntitiesMatchingNameTopic(v kv.Entity) (kv.EntitySlice, error) {
+	return s.EntitiesMatchingScalableIndex(NamePrefix, TopicPrefix, v.Encode())
+}
+
+// MatchingNameTopic returns a query matching the entities
+// that EntitiesMatchingNameTopic would return, for use with
+// QueryEntities.
+func (s Txn) MatchingNameTopic(v kv.Entity) kv.Query {
+	return kv.MatchingScalableIndex(NamePrefix, TopicPrefix, v.Encode())
+}
+
+// SetNameTopic sets the entity that the Name
+// associated with e refers to through its RefCascadeTopic method.
+//
+// Corresponding indexes are updated.
+func (s Txn) SetNameTopic(e, ref kv.Entity) error {
+	v, err := s.GetName(e)
+	if err != nil {
+		return err
+	}
+	v.SetRefTopic(ref)
+	return s.SetName(e, &v)
+}
+
+// addTopicNamesEntry adds e to the end of the TopicNames of ref, unless ref is
+// zero or e is already listed there.
+func (s Txn) addTopicNamesEntry(ref, e kv.Entity) error {
+	if ref == 0 {
+		return nil
+	}
+	es, err := s.GetTopicNames(ref)
+	if err != nil {
+		return err
+	}
+	for _, listed := range es {
+		if listed == e {
+			return nil
+		}
+	}
+	// The list may be shared with other readers, so it is copied rather
+	// than modified.
+	es = append(es[:len(es):len(es)], e)
+	return s.SetTopicNames(ref, es)
+}
+
+// removeTopicNamesEntry removes e from the TopicNames of ref, if it is listed
+// there.
+func (s Txn) removeTopicNamesEntry(ref, e kv.Entity) error {
+	if ref == 0 {
+		return nil
+	}
+	es, err := s.GetTopicNames(ref)
+	if err != nil {
+		return err
+	}
+	for i, listed := range es {
+		if listed == e {
+			es = append(es[:i:i], es[i+1:]...)
+			return s.SetTopicNames(ref, es)
+		}
+	}
+	return nil
+}
+
+// EntitiesByNameTopic returns entities with
+// Name values ordered by the kv.Entity values from their
+// RefCascadeTopic method.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to ByTopic would return next n
+// entities.
+func (s Txn) EntitiesByNameTopic(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByScalableIndex(NamePrefix, TopicPrefix, cursor, n)
+}
+
+// EntitiesByNameTopicRange returns entities with
+// Name values ordered by the kv.Entity values from their
+// RefCascadeTopic method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesByNameTopicRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesByNameTopicRange(lower, upper *kv.Entity, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByScalableIndexRange(NamePrefix, TopicPrefix, lo, hi, cursor, n)
+}
+
+// EntitiesByNameTopicReverse returns entities with
+// Name values in reverse order by the kv.Entity values from
+// their RefCascadeTopic method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesByNameTopicReverse would return next n entities.
+func (s Txn) EntitiesByNameTopicReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByScalableIndexReverse(NamePrefix, TopicPrefix, cursor, n)
+}
+
+// VerifyNameIndexes checks that every index of Name values is
+// consistent with the Name values themselves, and returns every
+// inconsistency it finds.
+func (s Txn) VerifyNameIndexes() ([]*kv.IndexError, error) {
+	var ies []*kv.IndexError
+	report := func(ie *kv.IndexError) error {
+		ies = append(ies, ie)
+		return nil
+	}
+	if err := s.CheckScalableIndex(NamePrefix, ValuePrefix, indexNameValue, report); err != nil {
+		return ies, err
+	}
+	if err := s.CheckScalableIndex(NamePrefix, WordsPrefix, indexNameWords, report); err != nil {
+		return ies, err
+	}
+	if err := s.CheckScalableIndex(NamePrefix, TopicPrefix, indexNameTopic, report); err != nil {
+		return ies, err
+	}
+	return ies, nil
+}
+
+// RebuildNameIndexes rebuilds every index of Name values, so that
+// VerifyNameIndexes finds no inconsistencies.
+func (s Txn) RebuildNameIndexes() error {
+	if err := s.RebuildNameValueIndex(); err != nil {
+		return err
+	}
+	if err := s.RebuildNameWordsIndex(); err != nil {
+		return err
+	}
+	if err := s.RebuildNameTopicIndex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebuildNameValueIndex rebuilds the index of
+// Name values by the kv.String values from their
+// IndexScalableValue method.
+func (s Txn) RebuildNameValueIndex() error {
+	return s.RebuildScalableIndex(NamePrefix, ValuePrefix, indexNameValue)
+}
+
+// indexNameValue decodes a Name and returns
+// the encoded kv.String values from its IndexScalableValue method.
+func indexNameValue(bs []byte) ([][]byte, error) {
+	var v Name
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := v.IndexScalableValue()
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
+// RebuildNameWordsIndex rebuilds the index of
+// Name values by the kv.String values from their
+// IndexTextWords method.
+func (s Txn) RebuildNameWordsIndex() error {
+	return s.RebuildScalableIndex(NamePrefix, WordsPrefix, indexNameWords)
+}
+
+// indexNameWords decodes a Name and returns
+// the encoded words in the kv.String values from its IndexTextWords method.
+func indexNameWords(bs []byte) ([][]byte, error) {
+	var v Name
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := kv.TextTerms(v.IndexTextWords())
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
+// RebuildNameTopicIndex rebuilds the index of
+// Name values by the kv.Entity values from their
+// RefCascadeTopic method.
+func (s Txn) RebuildNameTopicIndex() error {
+	return s.RebuildScalableIndex(NamePrefix, TopicPrefix, indexNameTopic)
+}
+
+// indexNameTopic decodes a Name and returns
+// the encoded kv.Entity values from its RefCascadeTopic method.
+func indexNameTopic(bs []byte) ([][]byte, error) {
+	var v Name
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := kv.ReferencedEntities(v.RefCascadeTopic())
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
 }
 
 // SetOccurrence sets the Occurrence associated with e to v.
 //
 // Corresponding indexes are updated.
+//
+// If v refers to a different entity than before through its RefCascadeTopic
+// method, e is removed from the TopicOccurrences of the entity it referred to and
+// added to the end of the TopicOccurrences of the entity it refers to now.
 func (s Txn) SetOccurrence(e kv.Entity, v *Occurrence) error {
 	key := make(kv.Prefix, 8+2+8)
 	s.Partition.EncodeAt(key)
 	OccurrencePrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
+	bs, err := kv.EncodeFormatted(v.ValueFormat(), v)
+	if err != nil {
+		return err
+	}
 	var old Occurrence
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
-	if err := s.Set(key, v.Encode()); err != nil {
+	if err := s.Set(key, bs); err != nil {
 		return err
 	}
-	// A prefix buffer for all index keys.
-	prefix := kv.ConcatByteSlices(key, kv.Component(0).Encode())
-	kv.Entity(0).EncodeAt(prefix[10:])
-	var es kv.EntitySlice
 
 	// Update Value index
-	ValuePrefix.EncodeAt(prefix[18:])
-	for _, iv := range old.IndexValue() {
-		k := kv.ConcatByteSlices(prefix, iv.Encode())
-		if err := s.Get(k, es.Decode); err != nil {
+	for _, iv := range old.IndexScalableValue() {
+		if err := s.RemoveScalableIndexEntry(OccurrencePrefix, ValuePrefix, iv.Encode(), e); err != nil {
 			return err
 		}
-		if es.Remove(e) {
-			if err := s.Set(k, es.Encode()); err != nil {
-				return err
-			}
+	}
+	for _, iv := range v.IndexScalableValue() {
+		if err := s.InsertScalableIndexEntry(OccurrencePrefix, ValuePrefix, iv.Encode(), e); err != nil {
+			return err
 		}
 	}
-	for _, iv := range v.IndexValue() {
-		k := kv.ConcatByteSlices(prefix, iv.Encode())
-		if err := s.Get(k, es.Decode); err != nil {
+
+	// Update Words index
+	for _, iv := range kv.TextTerms(old.IndexTextWords()) {
+		if err := s.RemoveScalableIndexEntry(OccurrencePrefix, WordsPrefix, iv.Encode(), e); err != nil {
 			return err
 		}
-		if es.Insert(e) {
-			if err := s.Set(k, es.Encode()); err != nil {
-				return err
-			}
+	}
+	for _, iv := range kv.TextTerms(v.IndexTextWords()) {
+		if err := s.InsertScalableIndexEntry(OccurrencePrefix, WordsPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+
+	// Update Topic index
+	for _, iv := range kv.ReferencedEntities(old.RefCascadeTopic()) {
+		if err := s.RemoveScalableIndexEntry(OccurrencePrefix, TopicPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+	for _, iv := range kv.ReferencedEntities(v.RefCascadeTopic()) {
+		if err := s.InsertScalableIndexEntry(OccurrencePrefix, TopicPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+
+	// Update TopicOccurrences lists
+	if ref, oldRef := v.RefCascadeTopic(), old.RefCascadeTopic(); ref != oldRef {
+		if err := s.removeTopicOccurrencesEntry(oldRef, e); err != nil {
+			return err
+		}
+		if err := s.addTopicOccurrencesEntry(ref, e); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -363,35 +836,47 @@ func (s Txn) SetOccurrence(e kv.Entity, v *Occurrence) error {
 // DeleteOccurrence deletes the Occurrence associated with e.
 //
 // Corresponding indexes are updated.
+//
+// e is removed from the TopicOccurrences of the entity that the deleted value
+// referred to through its RefCascadeTopic method.
 func (s Txn) DeleteOccurrence(e kv.Entity) error {
 	key := make(kv.Prefix, 8+2+8)
 	s.Partition.EncodeAt(key)
 	OccurrencePrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
 	var old Occurrence
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
 	if err := s.Delete(key); err != nil {
 		return err
 	}
-	prefix := kv.ConcatByteSlices(key, kv.Component(0).Encode())
-	kv.Entity(0).EncodeAt(prefix[10:])
-	var es kv.EntitySlice
 
 	// Update Value index
-	ValuePrefix.EncodeAt(prefix[18:])
-	for _, iv := range old.IndexValue() {
-		k := kv.ConcatByteSlices(prefix, iv.Encode())
-		if err := s.Get(k, es.Decode); err != nil {
+	for _, iv := range old.IndexScalableValue() {
+		if err := s.RemoveScalableIndexEntry(OccurrencePrefix, ValuePrefix, iv.Encode(), e); err != nil {
 			return err
 		}
-		if es.Remove(e) {
-			if err := s.Set(k, es.Encode()); err != nil {
-				return err
-			}
+	}
+
+	// Update Words index
+	for _, iv := range kv.TextTerms(old.IndexTextWords()) {
+		if err := s.RemoveScalableIndexEntry(OccurrencePrefix, WordsPrefix, iv.Encode(), e); err != nil {
+			return err
+		}
+	}
+
+	// Update Topic index
+	for _, iv := range kv.ReferencedEntities(old.RefCascadeTopic()) {
+		if err := s.RemoveScalableIndexEntry(OccurrencePrefix, TopicPrefix, iv.Encode(), e); err != nil {
+			return err
 		}
 	}
+
+	// Update TopicOccurrences lists
+	if err := s.removeTopicOccurrencesEntry(old.RefCascadeTopic(), e); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -419,14 +904,22 @@ func (s Txn) GetOccurrenceSlice(es []kv.Entity) ([]Occurrence, error) {
 	OccurrencePrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeOccurrence)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(Occurrence)
 	}
 	return result, nil
 }
 
+// decodeOccurrence decodes a Occurrence, for use with kv.GetDecoded.
+func decodeOccurrence(bs []byte) (interface{}, error) {
+	var v Occurrence
+	err := kv.DecodeFormatted(bs, &v)
+	return v, err
+}
+
 // AllOccurrenceEntities returns the first n entities that have a Occurrence, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -438,45 +931,340 @@ func (s Txn) AllOccurrenceEntities(start *kv.Entity, n int) (es []kv.Entity, err
 	return s.AllComponentEntities(OccurrencePrefix, start, n)
 }
 
-// EntitiesMatchingOccurrenceValue returns entities with Occurrence values that return a matching kv.String from their IndexValue method.
+// AllOccurrenceEntitiesReverse returns the first n entities that have a
+// Occurrence in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllOccurrenceEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllOccurrenceEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(OccurrencePrefix, start, n)
+}
+
+// HasOccurrence returns a query matching every entity that has a Occurrence,
+// for use with QueryEntities.
+func (s Txn) HasOccurrence() kv.Query {
+	return kv.HasComponent(OccurrencePrefix)
+}
+
+// EntitiesMatchingOccurrenceValue returns entities with Occurrence values that return a matching kv.String from their IndexScalableValue method.
 //
 // The returned EntitySlice is already sorted.
 func (s Txn) EntitiesMatchingOccurrenceValue(v kv.String) (kv.EntitySlice, error) {
-	key := make(kv.Prefix, 8+2+8+2)
-	s.Partition.EncodeAt(key)
-	OccurrencePrefix.EncodeAt(key[8:])
-	kv.Entity(0).EncodeAt(key[10:])
-	ValuePrefix.EncodeAt(key[18:])
-	key = append(key, v.Encode()...)
-	var es kv.EntitySlice
-	return es, s.Get(key, es.Decode)
+	return s.EntitiesMatchingScalableIndex(OccurrencePrefix, ValuePrefix, v.Encode())
+}
+
+// MatchingOccurrenceValue returns a query matching the entities
+// that EntitiesMatchingOccurrenceValue would return, for use with
+// QueryEntities.
+func (s Txn) MatchingOccurrenceValue(v kv.String) kv.Query {
+	return kv.MatchingScalableIndex(OccurrencePrefix, ValuePrefix, v.Encode())
 }
 
 // EntitiesByOccurrenceValue returns entities with
 // Occurrence values ordered by the kv.String values from their
-// IndexValue method.
+// IndexScalableValue method.
 //
 // Reading begins at cursor, and ends when the length of the returned Entity
 // slice is less than n. When reading is not complete, cursor is updated such
 // that using it in a subequent call to ByValue would return next n
 // entities.
 func (s Txn) EntitiesByOccurrenceValue(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
-	return s.EntitiesByComponentIndex(OccurrencePrefix, ValuePrefix, cursor, n)
+	return s.EntitiesByScalableIndex(OccurrencePrefix, ValuePrefix, cursor, n)
+}
+
+// EntitiesByOccurrenceValueRange returns entities with
+// Occurrence values ordered by the kv.String values from their
+// IndexScalableValue method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesByOccurrenceValueRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesByOccurrenceValueRange(lower, upper *kv.String, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByScalableIndexRange(OccurrencePrefix, ValuePrefix, lo, hi, cursor, n)
+}
+
+// EntitiesByOccurrenceValueReverse returns entities with
+// Occurrence values in reverse order by the kv.String values from
+// their IndexScalableValue method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesByOccurrenceValueReverse would return next n entities.
+func (s Txn) EntitiesByOccurrenceValueReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByScalableIndexReverse(OccurrencePrefix, ValuePrefix, cursor, n)
+}
+
+// SearchOccurrenceWords returns up to n entities with
+// Occurrence values whose IndexTextWords method returns words that
+// match the words in query, best matches first.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) SearchOccurrenceWords(query string, n int) ([]kv.TextMatch, error) {
+	return s.SearchTextIndex(OccurrencePrefix, WordsPrefix, query, n)
+}
+
+// EntitiesMatchingOccurrenceTopic returns entities with Occurrence values that return a matching kv.Entity from their RefCascadeTopic method.
+//
+// The returned EntitySlice is already sorted.
+func (s Txn) EntitiesMatchingOccurrenceTopic(v kv.Entity) (kv.EntitySlice, error) {
+	return s.EntitiesMatchingScalableIndex(OccurrencePrefix, TopicPrefix, v.Encode())
+}
+
+// MatchingOccurrenceTopic returns a query matching the entities
+// that EntitiesMatchingOccurrenceTopic would return, for use with
+// QueryEntities.
+func (s Txn) MatchingOccurrenceTopic(v kv.Entity) kv.Query {
+	return kv.MatchingScalableIndex(OccurrencePrefix, TopicPrefix, v.Encode())
+}
+
+// SetOccurrenceTopic sets the entity that the Occurrence
+// associated with e refers to through its RefCascadeTopic method.
+//
+// Corresponding indexes are updated.
+func (s Txn) SetOccurrenceTopic(e, ref kv.Entity) error {
+	v, err := s.GetOccurrence(e)
+	if err != nil {
+		return err
+	}
+	v.SetRefTopic(ref)
+	return s.SetOccurrence(e, &v)
+}
+
+// addTopicOccurrencesEntry adds e to the end of the TopicOccurrences of ref, unless ref is
+// zero or e is already listed there.
+func (s Txn) addTopicOccurrencesEntry(ref, e kv.Entity) error {
+	if ref == 0 {
+		return nil
+	}
+	es, err := s.GetTopicOccurrences(ref)
+	if err != nil {
+		return err
+	}
+	for _, listed := range es {
+		if listed == e {
+			return nil
+		}
+	}
+	// The list may be shared with other readers, so it is copied rather
+	// than modified.
+	es = append(es[:len(es):len(es)], e)
+	return s.SetTopicOccurrences(ref, es)
+}
+
+// removeTopicOccurrencesEntry removes e from the TopicOccurrences of ref, if it is listed
+// there.
+func (s Txn) removeTopicOccurrencesEntry(ref, e kv.Entity) error {
+	if ref == 0 {
+		return nil
+	}
+	es, err := s.GetTopicOccurrences(ref)
+	if err != nil {
+		return err
+	}
+	for i, listed := range es {
+		if listed == e {
+			es = append(es[:i:i], es[i+1:]...)
+			return s.SetTopicOccurrences(ref, es)
+		}
+	}
+	return nil
+}
+
+// EntitiesByOccurrenceTopic returns entities with
+// Occurrence values ordered by the kv.Entity values from their
+// RefCascadeTopic method.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to ByTopic would return next n
+// entities.
+func (s Txn) EntitiesByOccurrenceTopic(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByScalableIndex(OccurrencePrefix, TopicPrefix, cursor, n)
+}
+
+// EntitiesByOccurrenceTopicRange returns entities with
+// Occurrence values ordered by the kv.Entity values from their
+// RefCascadeTopic method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesByOccurrenceTopicRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesByOccurrenceTopicRange(lower, upper *kv.Entity, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByScalableIndexRange(OccurrencePrefix, TopicPrefix, lo, hi, cursor, n)
+}
+
+// EntitiesByOccurrenceTopicReverse returns entities with
+// Occurrence values in reverse order by the kv.Entity values from
+// their RefCascadeTopic method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesByOccurrenceTopicReverse would return next n entities.
+func (s Txn) EntitiesByOccurrenceTopicReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByScalableIndexReverse(OccurrencePrefix, TopicPrefix, cursor, n)
+}
+
+// VerifyOccurrenceIndexes checks that every index of Occurrence values is
+// consistent with the Occurrence values themselves, and returns every
+// inconsistency it finds.
+func (s Txn) VerifyOccurrenceIndexes() ([]*kv.IndexError, error) {
+	var ies []*kv.IndexError
+	report := func(ie *kv.IndexError) error {
+		ies = append(ies, ie)
+		return nil
+	}
+	if err := s.CheckScalableIndex(OccurrencePrefix, ValuePrefix, indexOccurrenceValue, report); err != nil {
+		return ies, err
+	}
+	if err := s.CheckScalableIndex(OccurrencePrefix, WordsPrefix, indexOccurrenceWords, report); err != nil {
+		return ies, err
+	}
+	if err := s.CheckScalableIndex(OccurrencePrefix, TopicPrefix, indexOccurrenceTopic, report); err != nil {
+		return ies, err
+	}
+	return ies, nil
+}
+
+// RebuildOccurrenceIndexes rebuilds every index of Occurrence values, so that
+// VerifyOccurrenceIndexes finds no inconsistencies.
+func (s Txn) RebuildOccurrenceIndexes() error {
+	if err := s.RebuildOccurrenceValueIndex(); err != nil {
+		return err
+	}
+	if err := s.RebuildOccurrenceWordsIndex(); err != nil {
+		return err
+	}
+	if err := s.RebuildOccurrenceTopicIndex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebuildOccurrenceValueIndex rebuilds the index of
+// Occurrence values by the kv.String values from their
+// IndexScalableValue method.
+func (s Txn) RebuildOccurrenceValueIndex() error {
+	return s.RebuildScalableIndex(OccurrencePrefix, ValuePrefix, indexOccurrenceValue)
+}
+
+// indexOccurrenceValue decodes a Occurrence and returns
+// the encoded kv.String values from its IndexScalableValue method.
+func indexOccurrenceValue(bs []byte) ([][]byte, error) {
+	var v Occurrence
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := v.IndexScalableValue()
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
+// RebuildOccurrenceWordsIndex rebuilds the index of
+// Occurrence values by the kv.String values from their
+// IndexTextWords method.
+func (s Txn) RebuildOccurrenceWordsIndex() error {
+	return s.RebuildScalableIndex(OccurrencePrefix, WordsPrefix, indexOccurrenceWords)
+}
+
+// indexOccurrenceWords decodes a Occurrence and returns
+// the encoded words in the kv.String values from its IndexTextWords method.
+func indexOccurrenceWords(bs []byte) ([][]byte, error) {
+	var v Occurrence
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := kv.TextTerms(v.IndexTextWords())
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
+// RebuildOccurrenceTopicIndex rebuilds the index of
+// Occurrence values by the kv.Entity values from their
+// RefCascadeTopic method.
+func (s Txn) RebuildOccurrenceTopicIndex() error {
+	return s.RebuildScalableIndex(OccurrencePrefix, TopicPrefix, indexOccurrenceTopic)
+}
+
+// indexOccurrenceTopic decodes a Occurrence and returns
+// the encoded kv.Entity values from its RefCascadeTopic method.
+func indexOccurrenceTopic(bs []byte) ([][]byte, error) {
+	var v Occurrence
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := kv.ReferencedEntities(v.RefCascadeTopic())
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
 }
 
 // SetSIs sets the SIs associated with e to v.
 //
 // Corresponding indexes are updated.
+//
+// If another entity already has a SIs whose IndexUniqueLiteral
+// method returns any of the same values, SetSIs returns a
+// *kv.UniqueIndexError without making any changes.
 func (s Txn) SetSIs(e kv.Entity, v SIs) error {
 	key := make(kv.Prefix, 8+2+8)
 	s.Partition.EncodeAt(key)
 	SIsPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
+	bs, err := kv.EncodeFormatted(v.ValueFormat(), v)
+	if err != nil {
+		return err
+	}
+	for _, iv := range v.IndexUniqueLiteral() {
+		if err := s.CheckUniqueIndex(SIsPrefix, LiteralPrefix, e, iv.Encode()); err != nil {
+			return err
+		}
+	}
 	var old SIs
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
-	if err := s.Set(key, v.Encode()); err != nil {
+	if err := s.Set(key, bs); err != nil {
 		return err
 	}
 	// A prefix buffer for all index keys.
@@ -486,7 +1274,7 @@ func (s Txn) SetSIs(e kv.Entity, v SIs) error {
 
 	// Update Literal index
 	LiteralPrefix.EncodeAt(prefix[18:])
-	for _, iv := range old.IndexLiteral() {
+	for _, iv := range old.IndexUniqueLiteral() {
 		k := kv.ConcatByteSlices(prefix, iv.Encode())
 		if err := s.Get(k, es.Decode); err != nil {
 			return err
@@ -497,7 +1285,7 @@ func (s Txn) SetSIs(e kv.Entity, v SIs) error {
 			}
 		}
 	}
-	for _, iv := range v.IndexLiteral() {
+	for _, iv := range v.IndexUniqueLiteral() {
 		k := kv.ConcatByteSlices(prefix, iv.Encode())
 		if err := s.Get(k, es.Decode); err != nil {
 			return err
@@ -520,7 +1308,7 @@ func (s Txn) DeleteSIs(e kv.Entity) error {
 	SIsPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
 	var old SIs
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
 	if err := s.Delete(key); err != nil {
@@ -532,7 +1320,7 @@ func (s Txn) DeleteSIs(e kv.Entity) error {
 
 	// Update Literal index
 	LiteralPrefix.EncodeAt(prefix[18:])
-	for _, iv := range old.IndexLiteral() {
+	for _, iv := range old.IndexUniqueLiteral() {
 		k := kv.ConcatByteSlices(prefix, iv.Encode())
 		if err := s.Get(k, es.Decode); err != nil {
 			return err
@@ -570,14 +1358,22 @@ func (s Txn) GetSIsSlice(es []kv.Entity) ([]SIs, error) {
 	SIsPrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeSIs)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(SIs)
 	}
 	return result, nil
 }
 
+// decodeSIs decodes a SIs, for use with kv.GetDecoded.
+func decodeSIs(bs []byte) (interface{}, error) {
+	var v SIs
+	err := kv.DecodeFormatted(bs, &v)
+	return v, err
+}
+
 // AllSIsEntities returns the first n entities that have a SIs, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -589,7 +1385,28 @@ func (s Txn) AllSIsEntities(start *kv.Entity, n int) (es []kv.Entity, err error)
 	return s.AllComponentEntities(SIsPrefix, start, n)
 }
 
-// EntitiesMatchingSIsLiteral returns entities with SIs values that return a matching kv.String from their IndexLiteral method.
+// AllSIsEntitiesReverse returns the first n entities that have a
+// SIs in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllSIsEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllSIsEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(SIsPrefix, start, n)
+}
+
+// HasSIs returns a query matching every entity that has a SIs,
+// for use with QueryEntities.
+func (s Txn) HasSIs() kv.Query {
+	return kv.HasComponent(SIsPrefix)
+}
+
+// EntitiesMatchingSIsLiteral returns entities with SIs values that return a matching kv.String from their IndexUniqueLiteral method.
 //
 // The returned EntitySlice is already sorted.
 func (s Txn) EntitiesMatchingSIsLiteral(v kv.String) (kv.EntitySlice, error) {
@@ -603,16 +1420,111 @@ func (s Txn) EntitiesMatchingSIsLiteral(v kv.String) (kv.EntitySlice, error) {
 	return es, s.Get(key, es.Decode)
 }
 
-// EntitiesBySIsLiteral returns entities with
-// SIs values ordered by the kv.String values from their
-// IndexLiteral method.
-//
-// Reading begins at cursor, and ends when the length of the returned Entity
-// slice is less than n. When reading is not complete, cursor is updated such
-// that using it in a subequent call to ByLiteral would return next n
-// entities.
-func (s Txn) EntitiesBySIsLiteral(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
-	return s.EntitiesByComponentIndex(SIsPrefix, LiteralPrefix, cursor, n)
+// MatchingSIsLiteral returns a query matching the entities
+// that EntitiesMatchingSIsLiteral would return, for use with
+// QueryEntities.
+func (s Txn) MatchingSIsLiteral(v kv.String) kv.Query {
+	return kv.MatchingComponentIndex(SIsPrefix, LiteralPrefix, v.Encode())
+}
+
+// LookupSIsLiteral returns the entity with a SIs
+// value that returns a matching kv.String from its IndexUniqueLiteral method,
+// or zero if there is none.
+func (s Txn) LookupSIsLiteral(v kv.String) (kv.Entity, error) {
+	return s.LookupComponentIndex(SIsPrefix, LiteralPrefix, v.Encode())
+}
+
+// EntitiesBySIsLiteral returns entities with
+// SIs values ordered by the kv.String values from their
+// IndexUniqueLiteral method.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to ByLiteral would return next n
+// entities.
+func (s Txn) EntitiesBySIsLiteral(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByComponentIndex(SIsPrefix, LiteralPrefix, cursor, n)
+}
+
+// EntitiesBySIsLiteralRange returns entities with
+// SIs values ordered by the kv.String values from their
+// IndexUniqueLiteral method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesBySIsLiteralRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesBySIsLiteralRange(lower, upper *kv.String, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByComponentIndexRange(SIsPrefix, LiteralPrefix, lo, hi, cursor, n)
+}
+
+// EntitiesBySIsLiteralReverse returns entities with
+// SIs values in reverse order by the kv.String values from
+// their IndexUniqueLiteral method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesBySIsLiteralReverse would return next n entities.
+func (s Txn) EntitiesBySIsLiteralReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByComponentIndexReverse(SIsPrefix, LiteralPrefix, cursor, n)
+}
+
+// VerifySIsIndexes checks that every index of SIs values is
+// consistent with the SIs values themselves, and returns every
+// inconsistency it finds.
+func (s Txn) VerifySIsIndexes() ([]*kv.IndexError, error) {
+	var ies []*kv.IndexError
+	report := func(ie *kv.IndexError) error {
+		ies = append(ies, ie)
+		return nil
+	}
+	if err := s.CheckComponentIndex(SIsPrefix, LiteralPrefix, indexSIsLiteral, report); err != nil {
+		return ies, err
+	}
+	return ies, nil
+}
+
+// RebuildSIsIndexes rebuilds every index of SIs values, so that
+// VerifySIsIndexes finds no inconsistencies.
+func (s Txn) RebuildSIsIndexes() error {
+	if err := s.RebuildSIsLiteralIndex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebuildSIsLiteralIndex rebuilds the index of
+// SIs values by the kv.String values from their
+// IndexUniqueLiteral method.
+func (s Txn) RebuildSIsLiteralIndex() error {
+	return s.RebuildComponentIndex(SIsPrefix, LiteralPrefix, indexSIsLiteral)
+}
+
+// indexSIsLiteral decodes a SIs and returns
+// the encoded kv.String values from its IndexUniqueLiteral method.
+func indexSIsLiteral(bs []byte) ([][]byte, error) {
+	var v SIs
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := v.IndexUniqueLiteral()
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
 }
 
 // SetSLs sets the SLs associated with e to v.
@@ -623,11 +1535,15 @@ func (s Txn) SetSLs(e kv.Entity, v SLs) error {
 	s.Partition.EncodeAt(key)
 	SLsPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
+	bs, err := kv.EncodeFormatted(v.ValueFormat(), v)
+	if err != nil {
+		return err
+	}
 	var old SLs
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
-	if err := s.Set(key, v.Encode()); err != nil {
+	if err := s.Set(key, bs); err != nil {
 		return err
 	}
 	// A prefix buffer for all index keys.
@@ -671,7 +1587,7 @@ func (s Txn) DeleteSLs(e kv.Entity) error {
 	SLsPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
 	var old SLs
-	if err := s.Get(key, old.Decode); err != nil {
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
 		return err
 	}
 	if err := s.Delete(key); err != nil {
@@ -721,14 +1637,22 @@ func (s Txn) GetSLsSlice(es []kv.Entity) ([]SLs, error) {
 	SLsPrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeSLs)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(SLs)
 	}
 	return result, nil
 }
 
+// decodeSLs decodes a SLs, for use with kv.GetDecoded.
+func decodeSLs(bs []byte) (interface{}, error) {
+	var v SLs
+	err := kv.DecodeFormatted(bs, &v)
+	return v, err
+}
+
 // AllSLsEntities returns the first n entities that have a SLs, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -740,6 +1664,27 @@ func (s Txn) AllSLsEntities(start *kv.Entity, n int) (es []kv.Entity, err error)
 	return s.AllComponentEntities(SLsPrefix, start, n)
 }
 
+// AllSLsEntitiesReverse returns the first n entities that have a
+// SLs in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllSLsEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllSLsEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(SLsPrefix, start, n)
+}
+
+// HasSLs returns a query matching every entity that has a SLs,
+// for use with QueryEntities.
+func (s Txn) HasSLs() kv.Query {
+	return kv.HasComponent(SLsPrefix)
+}
+
 // EntitiesMatchingSLsLiteral returns entities with SLs values that return a matching kv.String from their IndexLiteral method.
 //
 // The returned EntitySlice is already sorted.
@@ -754,6 +1699,13 @@ func (s Txn) EntitiesMatchingSLsLiteral(v kv.String) (kv.EntitySlice, error) {
 	return es, s.Get(key, es.Decode)
 }
 
+// MatchingSLsLiteral returns a query matching the entities
+// that EntitiesMatchingSLsLiteral would return, for use with
+// QueryEntities.
+func (s Txn) MatchingSLsLiteral(v kv.String) kv.Query {
+	return kv.MatchingComponentIndex(SLsPrefix, LiteralPrefix, v.Encode())
+}
+
 // EntitiesBySLsLiteral returns entities with
 // SLs values ordered by the kv.String values from their
 // IndexLiteral method.
@@ -766,6 +1718,87 @@ func (s Txn) EntitiesBySLsLiteral(cursor *kv.IndexCursor, n int) (es []kv.Entity
 	return s.EntitiesByComponentIndex(SLsPrefix, LiteralPrefix, cursor, n)
 }
 
+// EntitiesBySLsLiteralRange returns entities with
+// SLs values ordered by the kv.String values from their
+// IndexLiteral method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesBySLsLiteralRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesBySLsLiteralRange(lower, upper *kv.String, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByComponentIndexRange(SLsPrefix, LiteralPrefix, lo, hi, cursor, n)
+}
+
+// EntitiesBySLsLiteralReverse returns entities with
+// SLs values in reverse order by the kv.String values from
+// their IndexLiteral method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesBySLsLiteralReverse would return next n entities.
+func (s Txn) EntitiesBySLsLiteralReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByComponentIndexReverse(SLsPrefix, LiteralPrefix, cursor, n)
+}
+
+// VerifySLsIndexes checks that every index of SLs values is
+// consistent with the SLs values themselves, and returns every
+// inconsistency it finds.
+func (s Txn) VerifySLsIndexes() ([]*kv.IndexError, error) {
+	var ies []*kv.IndexError
+	report := func(ie *kv.IndexError) error {
+		ies = append(ies, ie)
+		return nil
+	}
+	if err := s.CheckComponentIndex(SLsPrefix, LiteralPrefix, indexSLsLiteral, report); err != nil {
+		return ies, err
+	}
+	return ies, nil
+}
+
+// RebuildSLsIndexes rebuilds every index of SLs values, so that
+// VerifySLsIndexes finds no inconsistencies.
+func (s Txn) RebuildSLsIndexes() error {
+	if err := s.RebuildSLsLiteralIndex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebuildSLsLiteralIndex rebuilds the index of
+// SLs values by the kv.String values from their
+// IndexLiteral method.
+func (s Txn) RebuildSLsLiteralIndex() error {
+	return s.RebuildComponentIndex(SLsPrefix, LiteralPrefix, indexSLsLiteral)
+}
+
+// indexSLsLiteral decodes a SLs and returns
+// the encoded kv.String values from its IndexLiteral method.
+func indexSLsLiteral(bs []byte) ([][]byte, error) {
+	var v SLs
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := v.IndexLiteral()
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
 // SetTopicMapInfo sets the TopicMapInfo associated with e to v.
 //
 // Corresponding indexes are updated.
@@ -774,7 +1807,47 @@ func (s Txn) SetTopicMapInfo(e kv.Entity, v *TopicMapInfo) error {
 	s.Partition.EncodeAt(key)
 	TopicMapInfoPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
-	return s.Set(key, v.Encode())
+	bs, err := kv.EncodeFormatted(v.ValueFormat(), v)
+	if err != nil {
+		return err
+	}
+	var old TopicMapInfo
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
+		return err
+	}
+	if err := s.Set(key, bs); err != nil {
+		return err
+	}
+	// A prefix buffer for all index keys.
+	prefix := kv.ConcatByteSlices(key, kv.Component(0).Encode())
+	kv.Entity(0).EncodeAt(prefix[10:])
+	var es kv.EntitySlice
+
+	// Update Modified index
+	ModifiedPrefix.EncodeAt(prefix[18:])
+	for _, iv := range old.IndexModified() {
+		k := kv.ConcatByteSlices(prefix, iv.Encode())
+		if err := s.Get(k, es.Decode); err != nil {
+			return err
+		}
+		if es.Remove(e) {
+			if err := s.Set(k, es.Encode()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, iv := range v.IndexModified() {
+		k := kv.ConcatByteSlices(prefix, iv.Encode())
+		if err := s.Get(k, es.Decode); err != nil {
+			return err
+		}
+		if es.Insert(e) {
+			if err := s.Set(k, es.Encode()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // DeleteTopicMapInfo deletes the TopicMapInfo associated with e.
@@ -785,7 +1858,31 @@ func (s Txn) DeleteTopicMapInfo(e kv.Entity) error {
 	s.Partition.EncodeAt(key)
 	TopicMapInfoPrefix.EncodeAt(key[8:])
 	e.EncodeAt(key[10:])
-	return s.Delete(key)
+	var old TopicMapInfo
+	if err := s.Get(key, kv.FormattedDecoder(&old)); err != nil {
+		return err
+	}
+	if err := s.Delete(key); err != nil {
+		return err
+	}
+	prefix := kv.ConcatByteSlices(key, kv.Component(0).Encode())
+	kv.Entity(0).EncodeAt(prefix[10:])
+	var es kv.EntitySlice
+
+	// Update Modified index
+	ModifiedPrefix.EncodeAt(prefix[18:])
+	for _, iv := range old.IndexModified() {
+		k := kv.ConcatByteSlices(prefix, iv.Encode())
+		if err := s.Get(k, es.Decode); err != nil {
+			return err
+		}
+		if es.Remove(e) {
+			if err := s.Set(k, es.Encode()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // GetTopicMapInfo returns the TopicMapInfo associated with e.
@@ -812,14 +1909,22 @@ func (s Txn) GetTopicMapInfoSlice(es []kv.Entity) ([]TopicMapInfo, error) {
 	TopicMapInfoPrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeTopicMapInfo)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(TopicMapInfo)
 	}
 	return result, nil
 }
 
+// decodeTopicMapInfo decodes a TopicMapInfo, for use with kv.GetDecoded.
+func decodeTopicMapInfo(bs []byte) (interface{}, error) {
+	var v TopicMapInfo
+	err := kv.DecodeFormatted(bs, &v)
+	return v, err
+}
+
 // AllTopicMapInfoEntities returns the first n entities that have a TopicMapInfo, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -831,6 +1936,141 @@ func (s Txn) AllTopicMapInfoEntities(start *kv.Entity, n int) (es []kv.Entity, e
 	return s.AllComponentEntities(TopicMapInfoPrefix, start, n)
 }
 
+// AllTopicMapInfoEntitiesReverse returns the first n entities that have a
+// TopicMapInfo in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllTopicMapInfoEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllTopicMapInfoEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(TopicMapInfoPrefix, start, n)
+}
+
+// HasTopicMapInfo returns a query matching every entity that has a TopicMapInfo,
+// for use with QueryEntities.
+func (s Txn) HasTopicMapInfo() kv.Query {
+	return kv.HasComponent(TopicMapInfoPrefix)
+}
+
+// EntitiesMatchingTopicMapInfoModified returns entities with TopicMapInfo values that return a matching kv.Int64 from their IndexModified method.
+//
+// The returned EntitySlice is already sorted.
+func (s Txn) EntitiesMatchingTopicMapInfoModified(v kv.Int64) (kv.EntitySlice, error) {
+	key := make(kv.Prefix, 8+2+8+2)
+	s.Partition.EncodeAt(key)
+	TopicMapInfoPrefix.EncodeAt(key[8:])
+	kv.Entity(0).EncodeAt(key[10:])
+	ModifiedPrefix.EncodeAt(key[18:])
+	key = append(key, v.Encode()...)
+	var es kv.EntitySlice
+	return es, s.Get(key, es.Decode)
+}
+
+// MatchingTopicMapInfoModified returns a query matching the entities
+// that EntitiesMatchingTopicMapInfoModified would return, for use with
+// QueryEntities.
+func (s Txn) MatchingTopicMapInfoModified(v kv.Int64) kv.Query {
+	return kv.MatchingComponentIndex(TopicMapInfoPrefix, ModifiedPrefix, v.Encode())
+}
+
+// EntitiesByTopicMapInfoModified returns entities with
+// TopicMapInfo values ordered by the kv.Int64 values from their
+// IndexModified method.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to ByModified would return next n
+// entities.
+func (s Txn) EntitiesByTopicMapInfoModified(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByComponentIndex(TopicMapInfoPrefix, ModifiedPrefix, cursor, n)
+}
+
+// EntitiesByTopicMapInfoModifiedRange returns entities with
+// TopicMapInfo values ordered by the kv.Int64 values from their
+// IndexModified method, including only those values that are greater than or
+// equal to *lower and less than *upper.
+//
+// A nil lower or upper bound leaves that end of the range unbounded.
+//
+// Reading begins at cursor, and ends when the length of the returned Entity
+// slice is less than n. When reading is not complete, cursor is updated such
+// that using it in a subequent call to
+// EntitiesByTopicMapInfoModifiedRange with the same bounds would return
+// next n entities.
+func (s Txn) EntitiesByTopicMapInfoModifiedRange(lower, upper *kv.Int64, cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	var lo, hi []byte
+	if lower != nil {
+		lo = lower.Encode()
+	}
+	if upper != nil {
+		hi = upper.Encode()
+	}
+	return s.EntitiesByComponentIndexRange(TopicMapInfoPrefix, ModifiedPrefix, lo, hi, cursor, n)
+}
+
+// EntitiesByTopicMapInfoModifiedReverse returns entities with
+// TopicMapInfo values in reverse order by the kv.Int64 values from
+// their IndexModified method.
+//
+// A zero cursor starts reading from the end of the index. Reading ends when
+// the length of the returned Entity slice is less than n. When reading is not
+// complete, cursor is updated such that using it in a subequent call to
+// EntitiesByTopicMapInfoModifiedReverse would return next n entities.
+func (s Txn) EntitiesByTopicMapInfoModifiedReverse(cursor *kv.IndexCursor, n int) (es []kv.Entity, err error) {
+	return s.EntitiesByComponentIndexReverse(TopicMapInfoPrefix, ModifiedPrefix, cursor, n)
+}
+
+// VerifyTopicMapInfoIndexes checks that every index of TopicMapInfo values is
+// consistent with the TopicMapInfo values themselves, and returns every
+// inconsistency it finds.
+func (s Txn) VerifyTopicMapInfoIndexes() ([]*kv.IndexError, error) {
+	var ies []*kv.IndexError
+	report := func(ie *kv.IndexError) error {
+		ies = append(ies, ie)
+		return nil
+	}
+	if err := s.CheckComponentIndex(TopicMapInfoPrefix, ModifiedPrefix, indexTopicMapInfoModified, report); err != nil {
+		return ies, err
+	}
+	return ies, nil
+}
+
+// RebuildTopicMapInfoIndexes rebuilds every index of TopicMapInfo values, so that
+// VerifyTopicMapInfoIndexes finds no inconsistencies.
+func (s Txn) RebuildTopicMapInfoIndexes() error {
+	if err := s.RebuildTopicMapInfoModifiedIndex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebuildTopicMapInfoModifiedIndex rebuilds the index of
+// TopicMapInfo values by the kv.Int64 values from their
+// IndexModified method.
+func (s Txn) RebuildTopicMapInfoModifiedIndex() error {
+	return s.RebuildComponentIndex(TopicMapInfoPrefix, ModifiedPrefix, indexTopicMapInfoModified)
+}
+
+// indexTopicMapInfoModified decodes a TopicMapInfo and returns
+// the encoded kv.Int64 values from its IndexModified method.
+func indexTopicMapInfoModified(bs []byte) ([][]byte, error) {
+	var v TopicMapInfo
+	if err := kv.DecodeFormatted(bs, &v); err != nil {
+		return nil, err
+	}
+	ivs := v.IndexModified()
+	bss := make([][]byte, len(ivs))
+	for i, iv := range ivs {
+		bss[i] = iv.Encode()
+	}
+	return bss, nil
+}
+
 // SetTopicNames sets the TopicNames associated with e to v.
 //
 // Corresponding indexes are updated.
@@ -877,14 +2117,22 @@ func (s Txn) GetTopicNamesSlice(es []kv.Entity) ([]TopicNames, error) {
 	TopicNamesPrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeTopicNames)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(TopicNames)
 	}
 	return result, nil
 }
 
+// decodeTopicNames decodes a TopicNames, for use with kv.GetDecoded.
+func decodeTopicNames(bs []byte) (interface{}, error) {
+	var v TopicNames
+	err := v.Decode(bs)
+	return v, err
+}
+
 // AllTopicNamesEntities returns the first n entities that have a TopicNames, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -896,6 +2144,27 @@ func (s Txn) AllTopicNamesEntities(start *kv.Entity, n int) (es []kv.Entity, err
 	return s.AllComponentEntities(TopicNamesPrefix, start, n)
 }
 
+// AllTopicNamesEntitiesReverse returns the first n entities that have a
+// TopicNames in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllTopicNamesEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllTopicNamesEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(TopicNamesPrefix, start, n)
+}
+
+// HasTopicNames returns a query matching every entity that has a TopicNames,
+// for use with QueryEntities.
+func (s Txn) HasTopicNames() kv.Query {
+	return kv.HasComponent(TopicNamesPrefix)
+}
+
 // SetTopicOccurrences sets the TopicOccurrences associated with e to v.
 //
 // Corresponding indexes are updated.
@@ -942,14 +2211,22 @@ func (s Txn) GetTopicOccurrencesSlice(es []kv.Entity) ([]TopicOccurrences, error
 	TopicOccurrencesPrefix.EncodeAt(key[8:])
 	for i, e := range es {
 		e.EncodeAt(key[10:])
-		err := s.Get(key, (&result[i]).Decode)
+		v, err := kv.GetDecoded(s.Partitioned.Txn, key, decodeTopicOccurrences)
 		if err != nil {
 			return nil, err
 		}
+		result[i] = v.(TopicOccurrences)
 	}
 	return result, nil
 }
 
+// decodeTopicOccurrences decodes a TopicOccurrences, for use with kv.GetDecoded.
+func decodeTopicOccurrences(bs []byte) (interface{}, error) {
+	var v TopicOccurrences
+	err := v.Decode(bs)
+	return v, err
+}
+
 // AllTopicOccurrencesEntities returns the first n entities that have a TopicOccurrences, beginning
 // with the first entity greater than or equal to *start.
 //
@@ -960,3 +2237,171 @@ func (s Txn) GetTopicOccurrencesSlice(es []kv.Entity) ([]TopicOccurrences, error
 func (s Txn) AllTopicOccurrencesEntities(start *kv.Entity, n int) (es []kv.Entity, err error) {
 	return s.AllComponentEntities(TopicOccurrencesPrefix, start, n)
 }
+
+// AllTopicOccurrencesEntitiesReverse returns the first n entities that have a
+// TopicOccurrences in descending order, beginning with the greatest entity less than
+// *start.
+//
+// A nil start value, or a pointer to zero, will be interpreted as a pointer to
+// a value greater than any entity. When start is not nil, *start is updated
+// such that using it in a subsequent call to AllTopicOccurrencesEntitiesReverse would
+// return the next n entities.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) AllTopicOccurrencesEntitiesReverse(start *kv.Entity, n int) (es []kv.Entity, err error) {
+	return s.AllComponentEntitiesReverse(TopicOccurrencesPrefix, start, n)
+}
+
+// HasTopicOccurrences returns a query matching every entity that has a TopicOccurrences,
+// for use with QueryEntities.
+func (s Txn) HasTopicOccurrences() kv.Query {
+	return kv.HasComponent(TopicOccurrencesPrefix)
+}
+
+// DeleteEntity deletes every component associated with e.
+//
+// Entities that refer to e through a reference are deleted first if the
+// reference cascades. If the reference restricts deletion instead,
+// DeleteEntity returns a *kv.ReferenceError, and any changes already made to
+// delete other entities through cascading references should be discarded.
+func (s Txn) DeleteEntity(e kv.Entity) error {
+	var cascade []kv.Entity
+	if es, err := s.EntitiesMatchingNameTopic(e); err != nil {
+		return err
+	} else if len(es) > 0 {
+		cascade = append(cascade, es...)
+	}
+	if es, err := s.EntitiesMatchingOccurrenceTopic(e); err != nil {
+		return err
+	} else if len(es) > 0 {
+		cascade = append(cascade, es...)
+	}
+	if err := s.DeleteIIs(e); err != nil {
+		return err
+	}
+	if err := s.DeleteName(e); err != nil {
+		return err
+	}
+	if err := s.DeleteOccurrence(e); err != nil {
+		return err
+	}
+	if err := s.DeleteSIs(e); err != nil {
+		return err
+	}
+	if err := s.DeleteSLs(e); err != nil {
+		return err
+	}
+	if err := s.DeleteTopicMapInfo(e); err != nil {
+		return err
+	}
+	if err := s.DeleteTopicNames(e); err != nil {
+		return err
+	}
+	if err := s.DeleteTopicOccurrences(e); err != nil {
+		return err
+	}
+	for _, c := range cascade {
+		if err := s.DeleteEntity(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ComponentChange is a change to a component of an entity, decoded from a
+// kv.Change by DecodeChange.
+type ComponentChange struct {
+	Partition kv.Entity
+	Component kv.Component
+	Entity    kv.Entity
+
+	// Deleted is true if the component was deleted.
+	Deleted bool
+
+	// Value points to a new value of the component's type, or is nil if
+	// the component was deleted.
+	Value interface{}
+}
+
+// DecodeChange decodes a change to a component from c, such as a change
+// received from a kv.Subscription.
+//
+// DecodeChange returns false if c changed any other key, such as the key of an
+// index entry.
+func DecodeChange(c kv.Change) (ComponentChange, bool, error) {
+	p, component, e, ok := kv.SplitComponentKey(c.Key)
+	if !ok {
+		return ComponentChange{}, false, nil
+	}
+	cc := ComponentChange{Partition: p, Component: component, Entity: e, Deleted: c.Deleted}
+	switch component {
+	case IIsPrefix:
+		if !c.Deleted {
+			var v IIs
+			if err := kv.DecodeFormatted(c.Value, &v); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	case NamePrefix:
+		if !c.Deleted {
+			var v Name
+			if err := kv.DecodeFormatted(c.Value, &v); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	case OccurrencePrefix:
+		if !c.Deleted {
+			var v Occurrence
+			if err := kv.DecodeFormatted(c.Value, &v); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	case SIsPrefix:
+		if !c.Deleted {
+			var v SIs
+			if err := kv.DecodeFormatted(c.Value, &v); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	case SLsPrefix:
+		if !c.Deleted {
+			var v SLs
+			if err := kv.DecodeFormatted(c.Value, &v); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	case TopicMapInfoPrefix:
+		if !c.Deleted {
+			var v TopicMapInfo
+			if err := kv.DecodeFormatted(c.Value, &v); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	case TopicNamesPrefix:
+		if !c.Deleted {
+			var v TopicNames
+			if err := v.Decode(c.Value); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	case TopicOccurrencesPrefix:
+		if !c.Deleted {
+			var v TopicOccurrences
+			if err := v.Decode(c.Value); err != nil {
+				return cc, true, err
+			}
+			cc.Value = &v
+		}
+	default:
+		return ComponentChange{}, false, nil
+	}
+	return cc, true, nil
+}
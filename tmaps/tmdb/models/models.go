@@ -19,13 +19,13 @@ package models
 //go:generate kvschema
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/note-maps/kv"
+	"github.com/google/note-maps/kv/migrate"
 	"github.com/google/note-maps/tmaps/tmdb/models/internal/pb"
 )
 
@@ -40,14 +40,124 @@ const (
 	NamePrefix             kv.Component = 0x0008
 	OccurrencePrefix       kv.Component = 0x0009
 	ValuePrefix            kv.Component = 0x000A
+	ModifiedPrefix         kv.Component = 0x000B
+	WordsPrefix            kv.Component = 0x000C
+	TopicPrefix            kv.Component = 0x000D
 )
 
-// TopicMapInfo wraps pb.TopicMapInfo to implement kv.Encoder and kv.Decoder
-// interfaces.
+// ProtoFormat identifies component values encoded as protocol buffers.
+const ProtoFormat kv.Format = 0x40
+
+func init() {
+	kv.RegisterCodec(ProtoFormat, protoCodec{})
+}
+
+// Migrations upgrades the data in a database written by an earlier version of
+// this package, and must be run before the database is used.
+//
+// Any change to the way components are keyed or encoded must be accompanied by
+// a migration step registered here.
+var Migrations = migrate.NewSchema("models")
+
+func init() {
+	Migrations.Register(1, "tag component values with their format", func(txn kv.Txn) error {
+		for _, step := range []func(kv.Txn) error{
+			migrate.RewriteComponent(TopicMapInfoPrefix, tagFormat(ProtoFormat)),
+			migrate.RewriteComponent(IIsPrefix, tagFormat(kv.JSONFormat)),
+			migrate.RewriteComponent(SIsPrefix, tagFormat(kv.JSONFormat)),
+			migrate.RewriteComponent(SLsPrefix, tagFormat(kv.JSONFormat)),
+			migrate.RewriteComponent(NamePrefix, tagFormat(ProtoFormat)),
+			migrate.RewriteComponent(OccurrencePrefix, tagFormat(ProtoFormat)),
+		} {
+			if err := step(txn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func init() {
+	Migrations.RegisterBatched(2, "index topic maps by modification time", func(txn kv.Txn) error {
+		return New(txn).RebuildTopicMapInfoModifiedIndex()
+	})
+}
+
+func init() {
+	Migrations.RegisterBatched(3, "store name and occurrence value indexes as scalable indexes", func(txn kv.Txn) error {
+		return forEachPartition(txn, func(ms Txn) error {
+			if err := ms.RebuildNameValueIndex(); err != nil {
+				return err
+			}
+			return ms.RebuildOccurrenceValueIndex()
+		})
+	})
+}
+
+func init() {
+	Migrations.RegisterBatched(4, "index names and occurrences by the words in their values", func(txn kv.Txn) error {
+		return forEachPartition(txn, func(ms Txn) error {
+			if err := ms.RebuildNameWordsIndex(); err != nil {
+				return err
+			}
+			return ms.RebuildOccurrenceWordsIndex()
+		})
+	})
+}
+
+func init() {
+	Migrations.RegisterBatched(5, "index names and occurrences by the topics they refer to", func(txn kv.Txn) error {
+		return forEachPartition(txn, func(ms Txn) error {
+			if err := ms.RebuildNameTopicIndex(); err != nil {
+				return err
+			}
+			return ms.RebuildOccurrenceTopicIndex()
+		})
+	})
+}
+
+func init() {
+	Migrations.RegisterBatched(6, "reconcile the lists of topics' names and occurrences with the topics they refer to", func(txn kv.Txn) error {
+		return forEachPartition(txn, Txn.reconcileTopicLists)
+	})
+}
+
+// forEachPartition calls f with a Txn for partition zero and for the
+// partition of each topic map in turn, and stops at the first error returned
+// by f.
+func forEachPartition(txn kv.Txn, f func(Txn) error) error {
+	ms := New(txn)
+	tms, err := ms.AllTopicMapInfoEntities(nil, 0)
+	if err != nil {
+		return err
+	}
+	for _, p := range append([]kv.Entity{0}, tms...) {
+		ms.Partition = p
+		if err = f(ms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagFormat returns a function that prefixes an untagged value with f.
+func tagFormat(f kv.Format) func([]byte) ([]byte, error) {
+	return func(v []byte) ([]byte, error) {
+		return kv.ConcatByteSlices([]byte{byte(f)}, v), nil
+	}
+}
+
+// TopicMapInfo wraps pb.TopicMapInfo to implement the kv.Formatted interface.
 type TopicMapInfo struct{ pb.TopicMapInfo }
 
-func (tmi *TopicMapInfo) Encode() []byte          { return encodeProto(tmi) }
-func (tmi *TopicMapInfo) Decode(src []byte) error { return decodeProto(src, tmi) }
+func (tmi *TopicMapInfo) ValueFormat() kv.Format { return ProtoFormat }
+func (tmi *TopicMapInfo) message() proto.Message { return &tmi.TopicMapInfo }
+
+// IndexModified indexes topic maps by the time they were last modified, so
+// that they can be listed from the least to the most recently modified.
+func (tmi *TopicMapInfo) IndexModified() []kv.Int64 {
+	return []kv.Int64{kv.Int64(tmi.GetModifiedUnixSeconds())}
+}
 
 type (
 	IIs []string
@@ -55,37 +165,29 @@ type (
 	SLs []string
 )
 
-func (iis IIs) Encode() []byte { return encodeStringSlice(iis) }
-func (sis SIs) Encode() []byte { return encodeStringSlice(sis) }
-func (sls SLs) Encode() []byte { return encodeStringSlice(sls) }
-
-func (iis *IIs) Decode(bs []byte) error {
-	ss := []string(*iis)
-	err := decodeStringSlice(&ss, bs)
-	*iis = IIs(ss)
-	return err
-}
-func (sis *SIs) Decode(bs []byte) error {
-	ss := []string(*sis)
-	err := decodeStringSlice(&ss, bs)
-	*sis = SIs(ss)
-	return err
-}
-func (sls *SLs) Decode(bs []byte) error {
-	ss := []string(*sls)
-	err := decodeStringSlice(&ss, bs)
-	*sls = SLs(ss)
-	return err
-}
+func (IIs) ValueFormat() kv.Format { return kv.JSONFormat }
+func (SIs) ValueFormat() kv.Format { return kv.JSONFormat }
+func (SLs) ValueFormat() kv.Format { return kv.JSONFormat }
 
 func (iis IIs) IndexLiteral() []kv.String { return literalStringSlice(iis) }
-func (sis SIs) IndexLiteral() []kv.String { return literalStringSlice(sis) }
 func (sls SLs) IndexLiteral() []kv.String { return literalStringSlice(sls) }
 
+// IndexUniqueLiteral indexes topics by their subject identifiers, which must be
+// unique within a topic map, so SetSIs fails with a *kv.UniqueIndexError
+// rather than give a second topic a subject identifier that is already in
+// use.
+func (sis SIs) IndexUniqueLiteral() []kv.String { return literalStringSlice(sis) }
+
 // TopicNames holds a slice of all of a topic's names.
 //
 // TopicNames is not sorted: names are ordered according to user preferences,
 // and this is how that ordering is represented in kvmodels.
+//
+// Each name also refers to its topic, so that the name can be found through
+// the reverse index of that reference and deleted along with the topic.
+// SetName and DeleteEntity keep the two in agreement by adding names to and
+// removing them from the list, so SetTopicNames need only be used to reorder
+// a topic's names.
 type TopicNames kv.EntitySlice
 
 func (tns TopicNames) Encode() []byte {
@@ -102,6 +204,12 @@ func (tns *TopicNames) Decode(bs []byte) error {
 //
 // TopicOccurrences is not sorted: occurrences are ordered according to user
 // preferences, and this is how that ordering is represented in kvmodels.
+//
+// Each occurrence also refers to its topic, so that the occurrence can be found
+// through the reverse index of that reference and deleted along with the
+// topic. SetOccurrence and DeleteEntity keep the two in agreement by adding
+// occurrences to and removing them from the list, so SetTopicOccurrences need
+// only be used to reorder a topic's occurrences.
 type TopicOccurrences kv.EntitySlice
 
 func (tos TopicOccurrences) Encode() []byte {
@@ -114,28 +222,361 @@ func (tos *TopicOccurrences) Decode(bs []byte) error {
 	return err
 }
 
-// Name wraps pb.Name to implement kv.Encoder and kv.Decoder interfaces.
+// Name wraps pb.Name to implement the kv.Formatted interface.
 type Name struct{ pb.Name }
 
-func (n *Name) Encode() []byte          { return encodeProto(n) }
-func (n *Name) Decode(src []byte) error { return decodeProto(src, n) }
-func (n *Name) IndexValue() []kv.String { return []kv.String{kv.String(n.GetValue())} }
+func (n *Name) ValueFormat() kv.Format { return ProtoFormat }
+func (n *Name) message() proto.Message { return &n.Name }
+
+// IndexScalableValue indexes names by their values, which are often shared by
+// many names, in a scalable index.
+func (n *Name) IndexScalableValue() []kv.String {
+	return []kv.String{kv.String(n.GetValue())}
+}
+
+// RefCascadeTopic refers to the topic that a name belongs to, so that the
+// name is deleted along with the topic.
+func (n *Name) RefCascadeTopic() kv.Entity { return kv.Entity(n.Topic) }
+
+// SetRefTopic sets the topic that a name belongs to.
+func (n *Name) SetRefTopic(e kv.Entity) { n.Topic = uint64(e) }
+
+// IndexTextWords indexes names by the words in their values, so that they can
+// be found by SearchNameWords.
+func (n *Name) IndexTextWords() []kv.String {
+	return []kv.String{kv.String(n.GetValue())}
+}
 
-// Occurrence wraps pb.Names to implement kv.Encoder and kv.Decoder interfaces.
+// Occurrence wraps pb.Occurrence to implement the kv.Formatted interface.
 type Occurrence struct{ pb.Occurrence }
 
-func (o *Occurrence) Encode() []byte          { return encodeProto(o) }
-func (o *Occurrence) Decode(src []byte) error { return decodeProto(src, o) }
-func (o *Occurrence) IndexValue() []kv.String { return []kv.String{kv.String(o.GetValue())} }
+func (o *Occurrence) ValueFormat() kv.Format { return ProtoFormat }
+func (o *Occurrence) message() proto.Message { return &o.Occurrence }
+
+// IndexScalableValue indexes occurrences by their values, which are often
+// shared by many occurrences, in a scalable index.
+func (o *Occurrence) IndexScalableValue() []kv.String {
+	return []kv.String{kv.String(o.GetValue())}
+}
+
+// RefCascadeTopic refers to the topic that an occurrence belongs to, so that
+// the occurrence is deleted along with the topic.
+func (o *Occurrence) RefCascadeTopic() kv.Entity { return kv.Entity(o.Topic) }
+
+// SetRefTopic sets the topic that an occurrence belongs to.
+func (o *Occurrence) SetRefTopic(e kv.Entity) { o.Topic = uint64(e) }
+
+// IndexTextWords indexes occurrences by the words in their values, so that
+// they can be found by SearchOccurrenceWords.
+func (o *Occurrence) IndexTextWords() []kv.String {
+	return []kv.String{kv.String(o.GetValue())}
+}
+
+// IsTopic reports whether e is a topic, which is an entity with a TopicNames
+// value, even an empty one, and with neither a Name nor an Occurrence.
+//
+// The topic that reifies a topic map shares its entity with the topic map's
+// partition, and is a topic even before it has any names or occurrences.
+func (s Txn) IsTopic(e kv.Entity) (bool, error) {
+	if e != 0 && e == s.Partition {
+		return true, nil
+	}
+	for _, c := range []kv.Component{TopicNamesPrefix, NamePrefix, OccurrencePrefix} {
+		if ok, err := s.hasComponent(c, e); err != nil {
+			return false, err
+		} else if ok != (c == TopicNamesPrefix) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RemoveTopic deletes topic e along with its names and occurrences, or returns
+// an error if e is not a topic.
+func (s Txn) RemoveTopic(e kv.Entity) error {
+	if ok, err := s.IsTopic(e); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("models: entity %v is not a topic", e)
+	}
+	return s.DeleteEntity(e)
+}
+
+// AddName sets the Name of e to n, which adds e to the end of the TopicNames
+// of the topic that n refers to unless it already referred to that topic.
+//
+// AddName returns an error if n refers to an entity that is not a topic.
+func (s Txn) AddName(e kv.Entity, n *Name) error {
+	if err := s.checkTopic("name", e, n.RefCascadeTopic()); err != nil {
+		return err
+	}
+	return s.SetName(e, n)
+}
+
+// RemoveName deletes name e, which also removes it from the TopicNames of its
+// topic, or returns an error if e has no Name.
+func (s Txn) RemoveName(e kv.Entity) error { return s.removeChild(NamePrefix, "name", e) }
+
+// AddOccurrence sets the Occurrence of e to o, which adds e to the end of the
+// TopicOccurrences of the topic that o refers to unless it already referred to
+// that topic.
+//
+// AddOccurrence returns an error if o refers to an entity that is not a topic.
+func (s Txn) AddOccurrence(e kv.Entity, o *Occurrence) error {
+	if err := s.checkTopic("occurrence", e, o.RefCascadeTopic()); err != nil {
+		return err
+	}
+	return s.SetOccurrence(e, o)
+}
+
+// RemoveOccurrence deletes occurrence e, which also removes it from the
+// TopicOccurrences of its topic, or returns an error if e has no Occurrence.
+func (s Txn) RemoveOccurrence(e kv.Entity) error {
+	return s.removeChild(OccurrencePrefix, "occurrence", e)
+}
+
+// checkTopic returns an error if topic is neither zero nor a topic, naming e
+// as a child of the given kind.
+func (s Txn) checkTopic(kind string, e, topic kv.Entity) error {
+	if topic == 0 {
+		return nil
+	}
+	if ok, err := s.IsTopic(topic); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("models: %s %v cannot refer to entity %v, which is not a topic", kind, e, topic)
+	}
+	return nil
+}
+
+// removeChild deletes e, or returns an error if e has no value of component
+// c, naming e as a child of the given kind.
+func (s Txn) removeChild(c kv.Component, kind string, e kv.Entity) error {
+	if ok, err := s.hasComponent(c, e); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("models: entity %v is not a %s", e, kind)
+	}
+	return s.DeleteEntity(e)
+}
+
+// reconcileTopicLists makes the TopicNames and TopicOccurrences of every topic
+// agree with the topics that names and occurrences refer to, as they may not
+// in data written before SetName, SetOccurrence, and DeleteEntity kept them in
+// agreement.
+//
+// A listed name or occurrence keeps its place if it refers to the topic, or if
+// it refers to no topic at all, in which case it is made to refer to the
+// topic. Any other listed entity is removed. Names and occurrences that refer
+// to the topic but are not listed are added to the end of the list in
+// ascending order.
+func (s Txn) reconcileTopicLists() error {
+	var topics kv.EntitySlice
+	for _, c := range []kv.Component{TopicNamesPrefix, TopicOccurrencesPrefix} {
+		es, err := s.AllComponentEntities(c, nil, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range es {
+			topics.Insert(e)
+		}
+	}
+	for _, topic := range topics {
+		for _, l := range []children{s.names(), s.occurrences()} {
+			if err := l.reconcile(topic); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// children gives access to the lists of one kind of a topic's children, such
+// as its names, and to the references that the children hold.
+type children struct {
+	s    Txn
+	c    kv.Component
+	list func(topic kv.Entity) ([]kv.Entity, error)
+	set  func(topic kv.Entity, es []kv.Entity) error
+	// ref returns the topic that e refers to.
+	ref func(e kv.Entity) (kv.Entity, error)
+	// setRef sets the topic that e refers to.
+	setRef func(e, topic kv.Entity) error
+	// referrers returns the children that refer to topic, in ascending
+	// order.
+	referrers func(topic kv.Entity) (kv.EntitySlice, error)
+}
+
+func (s Txn) names() children {
+	return children{
+		s: s,
+		c: NamePrefix,
+		list: func(topic kv.Entity) ([]kv.Entity, error) {
+			return s.GetTopicNames(topic)
+		},
+		set: func(topic kv.Entity, es []kv.Entity) error {
+			return s.SetTopicNames(topic, es)
+		},
+		ref: func(e kv.Entity) (kv.Entity, error) {
+			n, err := s.GetName(e)
+			return n.RefCascadeTopic(), err
+		},
+		setRef:    s.SetNameTopic,
+		referrers: s.EntitiesMatchingNameTopic,
+	}
+}
+
+func (s Txn) occurrences() children {
+	return children{
+		s: s,
+		c: OccurrencePrefix,
+		list: func(topic kv.Entity) ([]kv.Entity, error) {
+			return s.GetTopicOccurrences(topic)
+		},
+		set: func(topic kv.Entity, es []kv.Entity) error {
+			return s.SetTopicOccurrences(topic, es)
+		},
+		ref: func(e kv.Entity) (kv.Entity, error) {
+			o, err := s.GetOccurrence(e)
+			return o.RefCascadeTopic(), err
+		},
+		setRef:    s.SetOccurrenceTopic,
+		referrers: s.EntitiesMatchingOccurrenceTopic,
+	}
+}
+
+// reconcile makes the list of topic agree with the references to topic, as
+// described by reconcileTopicLists.
+func (l children) reconcile(topic kv.Entity) error {
+	listed, err := l.list(topic)
+	if err != nil {
+		return err
+	}
+	referrers, err := l.referrers(topic)
+	if err != nil {
+		return err
+	}
+	var es, seen kv.EntitySlice
+	for _, e := range listed {
+		if !seen.Insert(e) {
+			continue
+		}
+		if i := referrers.Search(e); i == len(referrers) || referrers[i] != e {
+			if ok, err := l.s.hasComponent(l.c, e); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+			if ref, err := l.ref(e); err != nil {
+				return err
+			} else if ref != 0 {
+				continue
+			}
+			if err := l.setRef(e, topic); err != nil {
+				return err
+			}
+		}
+		es = append(es, e)
+	}
+	for _, e := range referrers {
+		if seen.Insert(e) {
+			es = append(es, e)
+		}
+	}
+	return l.set(topic, es)
+}
+
+// hasComponent reports whether e has a value of component c.
+func (s Txn) hasComponent(c kv.Component, e kv.Entity) (bool, error) {
+	key := make(kv.Prefix, 8+2+8)
+	s.Partition.EncodeAt(key)
+	c.EncodeAt(key[8:])
+	e.EncodeAt(key[10:])
+	return kv.Has(s.Partitioned.Txn, key)
+}
+
+// SearchTopics returns up to n topics with names or occurrences that match the
+// words in query, best matches first.
+//
+// Topics are ranked by the score of their best matching name, then by the
+// score of their best matching occurrence, as scored by kv.SearchTextIndex,
+// and then in ascending order. Names and occurrences that do not refer to a
+// topic are ignored.
+//
+// A value of n less than or equal to zero will be interpretted as the largest
+// possible value.
+func (s Txn) SearchTopics(query string, n int) ([]kv.Entity, error) {
+	type scores struct{ name, occurrence int }
+	topics := make(map[kv.Entity]*scores)
+	topic := func(e kv.Entity) *scores {
+		if topics[e] == nil {
+			topics[e] = &scores{}
+		}
+		return topics[e]
+	}
+	nms, err := s.SearchNameWords(query, 0)
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.GetNameSlice(textMatchEntities(nms))
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range nms {
+		if names[i].Topic == 0 {
+			continue
+		}
+		if t := topic(kv.Entity(names[i].Topic)); m.Score > t.name {
+			t.name = m.Score
+		}
+	}
+	oms, err := s.SearchOccurrenceWords(query, 0)
+	if err != nil {
+		return nil, err
+	}
+	occurrences, err := s.GetOccurrenceSlice(textMatchEntities(oms))
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range oms {
+		if occurrences[i].Topic == 0 {
+			continue
+		}
+		if t := topic(kv.Entity(occurrences[i].Topic)); m.Score > t.occurrence {
+			t.occurrence = m.Score
+		}
+	}
+	es := make([]kv.Entity, 0, len(topics))
+	for e := range topics {
+		es = append(es, e)
+	}
+	sort.Slice(es, func(i, j int) bool {
+		a, b := topics[es[i]], topics[es[j]]
+		if a.name != b.name {
+			return a.name > b.name
+		} else if a.occurrence != b.occurrence {
+			return a.occurrence > b.occurrence
+		}
+		return es[i] < es[j]
+	})
+	if n > 0 && len(es) > n {
+		es = es[:n]
+	}
+	return es, nil
+}
+
+func textMatchEntities(ms []kv.TextMatch) []kv.Entity {
+	es := make([]kv.Entity, len(ms))
+	for i := range ms {
+		es[i] = ms[i].Entity
+	}
+	return es
+}
 
 // UnsupportedFormatError indicates that a value was found in the key-value
 // backing store with an unsupported format code, perhaps due to data
 // corruption.
-type UnsupportedFormatError byte
-
-func (e UnsupportedFormatError) Error() string {
-	return fmt.Sprintf("unsupported format code 0x%x", byte(e))
-}
+type UnsupportedFormatError = kv.UnsupportedFormatError
 
 func normalizeURLs(us []string) []kv.String {
 	normalized := make([]kv.String, len(us))
@@ -145,31 +586,40 @@ func normalizeURLs(us []string) []kv.String {
 	return normalized
 }
 
-func encodeProto(src proto.Message) []byte {
-	bs, err := proto.Marshal(src)
-	if err != nil {
-		log.Println(err)
-	}
-	return bs
+// protoWrapper is implemented by types that wrap a protocol buffer message.
+type protoWrapper interface {
+	message() proto.Message
 }
 
-func decodeProto(src []byte, dst proto.Message) error {
-	return proto.Unmarshal(src, dst)
+// protoCodec encodes and decodes protocol buffer messages, and the messages
+// wrapped by values that implement protoWrapper.
+type protoCodec struct{}
+
+func (protoCodec) Encode(v interface{}) ([]byte, error) {
+	m, err := protoMessage(v)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
 }
 
-func encodeStringSlice(src []string) []byte {
-	bs, err := json.Marshal(src)
+func (protoCodec) Decode(src []byte, v interface{}) error {
+	m, err := protoMessage(v)
 	if err != nil {
-		log.Println(err)
+		return err
 	}
-	return bs
+	return proto.Unmarshal(src, m)
 }
 
-func decodeStringSlice(dst *[]string, src []byte) error {
-	if len(src) == 0 {
-		return nil
+func protoMessage(v interface{}) (proto.Message, error) {
+	switch m := v.(type) {
+	case protoWrapper:
+		return m.message(), nil
+	case proto.Message:
+		return m, nil
+	default:
+		return nil, fmt.Errorf("models: %T is not a protocol buffer message", v)
 	}
-	return json.Unmarshal(src, dst)
 }
 
 func literalStringSlice(src []string) []kv.String {
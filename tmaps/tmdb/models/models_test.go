@@ -16,12 +16,15 @@ package models
 
 import (
 	"os"
+	"reflect"
 	"sort"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/note-maps/kv"
 	"github.com/google/note-maps/kv/kvtest"
 	"github.com/google/note-maps/kv/memory"
+	"github.com/google/note-maps/kv/migrate"
 )
 
 func createTopicMap(s *Txn) (*TopicMapInfo, error) {
@@ -122,3 +125,692 @@ func TestSetGetTopicNames(t *testing.T) {
 	}()
 	kvtest.DumpDB(os.Stderr, db)
 }
+
+func TestUnsupportedFormat(t *testing.T) {
+	txn := New(memory.New())
+	key := kv.ConcatByteSlices(
+		kv.Entity(0).Encode(), NamePrefix.Encode(), kv.Entity(1).Encode())
+	if err := txn.Set(key, []byte{0x3f}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.GetName(1); err != UnsupportedFormatError(0x3f) {
+		t.Errorf("want UnsupportedFormatError(0x3f), got %v", err)
+	}
+}
+
+func TestMigrateFormats(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	var name Name
+	name.Value = "Test"
+	untagged, err := proto.Marshal(&name.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = kv.Update(db, func(txn kv.Txn) error {
+		key := kv.ConcatByteSlices(
+			kv.Entity(1).Encode(), NamePrefix.Encode(), kv.Entity(2).Encode())
+		if err := txn.Set(key, untagged); err != nil {
+			return err
+		}
+		key = kv.ConcatByteSlices(
+			kv.Entity(1).Encode(), IIsPrefix.Encode(), kv.Entity(2).Encode())
+		return txn.Set(key, []byte(`["http://example.com"]`))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = Migrations.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	ms := New(txn)
+	ms.Partition = 1
+	if got, err := ms.GetName(2); err != nil {
+		t.Error(err)
+	} else if got.Value != name.Value {
+		t.Errorf("want name %q, got %q", name.Value, got.Value)
+	}
+	if got, err := ms.GetIIs(2); err != nil {
+		t.Error(err)
+	} else if len(got) != 1 || got[0] != "http://example.com" {
+		t.Errorf("want [http://example.com], got %v", got)
+	}
+}
+
+func TestTopicMapsByModified(t *testing.T) {
+	txn := New(memory.New())
+	for i, modified := range []int64{5, -3, 1 << 40, 0} {
+		info := &TopicMapInfo{}
+		info.TopicMap = uint64(i + 1)
+		info.ModifiedUnixSeconds = modified
+		if err := txn.SetTopicMapInfo(kv.Entity(i+1), info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []kv.Entity{2, 4, 1, 3}
+	if got, err := txn.EntitiesByTopicMapInfoModified(&kv.IndexCursor{}, 10); err != nil {
+		t.Fatal(err)
+	} else if !kv.EntitySlice(want).Equal(kv.EntitySlice(got)) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestUniqueSIs(t *testing.T) {
+	txn := New(memory.New())
+	const iri = "http://example.com/subject"
+	if err := txn.SetSIs(1, SIs{iri}); err != nil {
+		t.Fatal(err)
+	}
+	// Setting the same value again for the same topic is not a violation.
+	if err := txn.SetSIs(1, SIs{iri, "http://example.com/other"}); err != nil {
+		t.Fatal(err)
+	}
+	err := txn.SetSIs(2, SIs{iri})
+	if ue, ok := err.(*kv.UniqueIndexError); !ok {
+		t.Fatalf("want a *kv.UniqueIndexError, got %v", err)
+	} else if ue.Entity != 2 || ue.Holder != 1 || string(ue.Value) != iri {
+		t.Errorf("want entity 2 and holder 1 for %q, got %v", iri, ue)
+	}
+	if got, err := txn.GetSIs(2); err != nil {
+		t.Error(err)
+	} else if len(got) != 0 {
+		t.Errorf("want no SIs set for a topic after a violation, got %v", got)
+	}
+	if e, err := txn.LookupSIsLiteral(iri); err != nil {
+		t.Error(err)
+	} else if e != 1 {
+		t.Errorf("want topic 1, got %v", e)
+	}
+	if e, err := txn.LookupSIsLiteral("http://example.com/none"); err != nil {
+		t.Error(err)
+	} else if e != 0 {
+		t.Errorf("want no topic, got %v", e)
+	}
+}
+
+func TestScalableNameIndex(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	ms := New(txn)
+	values := map[kv.Entity]string{
+		1: "Introduction", 2: "Intro", 3: "Introduction", 4: "\x00",
+		5: "Introduction", 6: "Intro\x00", 7: "Zebra",
+	}
+	for e, v := range values {
+		var n Name
+		n.Value = v
+		if err := ms.SetName(e, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Renaming a name moves it from one index value to another.
+	var n Name
+	n.Value = "Introduction"
+	if err := ms.SetName(7, &n); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.DeleteName(3); err != nil {
+		t.Fatal(err)
+	}
+	want := kv.EntitySlice{1, 5, 7}
+	if got, err := ms.EntitiesMatchingNameValue("Introduction"); err != nil {
+		t.Error(err)
+	} else if !want.Equal(got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// Page through the index two entities at a time in each direction.
+	for _, test := range []struct {
+		name string
+		read func(*kv.IndexCursor, int) ([]kv.Entity, error)
+		want []kv.Entity
+	}{
+		{"forward", ms.EntitiesByNameValue, []kv.Entity{4, 2, 6, 1, 5, 7}},
+		{"reverse", ms.EntitiesByNameValueReverse, []kv.Entity{7, 5, 1, 6, 2, 4}},
+		{"range", func(c *kv.IndexCursor, n int) ([]kv.Entity, error) {
+			lower, upper := kv.String("Intro"), kv.String("Introduction")
+			return ms.EntitiesByNameValueRange(&lower, &upper, c, n)
+		}, []kv.Entity{2, 6}},
+	} {
+		var (
+			cursor kv.IndexCursor
+			got    []kv.Entity
+		)
+		for {
+			es, err := test.read(&cursor, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, es...)
+			if len(es) < 2 {
+				break
+			}
+		}
+		if !kv.EntitySlice(test.want).Equal(kv.EntitySlice(got)) {
+			t.Errorf("%s: want %v, got %v", test.name, test.want, got)
+		}
+	}
+
+	if ies, err := ms.VerifyNameIndexes(); err != nil {
+		t.Error(err)
+	} else if len(ies) != 0 {
+		t.Errorf("want a consistent index, got %v", ies)
+	}
+}
+
+func TestMigrateScalableIndexes(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	var name Name
+	name.Value = "Test"
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		ms := New(txn)
+		tmi := &TopicMapInfo{}
+		tmi.TopicMap = 1
+		if err := ms.SetTopicMapInfo(1, tmi); err != nil {
+			return err
+		}
+		ms.Partition = 1
+		if err := ms.SetName(2, &name); err != nil {
+			return err
+		}
+		// Replace the scalable index entry with one in the layout used
+		// before migration step 3.
+		if err := ms.RemoveScalableIndexEntry(NamePrefix, ValuePrefix, []byte("Test"), 2); err != nil {
+			return err
+		}
+		key := kv.ConcatByteSlices(
+			kv.Entity(1).Encode(), NamePrefix.Encode(), kv.Entity(0).Encode(),
+			ValuePrefix.Encode(), []byte("Test"))
+		return txn.Set(key, kv.EntitySlice{2}.Encode())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Pretend that steps 1 and 2 have already been applied.
+	older := migrate.NewSchema("models")
+	older.Register(1, "", func(kv.Txn) error { return nil })
+	older.Register(2, "", func(kv.Txn) error { return nil })
+	if err := older.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrations.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	ms := New(txn)
+	ms.Partition = 1
+	if es, err := ms.EntitiesMatchingNameValue("Test"); err != nil {
+		t.Error(err)
+	} else if !es.Equal(kv.EntitySlice{2}) {
+		t.Errorf("want [2], got %v", es)
+	}
+	if ies, err := ms.VerifyNameIndexes(); err != nil {
+		t.Error(err)
+	} else if len(ies) != 0 {
+		t.Errorf("want a consistent index, got %v", ies)
+	}
+}
+
+func TestSearchTopics(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	ms := New(txn)
+	ms.Partition = 1
+	for _, n := range []struct {
+		Entity, Topic kv.Entity
+		Value         string
+	}{
+		{10, 1, "Café Racer"},
+		{11, 2, "Racing Bicycle"},
+		{12, 3, "Motorcycle"},
+		// A name that refers to no topic is never found.
+		{13, 0, "Racer without a topic"},
+	} {
+		var name Name
+		name.Topic, name.Value = uint64(n.Topic), n.Value
+		if err := ms.SetName(n.Entity, &name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var occurrence Occurrence
+	occurrence.Topic, occurrence.Value = 3, "a racer built from a motorcycle"
+	if err := ms.SetOccurrence(20, &occurrence); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		Query string
+		N     int
+		Want  []kv.Entity
+	}{
+		{"cafe", 0, []kv.Entity{1}},
+		{"racer", 0, []kv.Entity{1, 3}},
+		{"rac", 0, []kv.Entity{1, 2, 3}},
+		{"rac", 2, []kv.Entity{1, 2}},
+		{"racer motor", 0, []kv.Entity{1, 3}},
+		{"MOTOR", 0, []kv.Entity{3}},
+		{"", 0, []kv.Entity{}},
+	} {
+		got, err := ms.SearchTopics(test.Query, test.N)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("%q: got %v, want %v", test.Query, got, test.Want)
+		}
+	}
+	// Changing a name's value must remove it from the words of its old
+	// value.
+	var name Name
+	name.Topic, name.Value = 1, "Scrambler"
+	if err := ms.SetName(10, &name); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ms.SearchTopics("cafe", 0); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 0 {
+		t.Errorf("got %v, want no topics", got)
+	}
+}
+
+func TestMigrateWordsIndexes(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	var occurrence Occurrence
+	occurrence.Topic, occurrence.Value = 3, "Hello, World"
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		ms := New(txn)
+		tmi := &TopicMapInfo{}
+		tmi.TopicMap = 1
+		if err := ms.SetTopicMapInfo(1, tmi); err != nil {
+			return err
+		}
+		ms.Partition = 1
+		if err := ms.SetOccurrence(2, &occurrence); err != nil {
+			return err
+		}
+		// Remove the words index entries, which did not exist before
+		// migration step 4.
+		for _, w := range []string{"hello", "world"} {
+			if err := ms.RemoveScalableIndexEntry(OccurrencePrefix, WordsPrefix, []byte(w), 2); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Pretend that steps 1 through 3 have already been applied.
+	older := migrate.NewSchema("models")
+	for v := uint64(1); v <= 3; v++ {
+		older.Register(v, "", func(kv.Txn) error { return nil })
+	}
+	if err := older.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrations.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	ms := New(txn)
+	ms.Partition = 1
+	if got, err := ms.SearchTopics("world", 0); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(got, []kv.Entity{3}) {
+		t.Errorf("want [3], got %v", got)
+	}
+}
+
+func TestDeleteEntityCascades(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	txn := db.NewTxn(true)
+	defer txn.Discard()
+	ms := New(txn)
+	ms.Partition = 1
+	var name Name
+	name.SetRefTopic(2)
+	name.Value = "Cascade"
+	if err := ms.SetName(3, &name); err != nil {
+		t.Fatal(err)
+	}
+	var occurrence Occurrence
+	occurrence.Value = "Cascade"
+	if err := ms.SetOccurrence(4, &occurrence); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.SetOccurrenceTopic(4, 2); err != nil {
+		t.Fatal(err)
+	}
+	if es, err := ms.EntitiesMatchingOccurrenceTopic(2); err != nil {
+		t.Fatal(err)
+	} else if !es.Equal(kv.EntitySlice{4}) {
+		t.Errorf("want [4], got %v", es)
+	}
+	if err := ms.DeleteEntity(2); err != nil {
+		t.Fatal(err)
+	}
+	if es, err := ms.AllNameEntities(nil, 0); err != nil {
+		t.Fatal(err)
+	} else if len(es) != 0 {
+		t.Errorf("want no names, got %v", es)
+	}
+	if es, err := ms.AllOccurrenceEntities(nil, 0); err != nil {
+		t.Fatal(err)
+	} else if len(es) != 0 {
+		t.Errorf("want no occurrences, got %v", es)
+	}
+	if got, err := ms.SearchTopics("cascade", 0); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 0 {
+		t.Errorf("want no topics, got %v", got)
+	}
+}
+
+func TestWatchPartition(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	sub, err := kv.Watch(db, kv.Entity(1).Encode())
+	if err == kv.ErrWatchUnsupported {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	update := func(p kv.Entity, f func(Txn) error) {
+		t.Helper()
+		txn := db.NewTxn(true)
+		defer txn.Discard()
+		ms := New(txn)
+		ms.Partition = p
+		if err := f(ms); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var name Name
+	name.SetRefTopic(2)
+	name.Value = "Watched"
+	update(5, func(ms Txn) error { return ms.SetName(3, &name) })
+	update(1, func(ms Txn) error { return ms.SetName(3, &name) })
+	update(1, func(ms Txn) error { return ms.DeleteName(3) })
+	for _, want := range []ComponentChange{
+		{Partition: 1, Component: NamePrefix, Entity: 3, Value: &name},
+		{Partition: 1, Component: NamePrefix, Entity: 3, Deleted: true},
+	} {
+		var got []ComponentChange
+		for _, c := range <-sub.Changes() {
+			cc, ok, err := DecodeChange(c)
+			if err != nil {
+				t.Fatal(err)
+			} else if ok && cc.Component == NamePrefix {
+				// Changes to the TopicNames of topic 2 are
+				// not of interest here.
+				got = append(got, cc)
+			}
+		}
+		if len(got) != 1 {
+			t.Fatalf("want one component change, got %v", got)
+		}
+		if v, ok := got[0].Value.(*Name); ok && want.Value != nil {
+			if !proto.Equal(&v.Name, &name.Name) {
+				t.Errorf("want %v, got %v", &name.Name, &v.Name)
+			}
+			got[0].Value = want.Value
+		}
+		if !reflect.DeepEqual(got[0], want) {
+			t.Errorf("want %+v, got %+v", want, got[0])
+		}
+	}
+}
+
+func TestMigrateTopicIndexes(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		ms := New(txn)
+		tmi := &TopicMapInfo{}
+		tmi.TopicMap = 1
+		if err := ms.SetTopicMapInfo(1, tmi); err != nil {
+			return err
+		}
+		ms.Partition = 1
+		var name Name
+		name.SetRefTopic(2)
+		if err := ms.SetName(3, &name); err != nil {
+			return err
+		}
+		// Remove the reverse index entry, which did not exist before
+		// migration step 5.
+		return ms.RemoveScalableIndexEntry(NamePrefix, TopicPrefix, kv.Entity(2).Encode(), 3)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Pretend that steps 1 through 4 have already been applied.
+	older := migrate.NewSchema("models")
+	for v := uint64(1); v <= 4; v++ {
+		older.Register(v, "", func(kv.Txn) error { return nil })
+	}
+	if err := older.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrations.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	ms := New(txn)
+	ms.Partition = 1
+	if es, err := ms.EntitiesMatchingNameTopic(2); err != nil {
+		t.Error(err)
+	} else if !es.Equal(kv.EntitySlice{3}) {
+		t.Errorf("want [3], got %v", es)
+	}
+}
+
+func TestAddRemoveName(t *testing.T) {
+	txn := New(memory.New())
+	txn.Partition = 1
+	for _, topic := range []kv.Entity{2, 3} {
+		if err := txn.SetTopicNames(topic, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add := func(e, topic kv.Entity) {
+		t.Helper()
+		var name Name
+		name.SetRefTopic(topic)
+		if err := txn.AddName(e, &name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(topic kv.Entity, want ...kv.Entity) {
+		t.Helper()
+		if got, err := txn.GetTopicNames(topic); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual([]kv.Entity(got), want) {
+			t.Errorf("topic %v: want names %v, got %v", topic, want, got)
+		}
+	}
+	// Names are listed in the order they are added, which need not be
+	// the order of their entities.
+	add(5, 2)
+	add(4, 2)
+	add(4, 2)
+	check(2, 5, 4)
+	add(5, 3)
+	check(2, 4)
+	check(3, 5)
+	if ok, err := txn.IsTopic(2); err != nil || !ok {
+		t.Errorf("IsTopic(2): want true, got %v, %v", ok, err)
+	}
+	if ok, err := txn.IsTopic(4); err != nil || ok {
+		t.Errorf("IsTopic(4): want false, got %v, %v", ok, err)
+	}
+	var orphan Name
+	orphan.SetRefTopic(9)
+	if err := txn.AddName(8, &orphan); err == nil {
+		t.Error("added a name to entity 9, which is not a topic")
+	}
+	orphan.SetRefTopic(5)
+	if err := txn.AddName(8, &orphan); err == nil {
+		t.Error("added a name to name 5")
+	}
+	if err := txn.RemoveName(2); err == nil {
+		t.Error("removed topic 2 as a name")
+	}
+	if err := txn.RemoveTopic(4); err == nil {
+		t.Error("removed name 4 as a topic")
+	}
+	if err := txn.RemoveName(4); err != nil {
+		t.Fatal(err)
+	}
+	check(2)
+	if es, err := txn.AllNameEntities(nil, 0); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(es, []kv.Entity{5}) {
+		t.Errorf("want names [5], got %v", es)
+	}
+	var occurrence Occurrence
+	occurrence.SetRefTopic(3)
+	if err := txn.AddOccurrence(6, &occurrence); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := txn.GetTopicOccurrences(3); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual([]kv.Entity(got), []kv.Entity{6}) {
+		t.Errorf("want occurrences [6], got %v", got)
+	}
+	if err := txn.RemoveOccurrence(6); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := txn.GetTopicOccurrences(3); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 0 {
+		t.Errorf("want no occurrences, got %v", got)
+	}
+}
+
+func TestTopicListsFollowReferences(t *testing.T) {
+	txn := New(memory.New())
+	txn.Partition = 1
+	check := func(topic kv.Entity, want ...kv.Entity) {
+		t.Helper()
+		if got, err := txn.GetTopicNames(topic); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual([]kv.Entity(got), want) {
+			t.Errorf("topic %v: want names %v, got %v", topic, want, got)
+		}
+	}
+	for _, e := range []kv.Entity{4, 5, 6} {
+		if err := txn.SetName(e, &Name{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.SetNameTopic(e, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(2, 4, 5, 6)
+	// Changing a name's value keeps its place in the list.
+	var name Name
+	name.SetRefTopic(2)
+	name.Value = "renamed"
+	if err := txn.SetName(4, &name); err != nil {
+		t.Fatal(err)
+	}
+	check(2, 4, 5, 6)
+	if err := txn.SetNameTopic(5, 3); err != nil {
+		t.Fatal(err)
+	}
+	check(2, 4, 6)
+	check(3, 5)
+	if err := txn.DeleteEntity(6); err != nil {
+		t.Fatal(err)
+	}
+	check(2, 4)
+	if err := txn.SetNameTopic(4, 0); err != nil {
+		t.Fatal(err)
+	}
+	check(2)
+	if ok, err := txn.IsTopic(2); err != nil || !ok {
+		t.Errorf("IsTopic(2): want true after removing its names, got %v, %v", ok, err)
+	}
+	var occurrence Occurrence
+	occurrence.SetRefTopic(3)
+	if err := txn.SetOccurrence(7, &occurrence); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := txn.GetTopicOccurrences(3); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual([]kv.Entity(got), []kv.Entity{7}) {
+		t.Errorf("want occurrences [7], got %v", got)
+	}
+	if err := txn.DeleteEntity(7); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := txn.GetTopicOccurrences(3); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 0 {
+		t.Errorf("want no occurrences, got %v", got)
+	}
+}
+
+func TestMigrateTopicLists(t *testing.T) {
+	db := kvtest.NewDB(t)
+	defer db.Close()
+	if err := kv.Update(db, func(txn kv.Txn) error {
+		ms := New(txn)
+		tmi := &TopicMapInfo{}
+		tmi.TopicMap = 1
+		if err := ms.SetTopicMapInfo(1, tmi); err != nil {
+			return err
+		}
+		ms.Partition = 1
+		for e, topic := range map[kv.Entity]kv.Entity{3: 2, 4: 0, 6: 7} {
+			var name Name
+			name.SetRefTopic(topic)
+			if err := ms.SetName(e, &name); err != nil {
+				return err
+			}
+		}
+		// Before migration step 6, topic 2 lists a name that does not
+		// exist, a name that refers to no topic, and a name that
+		// refers to another topic, but not the name that refers to it.
+		return ms.SetTopicNames(2, TopicNames{9, 4, 6})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Pretend that steps 1 through 5 have already been applied.
+	older := migrate.NewSchema("models")
+	for v := uint64(1); v <= 5; v++ {
+		older.Register(v, "", func(kv.Txn) error { return nil })
+	}
+	if err := older.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrations.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	txn := db.NewTxn(false)
+	defer txn.Discard()
+	ms := New(txn)
+	ms.Partition = 1
+	if got, err := ms.GetTopicNames(2); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual([]kv.Entity(got), []kv.Entity{4, 3}) {
+		t.Errorf("want names [4 3], got %v", got)
+	}
+	if es, err := ms.EntitiesMatchingNameTopic(2); err != nil {
+		t.Error(err)
+	} else if !es.Equal(kv.EntitySlice{3, 4}) {
+		t.Errorf("want [3 4], got %v", es)
+	}
+}
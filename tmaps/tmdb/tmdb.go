@@ -119,6 +119,9 @@ func (tx Txn) Merge(t *pb.AnyItem) error {
 		if err = tx.Merge(name); err != nil {
 			return err
 		}
+		if err = tx.SetNameTopic(kv.Entity(name.ItemId), te); err != nil {
+			return err
+		}
 		ns.Insert(kv.Entity(name.ItemId))
 	}
 	if err := tx.SetTopicNames(te, models.TopicNames(ns)); err != nil {
@@ -133,6 +136,9 @@ func (tx Txn) Merge(t *pb.AnyItem) error {
 		if err = tx.Merge(occurrence); err != nil {
 			return err
 		}
+		if err = tx.SetOccurrenceTopic(kv.Entity(occurrence.ItemId), te); err != nil {
+			return err
+		}
 		os.Insert(kv.Entity(occurrence.ItemId))
 	}
 	if err := tx.SetTopicOccurrences(te, models.TopicOccurrences(os)); err != nil {